@@ -0,0 +1,36 @@
+// Command calibrate plays bots of different strength against each other,
+// fits their Elo ratings and prints the calibration table used by
+// services.botCalibration.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+
+	"tictactoe/internal/rules"
+	"tictactoe/internal/services"
+)
+
+func main() {
+	games := flag.Int("games", 200, "games per pair of bots")
+	seed := flag.Int64("seed", 1, "random seed")
+	flag.Parse()
+
+	points := services.SelfPlay{
+		Rules:        rules.Classic{},
+		Temperatures: services.DefaultCalibrationTemperatures,
+		GamesPerPair: *games,
+		Seed:         *seed,
+	}.Calibrate()
+
+	fmt.Println("var botCalibration = []CalibrationPoint{")
+	for _, p := range points {
+		temperature := fmt.Sprint(p.Temperature)
+		if math.IsInf(p.Temperature, 1) {
+			temperature = "math.Inf(1)"
+		}
+		fmt.Printf("\t{Temperature: %s, Elo: %d},\n", temperature, p.Elo)
+	}
+	fmt.Println("}")
+}
@@ -0,0 +1,23 @@
+// Command engine is the reference external engine. It speaks the engine
+// protocol described in services/external_engine.go on stdin and stdout
+// and plays with the built-in search. Build it and point BOT_ENGINE at the
+// binary to have the server's bots play through it.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"tictactoe/internal/services"
+)
+
+func main() {
+	name := flag.String("name", "tictactoe-reference", "engine name sent in the handshake")
+	flag.Parse()
+
+	if err := services.ServeEngine(*name, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
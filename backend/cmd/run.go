@@ -1,10 +1,10 @@
 package cmd
 
 import (
-	"context"
 	"os"
 	"tictactoe/config"
 	"tictactoe/internal/api/http"
+	"tictactoe/internal/bus"
 	"tictactoe/internal/cache"
 	"tictactoe/internal/logger"
 	"tictactoe/internal/services"
@@ -33,14 +33,39 @@ func Run() {
 			logger.Error("failed to close Redis:", err)
 		}
 	}()
-	rdb.Set(context.Background(), "active_games", 0, 0)
 
 	sessionStore := store.NewUserStore(db)
+	gameStore := store.NewGameStore(db)
 	sessionService := services.NewSessionService(rdb, sessionStore)
 
 	leaderboardService := services.NewLeaderboardService(rdb, sessionStore)
 
-	router := http.NewRouter(sessionService, leaderboardService)
+	if cfg.BotBooksDir != "" {
+		if err := services.LoadOpeningBooks(cfg.BotBooksDir); err != nil {
+			logger.Error("failed to load bot opening books:", err)
+			return
+		}
+	}
+
+	if cfg.BotEngine != "" {
+		engine := services.NewExternalEngine(services.ExternalEngineConfig{
+			Path:      cfg.BotEngine,
+			Processes: cfg.BotEngineProcesses,
+		})
+		if _, err := engine.Start(); err != nil {
+			logger.Error("failed to start bot engine:", err)
+			return
+		}
+		defer engine.Close()
+		services.UseExternalEngine(engine)
+	}
+
+	chatService := services.NewChatService(store.NewChatStore(db), services.NewWordListFilter(cfg.ChatBannedWords))
+
+	messageBus := bus.NewRedisBus(rdb)
+	defer messageBus.Close()
+
+	router := http.NewRouter(sessionService, leaderboardService, gameStore, chatService, messageBus)
 
 	port := os.Getenv("PORT")
 
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 
 	"tictactoe/internal/logger"
 
@@ -13,6 +15,14 @@ type Config struct {
 	PostgresDSN string
 	RedisAddr   string
 	RedisPass   string
+	// ChatBannedWords - слова, которые скрываются в чате (CHAT_BANNED_WORDS через запятую)
+	ChatBannedWords []string
+	// BotBooksDir - каталог с дебютными книгами ботов <id>.book (BOT_BOOKS_DIR)
+	BotBooksDir string
+	// BotEngine - путь к внешнему движку ботов (BOT_ENGINE), пусто - встроенные движки
+	BotEngine string
+	// BotEngineProcesses - сколько процессов движка запускать (BOT_ENGINE_PROCESSES), 0 - по умолчанию
+	BotEngineProcesses int
 }
 
 func Load() *Config {
@@ -21,10 +31,15 @@ func Load() *Config {
 	}
 
 	return &Config{
-		ServerPort:  os.Getenv("SERVER_PORT"),
-		PostgresDSN: mustGet("POSTGRES_DSN"),
-		RedisAddr:   mustGet("REDIS_ADDR"),
-		RedisPass:   os.Getenv("REDIS_PASS"),
+		ServerPort:      os.Getenv("SERVER_PORT"),
+		PostgresDSN:     mustGet("POSTGRES_DSN"),
+		RedisAddr:       mustGet("REDIS_ADDR"),
+		RedisPass:       os.Getenv("REDIS_PASS"),
+		ChatBannedWords: splitList(os.Getenv("CHAT_BANNED_WORDS")),
+		BotBooksDir:     os.Getenv("BOT_BOOKS_DIR"),
+		BotEngine:       os.Getenv("BOT_ENGINE"),
+		// Неверное число - то же, что не заданное
+		BotEngineProcesses: atoi(os.Getenv("BOT_ENGINE_PROCESSES")),
 	}
 }
 
@@ -35,3 +50,15 @@ func mustGet(key string) string {
 	logger.Error("Missing required environment variable:", key)
 	panic("Missing env: " + key)
 }
+
+func atoi(val string) int {
+	n, _ := strconv.Atoi(val)
+	return n
+}
+
+func splitList(val string) []string {
+	if val == "" {
+		return nil
+	}
+	return strings.Split(val, ",")
+}
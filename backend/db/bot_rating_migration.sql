@@ -0,0 +1,2 @@
+-- Separate rating for games against bots
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bot_elo_rating INT NOT NULL DEFAULT 1000;
@@ -0,0 +1,13 @@
+-- Create chat_messages table for in-game chat
+CREATE TABLE IF NOT EXISTS chat_messages (
+    id SERIAL PRIMARY KEY,
+    player_x VARCHAR(50) NOT NULL,
+    player_o VARCHAR(50) NOT NULL,
+    game_started_at TIMESTAMP NOT NULL,
+    sender VARCHAR(50) NOT NULL,
+    kind VARCHAR(10) NOT NULL,
+    body VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS chat_messages_game_idx ON chat_messages (player_x, player_o, game_started_at);
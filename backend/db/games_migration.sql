@@ -0,0 +1,34 @@
+-- Create games and game_moves tables for match history
+CREATE TABLE IF NOT EXISTS games (
+    id SERIAL PRIMARY KEY,
+    player_x VARCHAR(50) NOT NULL,
+    player_o VARCHAR(50) NOT NULL,
+    variant VARCHAR(20) NOT NULL DEFAULT 'classic',
+    board_size INT NOT NULL DEFAULT 3,
+    win_length INT NOT NULL DEFAULT 3,
+    mode VARCHAR(10) NOT NULL DEFAULT 'pvp',
+    bot_difficulty VARCHAR(20) NOT NULL DEFAULT '',
+    time_control VARCHAR(20) NOT NULL DEFAULT '',
+    result VARCHAR(10) NOT NULL,
+    reason VARCHAR(20) NOT NULL,
+    elo_x_before INT,
+    elo_x_after INT,
+    elo_o_before INT,
+    elo_o_after INT,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS games_player_x_idx ON games (player_x, id DESC);
+CREATE INDEX IF NOT EXISTS games_player_o_idx ON games (player_o, id DESC);
+
+CREATE TABLE IF NOT EXISTS game_moves (
+    game_id INT NOT NULL,
+    ply INT NOT NULL,
+    seat VARCHAR(1) NOT NULL,
+    cell INT NOT NULL,
+    symbol VARCHAR(1) NOT NULL,
+    played_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (game_id, ply),
+    FOREIGN KEY (game_id) REFERENCES games(id) ON DELETE CASCADE
+);
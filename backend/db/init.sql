@@ -9,6 +9,7 @@ CREATE TABLE IF NOT EXISTS users (
     losses INT NOT NULL DEFAULT 0,
     draws INT NOT NULL DEFAULT 0,
     elo_rating INT NOT NULL DEFAULT 1000,
+    bot_elo_rating INT NOT NULL DEFAULT 1000,
     coins INT NOT NULL DEFAULT 0,
     active_skin VARCHAR(50) NOT NULL DEFAULT 'default'
     );
@@ -19,4 +20,51 @@ CREATE TABLE IF NOT EXISTS inventory (
     purchased_at TIMESTAMP DEFAULT NOW(),
     PRIMARY KEY (user_id, item_id),
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-);
\ No newline at end of file
+);
+
+CREATE TABLE IF NOT EXISTS games (
+    id SERIAL PRIMARY KEY,
+    player_x VARCHAR(50) NOT NULL,
+    player_o VARCHAR(50) NOT NULL,
+    variant VARCHAR(20) NOT NULL DEFAULT 'classic',
+    board_size INT NOT NULL DEFAULT 3,
+    win_length INT NOT NULL DEFAULT 3,
+    mode VARCHAR(10) NOT NULL DEFAULT 'pvp',
+    bot_difficulty VARCHAR(20) NOT NULL DEFAULT '',
+    time_control VARCHAR(20) NOT NULL DEFAULT '',
+    result VARCHAR(10) NOT NULL,
+    reason VARCHAR(20) NOT NULL,
+    elo_x_before INT,
+    elo_x_after INT,
+    elo_o_before INT,
+    elo_o_after INT,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS games_player_x_idx ON games (player_x, id DESC);
+CREATE INDEX IF NOT EXISTS games_player_o_idx ON games (player_o, id DESC);
+
+CREATE TABLE IF NOT EXISTS game_moves (
+    game_id INT NOT NULL,
+    ply INT NOT NULL,
+    seat VARCHAR(1) NOT NULL,
+    cell INT NOT NULL,
+    symbol VARCHAR(1) NOT NULL,
+    played_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (game_id, ply),
+    FOREIGN KEY (game_id) REFERENCES games(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS chat_messages (
+    id SERIAL PRIMARY KEY,
+    player_x VARCHAR(50) NOT NULL,
+    player_o VARCHAR(50) NOT NULL,
+    game_started_at TIMESTAMP NOT NULL,
+    sender VARCHAR(50) NOT NULL,
+    kind VARCHAR(10) NOT NULL,
+    body VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS chat_messages_game_idx ON chat_messages (player_x, player_o, game_started_at);
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+	"tictactoe/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BotHandler отдает характеры ботов, которых можно выбрать в find_bot_match.
+type BotHandler struct{}
+
+func NewBotHandler() *BotHandler {
+	return &BotHandler{}
+}
+
+func (h *BotHandler) GetBots(c *gin.Context) {
+	c.JSON(http.StatusOK, services.BotPersonalities)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"tictactoe/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GameHandler struct {
+	History *services.HistoryService
+	Games   *services.GameManager
+}
+
+func NewGameHandler(history *services.HistoryService, games *services.GameManager) *GameHandler {
+	return &GameHandler{History: history, Games: games}
+}
+
+// GetLiveGames возвращает идущие партии, которые можно смотреть
+func (h *GameHandler) GetLiveGames(c *gin.Context) {
+	c.JSON(http.StatusOK, h.Games.LiveGames())
+}
+
+// GetHistory возвращает страницу законченных партий игрока
+func (h *GameHandler) GetHistory(c *gin.Context) {
+	player := c.Query("player")
+	if player == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "player required"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	page, err := h.History.GetHistory(player, c.Query("cursor"), limit)
+	if errors.Is(err, services.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch games"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetGame возвращает законченную партию со списком ходов для просмотра
+func (h *GameHandler) GetGame(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid game id"})
+		return
+	}
+
+	game, err := h.History.GetGame(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "game not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, game)
+}
+
+// maxImportSize - наибольший размер импортируемого файла нотации
+const maxImportSize = 64 << 10
+
+// ExportGame возвращает законченную партию в текстовой нотации
+func (h *GameHandler) ExportGame(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid game id"})
+		return
+	}
+
+	text, err := h.History.ExportGame(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "game not found"})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=game-"+c.Param("id")+".ttt")
+	c.String(http.StatusOK, text)
+}
+
+// ImportGame проверяет партию в текстовой нотации, проигрывая ее ходы
+func (h *GameHandler) ImportGame(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxImportSize+1))
+	if err != nil || len(body) > maxImportSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	game, state, outcome, err := h.History.ImportGame(string(body))
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	cells := make([]int, 0, len(game.Moves))
+	for _, move := range game.Moves {
+		cells = append(cells, move.Cell)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"player_x":        game.X,
+		"player_o":        game.O,
+		"variant":         game.Options.Variant,
+		"size":            game.Options.Size,
+		"win_length":      game.Options.WinLength,
+		"result":          game.Result,
+		"reason":          game.Reason,
+		"moves":           cells,
+		"board":           state.Board,
+		"is_finished":     outcome.Finished,
+		"winner":          outcome.Winner,
+		"winning_pattern": outcome.Line,
+	})
+}
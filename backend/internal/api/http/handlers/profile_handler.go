@@ -3,18 +3,24 @@ package handlers
 import (
 	"net/http"
 
+	"tictactoe/internal/logger"
+	"tictactoe/internal/services"
 	"tictactoe/internal/store"
 
 	"github.com/gin-gonic/gin"
 )
 
+const recentGamesCount = 5
+
 type ProfileHandler struct {
 	UserStore *store.UserStore
+	History   *services.HistoryService
 }
 
-func NewProfileHandler(userStore *store.UserStore) *ProfileHandler {
+func NewProfileHandler(userStore *store.UserStore, history *services.HistoryService) *ProfileHandler {
 	return &ProfileHandler{
 		UserStore: userStore,
+		History:   history,
 	}
 }
 
@@ -34,11 +40,12 @@ func (h *ProfileHandler) GetProfileStats(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"nickname":   user.Nickname,
-		"wins":       user.Wins,
-		"losses":     user.Losses,
-		"draws":      user.Draws,
-		"elo_rating": user.EloRating,
+		"nickname":       user.Nickname,
+		"wins":           user.Wins,
+		"losses":         user.Losses,
+		"draws":          user.Draws,
+		"elo_rating":     user.EloRating,
+		"bot_elo_rating": user.BotEloRating,
 	})
 }
 
@@ -56,11 +63,19 @@ func (h *ProfileHandler) GetUserProfileByNickname(c *gin.Context) {
 		return
 	}
 
+	recentGames, err := h.History.RecentGames(user.Nickname, recentGamesCount)
+	if err != nil {
+		logger.Warn("Failed to load recent games for", user.Nickname, ":", err)
+		recentGames = nil
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"nickname":   user.Nickname,
-		"wins":       user.Wins,
-		"losses":     user.Losses,
-		"draws":      user.Draws,
-		"elo_rating": user.EloRating,
+		"nickname":       user.Nickname,
+		"wins":           user.Wins,
+		"losses":         user.Losses,
+		"draws":          user.Draws,
+		"elo_rating":     user.EloRating,
+		"bot_elo_rating": user.BotEloRating,
+		"recent_games":   recentGames,
 	})
 }
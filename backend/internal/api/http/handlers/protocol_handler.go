@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"tictactoe/internal/protocol"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProtocolHandler отдает JSON Schema протокола WebSocket.
+type ProtocolHandler struct {
+	schema []byte
+}
+
+func NewProtocolHandler() *ProtocolHandler {
+	// Схема не меняется во время работы, поэтому собираем ее один раз
+	schema, _ := json.Marshal(protocol.Schema())
+	return &ProtocolHandler{schema: schema}
+}
+
+func (h *ProtocolHandler) GetSchema(c *gin.Context) {
+	c.Data(http.StatusOK, "application/schema+json", h.schema)
+}
@@ -6,13 +6,15 @@ import (
 
 	"tictactoe/internal/api/http/handlers"
 	"tictactoe/internal/api/ws"
+	"tictactoe/internal/bus"
 	"tictactoe/internal/services"
+	"tictactoe/internal/store"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-func NewRouter(sessionService *services.SessionService, leaderboardService *services.LeaderboardService) *gin.Engine {
+func NewRouter(sessionService *services.SessionService, leaderboardService *services.LeaderboardService, gameStore *store.GameStore, chatService *services.ChatService, messageBus bus.Bus) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	gin.DefaultWriter = io.Discard
 
@@ -33,13 +35,18 @@ func NewRouter(sessionService *services.SessionService, leaderboardService *serv
 	// Создаем middleware
 	authMiddleware := AuthMiddleware(sessionService.RDB) // <-- НАШ MIDDLEWARE
 
-	manager := ws.NewManager(sessionService.RDB, sessionService.Store)
+	gameManager := services.NewGameManager(sessionService.Store, gameStore)
+	manager := ws.NewManager(sessionService.RDB, messageBus, gameManager, chatService)
 	statsHandler := handlers.NewStatsHandler(sessionService.RDB)
 	sessionHandler := handlers.NewSessionHandler(sessionService, sessionService.RDB)
-	profileHandler := handlers.NewProfileHandler(sessionService.Store)
+	historyService := services.NewHistoryService(gameStore)
+	profileHandler := handlers.NewProfileHandler(sessionService.Store, historyService)
+	gameHandler := handlers.NewGameHandler(historyService, gameManager)
 	leaderboardHandler := handlers.NewLeaderboardHandler(leaderboardService)
 	shopService := services.NewShopService(sessionService.Store)
 	shopHandler := handlers.NewShopHandler(shopService)
+	protocolHandler := handlers.NewProtocolHandler()
+	botHandler := handlers.NewBotHandler()
 
 	// Защищенный WebSocket
 	router.GET("/ws", authMiddleware, func(c *gin.Context) {
@@ -57,12 +64,20 @@ func NewRouter(sessionService *services.SessionService, leaderboardService *serv
 		api.POST("/logout", sessionHandler.Logout)
 
 		api.GET("/stats", statsHandler.GetStats)
+		api.GET("/ws-schema", protocolHandler.GetSchema)
 		api.GET("/leaderboard", leaderboardHandler.GetLeaderboard)
+		api.GET("/bots", botHandler.GetBots)
 
 		api.GET("/nickname", authMiddleware, sessionHandler.GetNickname)
 		api.GET("/profile-stats", authMiddleware, profileHandler.GetProfileStats)
 		api.GET("/profile/:nickname", profileHandler.GetUserProfileByNickname)
 
+		api.GET("/games", gameHandler.GetHistory)
+		api.GET("/games/live", gameHandler.GetLiveGames)
+		api.GET("/games/:id", gameHandler.GetGame)
+		api.GET("/games/:id/export", gameHandler.ExportGame)
+		api.POST("/games/import", gameHandler.ImportGame)
+
 		shop := api.Group("/shop")
 		shop.Use(authMiddleware)
 		{
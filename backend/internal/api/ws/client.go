@@ -0,0 +1,177 @@
+package ws
+
+import (
+	"sync"
+	"time"
+
+	"tictactoe/internal/logger"
+	"tictactoe/internal/protocol"
+
+	"github.com/gorilla/websocket"
+)
+
+// Параметры соединения
+const (
+	// writeWait - сколько ждать записи одного сообщения
+	writeWait = 10 * time.Second
+	// pongWait - сколько ждать pong или любого сообщения от клиента
+	pongWait = 60 * time.Second
+	// pingPeriod - как часто слать ping; меньше pongWait
+	pingPeriod = pongWait * 9 / 10
+	// maxMessageSize - максимальный размер сообщения от клиента в байтах
+	maxMessageSize = 4096
+	// sendBufferSize - сколько исходящих сообщений может ждать записи.
+	// Клиент, который не успевает их читать, отключается.
+	sendBufferSize = 64
+)
+
+// Client - соединение игрока. Писать в соединение может только одна
+// горутина (writePump), остальные ставят сообщения в очередь через Send.
+// Сообщения кодируются в формате, о котором клиент договорился при
+// подключении.
+type Client struct {
+	nickname  string
+	conn      *websocket.Conn
+	codec     protocol.Codec
+	frameType int
+	send      chan []byte
+	done      chan struct{}
+
+	pongWait   time.Duration
+	pingPeriod time.Duration
+
+	closeOnce sync.Once
+	closeCode int
+	closeText string
+}
+
+func newClient(nickname string, conn *websocket.Conn, codec protocol.Codec) *Client {
+	frameType := websocket.TextMessage
+	if codec.Binary() {
+		frameType = websocket.BinaryMessage
+	}
+	return &Client{
+		nickname:   nickname,
+		conn:       conn,
+		codec:      codec,
+		frameType:  frameType,
+		send:       make(chan []byte, sendBufferSize),
+		done:       make(chan struct{}),
+		pongWait:   pongWait,
+		pingPeriod: pingPeriod,
+		closeCode:  websocket.CloseNormalClosure,
+	}
+}
+
+// Send кодирует сообщение и ставит его в очередь на запись. Возвращает
+// false, если сообщение не будет доставлено.
+func (c *Client) Send(msg protocol.Message) bool {
+	payload, err := c.codec.Encode(msg)
+	if err != nil {
+		logger.Error("failed to encode message:", err)
+		return false
+	}
+	return c.enqueue(payload)
+}
+
+// Deliver отправляет сообщение, пришедшее из шины в JSON.
+func (c *Client) Deliver(payload []byte) bool {
+	payload, err := c.codec.FromJSON(payload)
+	if err != nil {
+		logger.Error("failed to encode message:", err)
+		return false
+	}
+	return c.enqueue(payload)
+}
+
+// enqueue ставит кадр в очередь. Если очередь переполнена, клиент
+// считается медленным и отключается.
+func (c *Client) enqueue(payload []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- payload:
+		return true
+	case <-c.done:
+		return false
+	default:
+		logger.Warn("slow consumer, closing connection:", c.nickname)
+		c.closeWith(websocket.CloseTryAgainLater, "slow consumer")
+		return false
+	}
+}
+
+// Close закрывает соединение. Можно вызывать много раз из любых горутин.
+func (c *Client) Close() {
+	c.closeWith(websocket.CloseNormalClosure, "")
+}
+
+func (c *Client) closeWith(code int, text string) {
+	c.closeOnce.Do(func() {
+		c.closeCode, c.closeText = code, text
+		close(c.done)
+	})
+}
+
+// Done закрывается, когда соединение закрыто.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// writePump пишет сообщения из очереди и шлет ping, пока соединение не закроют.
+// После закрытия отправляет close frame и закрывает сокет, чем завершает readPump.
+func (c *Client) writePump() {
+	ticker := time.NewTicker(c.pingPeriod)
+	defer func() {
+		ticker.Stop()
+		_ = c.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(c.closeCode, c.closeText), time.Now().Add(writeWait))
+		_ = c.conn.Close()
+	}()
+
+	for {
+		select {
+		case payload := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(c.frameType, payload); err != nil {
+				c.Close()
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				c.Close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// readPump читает сообщения клиента и передает их handle, пока соединение
+// живо. Клиент, который не ответил на ping за pongWait или прислал
+// сообщение больше maxMessageSize, отключается.
+func (c *Client) readPump(handle func(data []byte)) {
+	defer c.Close()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.Warn("WebSocket read error:", err)
+			}
+			return
+		}
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
+		handle(data)
+	}
+}
@@ -0,0 +1,128 @@
+package ws
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tictactoe/internal/protocol"
+
+	"github.com/gorilla/websocket"
+)
+
+// Heartbeat timings short enough for tests.
+const (
+	testPongWait   = 200 * time.Millisecond
+	testPingPeriod = 50 * time.Millisecond
+)
+
+// serveClient runs a Client for every connection to the returned server and
+// reports on the channel when its read loop ends. A fast heartbeat shortens
+// the ping timings.
+func serveClient(t *testing.T, fastHeartbeat bool) (*httptest.Server, <-chan struct{}) {
+	t.Helper()
+	finished := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		client := newClient("alice", conn, protocol.JSON)
+		if fastHeartbeat {
+			client.pongWait, client.pingPeriod = testPongWait, testPingPeriod
+		}
+		go client.writePump()
+		client.readPump(func([]byte) {})
+		finished <- struct{}{}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, finished
+}
+
+func dial(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestClientClosesSlowConsumer(t *testing.T) {
+	client := newClient("alice", nil, protocol.JSON)
+	for i := 0; i < sendBufferSize; i++ {
+		if !client.Send(&protocol.MatchCancelled{}) {
+			t.Fatalf("message %d rejected before the buffer filled up", i)
+		}
+	}
+	if client.Send(&protocol.MatchCancelled{}) {
+		t.Fatal("message accepted into a full buffer")
+	}
+	select {
+	case <-client.Done():
+	default:
+		t.Fatal("slow consumer was not closed")
+	}
+	if client.closeCode != websocket.CloseTryAgainLater {
+		t.Fatalf("close code = %d", client.closeCode)
+	}
+	if client.Send(&protocol.MatchCancelled{}) {
+		t.Fatal("message accepted after close")
+	}
+}
+
+func TestClientRejectsLargeMessages(t *testing.T) {
+	srv, finished := serveClient(t, false)
+	conn := dial(t, srv)
+
+	if err := conn.WriteMessage(websocket.TextMessage, make([]byte, maxMessageSize+1)); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseMessageTooBig {
+		t.Fatalf("expected close 1009, got %v", err)
+	}
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("read loop did not stop")
+	}
+}
+
+func TestClientDropsPeerWithoutPong(t *testing.T) {
+	srv, finished := serveClient(t, true)
+	// The peer never reads, so it never answers pings.
+	dial(t, srv)
+
+	select {
+	case <-finished:
+	case <-time.After(2 * time.Second):
+		t.Fatal("silent peer was not disconnected")
+	}
+}
+
+func TestClientKeepsPeerAnsweringPings(t *testing.T) {
+	srv, finished := serveClient(t, true)
+	conn := dial(t, srv)
+	// Reading makes gorilla answer pings with pongs.
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-finished:
+		t.Fatal("responsive peer was disconnected")
+	case <-time.After(4 * testPongWait):
+	}
+}
@@ -0,0 +1,154 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"tictactoe/internal/bus"
+	"tictactoe/internal/logger"
+	"tictactoe/internal/protocol"
+	"tictactoe/internal/services"
+	"tictactoe/internal/utils"
+
+	"github.com/gorilla/websocket"
+)
+
+// Hub доставляет сообщения игрокам. Если игрок подключен к этому экземпляру,
+// сообщение пишется в его соединение, иначе публикуется в шину, где его
+// получит экземпляр с соединением игрока.
+type Hub struct {
+	bus     bus.Bus
+	owners  *services.Ownership
+	clients sync.Map // nickname -> *Client
+	subs    sync.Map // nickname -> func() (отписка от канала игрока)
+}
+
+// routedMessage - команда игрока, пересланная экземпляру-владельцу
+type routedMessage struct {
+	Nickname string          `json:"nickname"`
+	Message  json.RawMessage `json:"message"`
+}
+
+func playerChannel(nickname string) string {
+	return "ws:player:" + nickname
+}
+
+func instanceChannel(instanceID string) string {
+	return "ws:instance:" + instanceID
+}
+
+// instanceID возвращает имя экземпляра из INSTANCE_ID или имени хоста.
+// Экземпляр, перезапущенный с тем же именем, сразу забирает свои партии.
+func instanceID() string {
+	if id := os.Getenv("INSTANCE_ID"); id != "" {
+		return id
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return utils.GenerateSessionID()
+}
+
+func NewHub(b bus.Bus, owners *services.Ownership) *Hub {
+	return &Hub{bus: b, owners: owners}
+}
+
+// Register запоминает соединение игрока, запускает его запись и подписывает
+// игрока на сообщения с других экземпляров.
+func (h *Hub) Register(nickname string, conn *websocket.Conn, codec protocol.Codec) *Client {
+	client := newClient(nickname, conn, codec)
+	go client.writePump()
+
+	if old, ok := h.subs.LoadAndDelete(nickname); ok {
+		old.(func())()
+	}
+	h.clients.Store(nickname, client)
+
+	unsubscribe, err := h.bus.Subscribe(playerChannel(nickname), func(payload []byte) {
+		if c, ok := h.clients.Load(nickname); ok {
+			c.(*Client).Deliver(payload)
+		}
+	})
+	if err != nil {
+		logger.Error("failed to subscribe player channel:", err)
+	} else {
+		h.subs.Store(nickname, unsubscribe)
+	}
+	h.owners.Claim(services.OwnedPlayer, nickname)
+	return client
+}
+
+// Unregister забывает соединение, если игрок не успел переподключиться.
+func (h *Hub) Unregister(nickname string, client *Client) {
+	if !h.clients.CompareAndDelete(nickname, client) {
+		return
+	}
+	if unsubscribe, ok := h.subs.LoadAndDelete(nickname); ok {
+		unsubscribe.(func())()
+	}
+	h.owners.Release(services.OwnedPlayer, nickname)
+}
+
+// Send доставляет сообщение игроку. Между экземплярами сообщения идут в
+// JSON и перекодируются экземпляром с соединением.
+func (h *Hub) Send(nickname string, msg protocol.Message) {
+	if c, ok := h.clients.Load(nickname); ok {
+		c.(*Client).Send(msg)
+		return
+	}
+	payload, err := protocol.Encode(msg)
+	if err != nil {
+		logger.Error("failed to encode message:", err)
+		return
+	}
+	if err := h.bus.Publish(context.Background(), playerChannel(nickname), payload); err != nil {
+		logger.Warn("failed to publish message:", err)
+	}
+}
+
+func (h *Hub) Online(nickname string) bool {
+	if _, ok := h.clients.Load(nickname); ok {
+		return true
+	}
+	_, ok := h.owners.Remote(services.OwnedPlayer, nickname)
+	return ok
+}
+
+// Close закрывает локальное соединение игрока.
+func (h *Hub) Close(nickname string) {
+	if c, ok := h.clients.Load(nickname); ok {
+		c.(*Client).Close()
+	}
+}
+
+// Forward пересылает команду игрока экземпляру instanceID.
+func (h *Hub) Forward(instanceID, nickname string, msg protocol.Message) {
+	encoded, err := protocol.Encode(msg)
+	if err != nil {
+		logger.Error("failed to encode routed message:", err)
+		return
+	}
+	payload, err := json.Marshal(routedMessage{Nickname: nickname, Message: encoded})
+	if err != nil {
+		logger.Error("failed to encode routed message:", err)
+		return
+	}
+	if err := h.bus.Publish(context.Background(), instanceChannel(instanceID), payload); err != nil {
+		logger.Warn("failed to route message:", err)
+	}
+}
+
+// Listen принимает команды, пересланные этому экземпляру.
+func (h *Hub) Listen(handle func(nickname string, payload []byte)) error {
+	_, err := h.bus.Subscribe(instanceChannel(h.owners.InstanceID()), func(payload []byte) {
+		var routed routedMessage
+		if err := json.Unmarshal(payload, &routed); err != nil {
+			logger.Warn("invalid routed message:", err)
+			return
+		}
+		handle(routed.Nickname, routed.Message)
+	})
+	return err
+}
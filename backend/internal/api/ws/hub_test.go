@@ -0,0 +1,178 @@
+package ws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tictactoe/internal/bus"
+	"tictactoe/internal/protocol"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+// TestHubDeliversAcrossInstances connects alice to one hub and sends to her
+// through another hub sharing the same bus.
+func TestHubDeliversAcrossInstances(t *testing.T) {
+	b := bus.NewLocalBus()
+	defer b.Close()
+	home, other := NewHub(b, nil), NewHub(b, nil)
+
+	registered := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		home.Register("alice", conn, protocol.JSON)
+		close(registered)
+	}))
+	defer srv.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	<-registered
+
+	if !home.Online("alice") || other.Online("alice") {
+		t.Fatal("alice should only be connected to the home hub")
+	}
+
+	other.Send("alice", &protocol.Spectators{Count: 2})
+
+	_ = client.SetReadDeadline(time.Now().Add(time.Second))
+	var msg map[string]interface{}
+	if err := client.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg["type"] != "spectators" || msg["count"] != 2.0 {
+		t.Fatalf("unexpected message %v", msg)
+	}
+}
+
+func TestHubForward(t *testing.T) {
+	b := bus.NewLocalBus()
+	defer b.Close()
+	owner, sender := NewHub(b, nil), NewHub(b, nil)
+
+	got := make(chan string, 1)
+	if err := owner.Listen(func(nickname string, payload []byte) {
+		msg, err := protocol.Decode(payload)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		got <- fmt.Sprintf("%s:%s:%d", nickname, msg.MessageType(), msg.(*protocol.Move).Cell)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	sender.Forward("", "alice", &protocol.Move{Cell: 4})
+
+	select {
+	case v := <-got:
+		if v != "alice:move:4" {
+			t.Fatalf("unexpected routed message %q", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("routed message was not delivered")
+	}
+}
+
+// TestHubDeliversMessagePack checks that a MessagePack client gets binary
+// frames both for local sends and for JSON payloads arriving over the bus.
+func TestHubDeliversMessagePack(t *testing.T) {
+	b := bus.NewLocalBus()
+	defer b.Close()
+	home, other := NewHub(b, nil), NewHub(b, nil)
+
+	registered := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_, negotiated, ok := negotiateVersion(r, conn)
+		if !ok || negotiated != protocol.MessagePack {
+			t.Errorf("negotiated %v, %v", negotiated, ok)
+		}
+		home.Register("alice", conn, negotiated)
+		close(registered)
+	}))
+	defer srv.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{"msgpack"}}
+	client, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	<-registered
+
+	home.Send("alice", &protocol.Spectators{Count: 1})
+	other.Send("alice", &protocol.Spectators{Count: 2})
+
+	handle := &codec.MsgpackHandle{}
+	handle.RawToString = true
+	for want := int64(1); want <= 2; want++ {
+		_ = client.SetReadDeadline(time.Now().Add(time.Second))
+		frameType, data, err := client.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frameType != websocket.BinaryMessage {
+			t.Fatalf("expected a binary frame, got %d", frameType)
+		}
+		var msg map[string]interface{}
+		if err := codec.NewDecoderBytes(data, handle).Decode(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg["type"] != "spectators" || fmt.Sprint(msg["count"]) != fmt.Sprint(want) {
+			t.Fatalf("unexpected message %v", msg)
+		}
+	}
+}
+
+func TestNegotiateVersion(t *testing.T) {
+	tests := []struct {
+		offered []string
+		codec   protocol.Codec
+		ok      bool
+	}{
+		{nil, protocol.JSON, true},
+		{[]string{"tictactoe.v1"}, protocol.JSON, true},
+		{[]string{"tictactoe.v1", "tictactoe.v1.msgpack"}, protocol.MessagePack, true},
+		{[]string{"tictactoe.v99"}, nil, false},
+	}
+	for _, tt := range tests {
+		result := make(chan bool, 1)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			_, negotiated, ok := negotiateVersion(r, conn)
+			result <- ok == tt.ok && (!ok || negotiated == tt.codec)
+		}))
+		dialer := websocket.Dialer{Subprotocols: tt.offered}
+		conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !<-result {
+			t.Errorf("offered %v: unexpected negotiation result", tt.offered)
+		}
+		conn.Close()
+		srv.Close()
+	}
+}
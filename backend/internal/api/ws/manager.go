@@ -2,41 +2,68 @@ package ws
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
-	"sync"
-	"tictactoe/internal/models"
+	"strings"
 	"time"
 
+	"tictactoe/internal/bus"
 	"tictactoe/internal/logger"
+	"tictactoe/internal/models"
+	"tictactoe/internal/notation"
+	"tictactoe/internal/protocol"
+	"tictactoe/internal/rules"
 	"tictactoe/internal/services"
-	"tictactoe/internal/store"
 
 	"github.com/gorilla/websocket"
 	"github.com/redis/go-redis/v9"
 )
 
 type WSManager struct {
-	clients     sync.Map
+	hub         *Hub
 	redis       *redis.Client
 	matchmaker  *services.MatchmakingService
+	rooms       *services.RoomService
+	challenges  *services.ChallengeService
 	gameManager *services.GameManager
+	chat        *services.ChatService
 }
 
-func NewManager(rdb *redis.Client, userStore *store.UserStore) *WSManager {
-	gameManager := services.NewGameManager(userStore)
-	gameManager.StartCleaner(rdb)
+// NewManager создает обработчик WebSocket для одного экземпляра сервера.
+// Экземпляры обмениваются сообщениями через шину b: партия ведется на
+// экземпляре, который ее создал, а ходы и другие команды игроков с других
+// экземпляров пересылаются ему.
+func NewManager(rdb *redis.Client, b bus.Bus, gameManager *services.GameManager, chat *services.ChatService) *WSManager {
+	owners := services.NewOwnership(rdb, instanceID())
+	owners.Heartbeat()
+	gameManager.UseOwnership(owners)
+	gameManager.UseSnapshots(services.NewSnapshotStore(rdb))
+	hub := NewHub(b, owners)
 	manager := &WSManager{
+		hub:         hub,
 		redis:       rdb,
 		gameManager: gameManager,
-	}
-	manager.matchmaker = services.NewMatchmakerService(rdb, &manager.clients, gameManager)
+		chat:        chat,
+	}
+	manager.matchmaker = services.NewMatchmakerService(rdb, hub, gameManager)
+	manager.matchmaker.StartMatcher()
+	manager.rooms = services.NewRoomService(rdb, hub, gameManager)
+	manager.challenges = services.NewChallengeService(rdb, hub, gameManager)
+	gameManager.OnTimeout(manager.handleTimeout)
+	gameManager.OnAbandon(manager.handleTimeout)
+	gameManager.OnRestore(manager.handleRestored)
+	gameManager.OnRemove(chat.EndGame)
+	if err := hub.Listen(manager.handleRouted); err != nil {
+		logger.Error("failed to listen for routed messages:", err)
+	}
+	gameManager.RestoreGames()
+	gameManager.StartCleaner(rdb)
 	return manager
 }
 
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+	CheckOrigin:  func(r *http.Request) bool { return true },
+	Subprotocols: protocol.Subprotocols(),
 }
 
 func (m *WSManager) HandleConnection(w http.ResponseWriter, r *http.Request, nickname string) {
@@ -45,16 +72,37 @@ func (m *WSManager) HandleConnection(w http.ResponseWriter, r *http.Request, nic
 		logger.Error("WebSocket upgrade failed:", err)
 		return
 	}
+	version, codec, ok := negotiateVersion(r, conn)
+	if !ok {
+		rejectVersion(conn)
+		return
+	}
 
 	logger.Info("WebSocket connected:", nickname)
-	m.clients.Store(nickname, conn)
+	client := m.hub.Register(nickname, conn, codec)
+	m.hub.Send(nickname, &protocol.Welcome{
+		Version:    version,
+		MinVersion: protocol.MinVersion,
+		MaxVersion: protocol.Version,
+		Codec:      codec.Name(),
+		Nickname:   nickname,
+	})
 
 	ctx := context.Background()
 	_ = m.redis.Incr(ctx, "online_users").Err()
 
 	defer func() {
-		conn.Close()
-		m.clients.Delete(nickname)
+		client.Close()
+		m.hub.Unregister(nickname, client)
+		// Игрок мог уже переподключиться, в том числе к другому экземпляру:
+		// очередь, комната, вызовы и партия теперь принадлежат новой сессии
+		if !m.hub.Online(nickname) {
+			m.matchmaker.LeaveQueue(nickname)
+			m.rooms.LeaveRoom(nickname)
+			m.challenges.Disconnect(nickname)
+			m.handleClientMessage(nickname, &protocol.StopSpectating{})
+			m.handleDisconnect(nickname)
+		}
 		count, err := m.redis.Decr(ctx, "online_users").Result()
 		if err != nil {
 			logger.Warn("failed to decrement online_users:", err)
@@ -64,7 +112,9 @@ func (m *WSManager) HandleConnection(w http.ResponseWriter, r *http.Request, nic
 		logger.Info("WebSocket disconnected:", nickname)
 	}()
 
-	m.handleMessages(conn, nickname)
+	client.readPump(func(data []byte) {
+		m.handleData(nickname, codec, data)
+	})
 }
 
 func (m *WSManager) upgradeConnection(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) {
@@ -75,133 +125,210 @@ func (m *WSManager) upgradeConnection(w http.ResponseWriter, r *http.Request) (*
 	return conn, nil
 }
 
-func (m *WSManager) handleMessages(conn *websocket.Conn, nickname string) {
-	for {
-		_, data, err := conn.ReadMessage()
-		if err != nil {
-			break
-		}
+// handleData разбирает сообщение, прочитанное из соединения игрока.
+func (m *WSManager) handleData(nickname string, codec protocol.Codec, data []byte) {
+	msg, err := codec.Decode(data)
+	if err != nil {
+		m.sendError(nickname, "", err)
+		return
+	}
+	m.handleClientMessage(nickname, msg)
+}
 
-		var msg map[string]interface{}
-		if err := json.Unmarshal(data, &msg); err != nil {
-			continue
+// handleClientMessage обрабатывает сообщение из соединения игрока или
+// пересылает его экземпляру, который владеет партией, комнатой или вызовом.
+func (m *WSManager) handleClientMessage(nickname string, msg protocol.Message) {
+	if owner, remote := m.ownerOf(nickname, msg); remote {
+		m.hub.Forward(owner, nickname, msg)
+		return
+	}
+	m.handleMessage(nickname, msg)
+}
+
+// handleRouted обрабатывает сообщение, пересланное другим экземпляром.
+func (m *WSManager) handleRouted(nickname string, payload []byte) {
+	// Отключение приходит только от экземпляров, клиент его отправить не может
+	if isDisconnected(payload) {
+		m.matchmaker.HandleDisconnect(nickname)
+		return
+	}
+	msg, err := protocol.Decode(payload)
+	if err != nil {
+		logger.Warn("invalid routed message:", err)
+		return
+	}
+	m.handleMessage(nickname, msg)
+}
+
+// handleDisconnect передает отключение игрока экземпляру, который ведет
+// его партию.
+func (m *WSManager) handleDisconnect(nickname string) {
+	msg := &playerDisconnected{}
+	if owner, remote := m.ownerOf(nickname, msg); remote {
+		m.hub.Forward(owner, nickname, msg)
+		return
+	}
+	m.matchmaker.HandleDisconnect(nickname)
+}
+
+// ownerOf возвращает экземпляр, который должен обработать сообщение, если
+// это не текущий экземпляр.
+func (m *WSManager) ownerOf(nickname string, msg protocol.Message) (string, bool) {
+	owners := m.gameManager.Owners()
+	switch msg := msg.(type) {
+	case *protocol.Move, *protocol.Forfeit, *protocol.RequestRematch, *protocol.AcceptRematch,
+		*protocol.DeclineRematch, *protocol.RejoinMatch, *protocol.Resume, *protocol.ExportGame,
+		*protocol.SendChat, *protocol.SendEmote, *protocol.MuteOpponent, *playerDisconnected:
+		if _, ok := m.gameManager.GetGame(nickname); ok {
+			return "", false
 		}
-		msgType, ok := msg["type"].(string)
-		if !ok {
-			continue
+		return owners.Remote(services.OwnedGame, nickname)
+	case *protocol.Spectate:
+		target := spectateTarget(msg)
+		if _, ok := m.gameManager.GetGame(target); ok {
+			return "", false
 		}
+		return owners.Remote(services.OwnedGame, target)
+	case *protocol.StopSpectating:
+		return owners.Remote(services.OwnedSpectator, nickname)
+	case *protocol.JoinRoom:
+		return owners.Remote(services.OwnedRoom, strings.ToUpper(strings.TrimSpace(msg.Code)))
+	case *protocol.AcceptChallenge:
+		return owners.Remote(services.OwnedChallenge, msg.ChallengeID)
+	case *protocol.DeclineChallenge:
+		return owners.Remote(services.OwnedChallenge, msg.ChallengeID)
+	}
+	return "", false
+}
 
-		m.handleMessageType(conn, nickname, msgType, msg)
+// sendError отправляет игроку error с кодом. request - тип сообщения,
+// на которое это ответ.
+func (m *WSManager) sendError(nickname, request string, err error) {
+	msg := errorMessage(err)
+	if msg.Request == "" {
+		msg.Request = request
 	}
+	m.hub.Send(nickname, msg)
 }
 
-func (m *WSManager) handleMessageType(conn *websocket.Conn, nickname, msgType string, msg map[string]interface{}) {
-	switch msgType {
-	case "find_match":
-		if err := m.matchmaker.HandleFindMatch(nickname); err != nil {
+// handleMessage выполняет команду игрока и отвечает error, если она не удалась.
+func (m *WSManager) handleMessage(nickname string, msg protocol.Message) {
+	if err := m.dispatch(nickname, msg); err != nil {
+		m.sendError(nickname, msg.MessageType(), err)
+	}
+}
+
+func (m *WSManager) dispatch(nickname string, msg protocol.Message) error {
+	switch msg := msg.(type) {
+	case *protocol.FindMatch:
+		opts, tc := gameSettings(msg.GameSettings)
+		if err := m.matchmaker.HandleFindMatch(nickname, opts, tc); err != nil {
 			logger.Warn("Matchmaking error:", err)
-			_ = conn.WriteJSON(map[string]string{"type": "error", "message": err.Error()})
+			return err
 		}
-	case "find_bot_match":
-		difficulty, ok := msg["difficulty"].(string)
-		if !ok {
-			_ = conn.WriteJSON(map[string]string{"type": "error", "message": "invalid difficulty"})
-			return
+	case *protocol.FindBotMatch:
+		return m.handleFindBotMatch(nickname, msg)
+	case *protocol.CreateRoom:
+		return m.handleCreateRoom(nickname, msg)
+	case *protocol.JoinRoom:
+		if _, err := m.rooms.JoinRoom(msg.Code, nickname); err != nil {
+			return err
+		}
+	case *protocol.LeaveRoom:
+		if !m.rooms.LeaveRoom(nickname) {
+			return protocol.Errorf(protocol.CodeRoomNotFound, "no open room")
+		}
+	case *protocol.Challenge:
+		opts, tc := gameSettings(msg.GameSettings)
+		if _, err := m.challenges.Challenge(nickname, msg.Nickname, opts, tc); err != nil {
+			return err
 		}
-		m.handleFindBotMatch(conn, nickname, models.BotDifficulty(difficulty))
-	case "cancel_match":
+	case *protocol.AcceptChallenge:
+		if _, err := m.challenges.Accept(nickname, msg.ChallengeID); err != nil {
+			return err
+		}
+	case *protocol.DeclineChallenge:
+		return m.challenges.Decline(nickname, msg.ChallengeID)
+	case *protocol.CancelChallenge:
+		if !m.challenges.Cancel(nickname) {
+			return protocol.Errorf(protocol.CodeChallengeNotFound, "no pending challenge")
+		}
+	case *protocol.RefuseChallenges:
+		if err := m.challenges.SetRefuse(nickname, msg.Refuse); err != nil {
+			logger.Warn("Failed to update challenge setting:", err)
+			return protocol.Errorf(protocol.CodeInternal, "failed to update setting")
+		}
+		m.hub.Send(nickname, &protocol.ChallengeSettings{Refuse: msg.Refuse})
+	case *protocol.CancelMatch:
 		if err := m.matchmaker.HandleCancelMatch(nickname); err != nil {
 			logger.Warn("Cancel match error:", err)
-			_ = conn.WriteJSON(map[string]string{"type": "error", "message": err.Error()})
+			return err
 		}
-	case "request_rematch":
+	case *protocol.RequestRematch:
 		m.handleRequestRematch(nickname)
-	case "accept_rematch":
-		m.handleAcceptRematch(conn, nickname)
-	case "decline_rematch":
+	case *protocol.AcceptRematch:
+		return m.handleAcceptRematch(nickname)
+	case *protocol.DeclineRematch:
 		m.handleDeclineRematch(nickname)
-	case "rejoin_match":
-		m.handleRejoinMatch(conn, nickname)
-	case "forfeit":
+	case *protocol.RejoinMatch:
+		m.handleRejoinMatch(nickname)
+	case *protocol.Resume:
+		return m.handleResume(nickname, msg)
+	case *protocol.Forfeit:
 		m.handleForfeit(nickname)
-	case "move":
-		m.handleMove(conn, nickname, msg)
+	case *protocol.Move:
+		return m.handleMove(nickname, msg)
+	case *protocol.ExportGame:
+		return m.handleExportGame(nickname)
+	case *protocol.Spectate:
+		return m.handleSpectate(nickname, msg)
+	case *protocol.StopSpectating:
+		m.handleStopSpectating(nickname)
+	case *protocol.SendChat:
+		return m.handleChat(nickname, models.ChatKindText, msg.Text)
+	case *protocol.SendEmote:
+		return m.handleChat(nickname, models.ChatKindEmote, msg.Emote)
+	case *protocol.MuteOpponent:
+		return m.handleMuteOpponent(nickname, msg)
 	default:
-		logger.Warn("Unhandled message type:", msgType)
+		logger.Warn("Unhandled message type:", msg.MessageType())
 	}
+	return nil
 }
 
 func (m *WSManager) handleRequestRematch(nickname string) {
-	game, ok := m.gameManager.GetGame(nickname)
-	if !ok || !game.IsFinished {
+	opponent, ok := m.gameManager.RequestRematch(nickname, func(players []string) {
+		for _, p := range players {
+			m.hub.Send(p, &protocol.RematchDeclined{})
+		}
+		m.gameManager.FinishGame(m.redis, nickname)
+	})
+	if !ok {
 		return
 	}
-	opponent := game.PlayerO
-	if nickname == game.PlayerO {
-		opponent = game.PlayerX
-	}
-
-	if nickname == game.PlayerX {
-		game.PlayAgainX = true
-	} else {
-		game.PlayAgainO = true
-	}
-
-	if game.RematchTimer == nil {
-		game.RematchTimer = time.AfterFunc(15*time.Second, func() {
-			if !game.PlayAgainX || !game.PlayAgainO {
-				if c, ok := m.clients.Load(nickname); ok {
-					c.(*websocket.Conn).WriteJSON(map[string]interface{}{"type": "rematch_declined"})
-				}
-				if c, ok := m.clients.Load(opponent); ok {
-					c.(*websocket.Conn).WriteJSON(map[string]interface{}{"type": "rematch_declined"})
-				}
-				m.gameManager.FinishGame(m.redis, nickname)
-			}
-		})
-	}
 
-	if c, ok := m.clients.Load(opponent); ok {
-		c.(*websocket.Conn).WriteJSON(map[string]interface{}{
-			"type":     "rematch_requested",
-			"opponent": nickname,
-		})
-	}
+	m.hub.Send(opponent, &protocol.RematchRequested{Opponent: nickname})
 }
 
-func (m *WSManager) handleAcceptRematch(conn *websocket.Conn, nickname string) {
+func (m *WSManager) handleAcceptRematch(nickname string) error {
 	msg1, msg2, err := m.gameManager.HandlePlayAgain(nickname)
 	if err != nil {
 		logger.Warn("PlayAgain error:", err)
-		_ = conn.WriteJSON(map[string]string{"type": "error", "message": err.Error()})
-		return
+		return err
 	}
 	if msg1 == nil || msg2 == nil {
-		return
-	}
-	game, _ := m.gameManager.GetGame(nickname)
-	if game.RematchTimer != nil {
-		game.RematchTimer.Stop()
-		game.RematchTimer = nil
+		return nil
 	}
 
-	var selfMsg, oppMsg map[string]interface{}
-	if nickname == game.PlayerX {
-		selfMsg, oppMsg = msg1, msg2
-	} else {
+	// msg1 уходит игроку X, msg2 - игроку O; соперник указан в каждом
+	selfMsg, oppMsg := msg1, msg2
+	if msg1.Opponent == nickname {
 		selfMsg, oppMsg = msg2, msg1
 	}
 
-	if c, ok := m.clients.Load(nickname); ok {
-		c.(*websocket.Conn).WriteJSON(selfMsg)
-	}
-	opponent := game.PlayerO
-	if nickname == game.PlayerO {
-		opponent = game.PlayerX
-	}
-	if c, ok := m.clients.Load(opponent); ok {
-		c.(*websocket.Conn).WriteJSON(oppMsg)
-	}
+	m.hub.Send(nickname, selfMsg)
+	m.hub.Send(selfMsg.Opponent, oppMsg)
+	return nil
 }
 
 func (m *WSManager) handleDeclineRematch(nickname string) {
@@ -210,152 +337,339 @@ func (m *WSManager) handleDeclineRematch(nickname string) {
 		return
 	}
 	for _, p := range []string{game.PlayerX, game.PlayerO} {
-		if c, ok := m.clients.Load(p); ok {
-			c.(*websocket.Conn).WriteJSON(map[string]interface{}{
-				"type": "rematch_declined",
-			})
-		}
+		m.hub.Send(p, &protocol.RematchDeclined{})
 	}
 	m.gameManager.FinishGame(m.redis, nickname)
 }
 
-func (m *WSManager) handleRejoinMatch(conn *websocket.Conn, nickname string) {
-	game, ok := m.gameManager.GetGame(nickname)
+func (m *WSManager) handleRejoinMatch(nickname string) {
+	reconnected := m.gameManager.Reconnect(nickname)
+	state, ok := m.gameManager.GameState(nickname)
 	if !ok {
-		_ = conn.WriteJSON(map[string]string{"type": "error", "message": "no active game"})
-		conn.Close()
+		m.sendError(nickname, "rejoin_match", services.ErrNoActiveGame)
+		m.hub.Close(nickname)
 		return
 	}
-	_ = conn.WriteJSON(map[string]interface{}{
-		"type":       "game_state",
-		"board":      game.Board,
-		"turn":       game.Turn,
-		"isFinished": game.IsFinished,
-		"winner":     game.Winner,
-	})
+	m.hub.Send(nickname, state)
+	if reconnected {
+		m.announceReconnect(nickname)
+	}
 }
 
-func (m *WSManager) handleForfeit(nickname string) {
-	game, ok := m.gameManager.GetGame(nickname)
-	if ok && !game.IsFinished {
-		winner := game.PlayerO
-		if nickname == game.PlayerO {
-			winner = game.PlayerX
+// handleResume возвращает игрока в партию и досылает события после last_seq.
+// Если журнал до них уже не доходит, отправляется game_state.
+func (m *WSManager) handleResume(nickname string, msg *protocol.Resume) error {
+	events, state, err := m.gameManager.Resume(nickname, msg.GameID, msg.LastSeq)
+	if err != nil {
+		return err
+	}
+	resumed := &protocol.Resumed{GameID: msg.GameID, Seq: msg.LastSeq, Replayed: len(events)}
+	if state != nil {
+		m.hub.Send(nickname, state)
+		resumed.Seq, resumed.Replayed = state.Seq, -1
+	}
+	for _, event := range events {
+		m.hub.Send(nickname, event)
+		resumed.Seq = event.Sequence()
+	}
+	m.hub.Send(nickname, resumed)
+
+	if m.gameManager.Reconnect(nickname) {
+		m.announceReconnect(nickname)
+	}
+	return nil
+}
+
+// announceReconnect сообщает сопернику и зрителям, что игрок вернулся.
+func (m *WSManager) announceReconnect(nickname string) {
+	event := &protocol.OpponentReconnected{Nickname: nickname}
+	m.gameManager.RecordEvent(nickname, event)
+	for _, p := range m.gameManager.Recipients(nickname) {
+		if p != nickname {
+			m.hub.Send(p, event)
 		}
-		m.sendToGame(nickname, map[string]interface{}{
-			"type":   "game_over",
-			"result": winner,
-		})
-		game.IsFinished = true
-		m.gameManager.RecordGameResult(m.redis, nickname)
 	}
 }
 
-func (m *WSManager) handleMove(conn *websocket.Conn, nickname string, msg map[string]interface{}) {
-	cell, ok := intFrom(msg["cell"])
+func (m *WSManager) handleForfeit(nickname string) {
+	resultMsg, ok := m.gameManager.Forfeit(nickname, models.ReasonForfeit)
 	if !ok {
-		conn.WriteJSON(map[string]string{"type": "error", "message": "invalid cell"})
 		return
 	}
+	m.sendToGame(nickname, resultMsg)
+	m.gameManager.RecordGameResult(m.redis, nickname)
+}
+
+// handleChat передает сообщение чата или эмоцию сопернику.
+func (m *WSManager) handleChat(nickname, kind, body string) error {
+	game, ok := m.gameManager.ChatGame(nickname)
+	if !ok {
+		return services.ErrNoActiveGame
+	}
+	opponent := game.Opponent(nickname)
 
-	moveMsg, resultMsg, err := m.gameManager.HandleMove(nickname, cell)
+	body, err := m.chat.Send(game, nickname, kind, body)
 	if err != nil {
-		conn.WriteJSON(map[string]string{"type": "error", "message": err.Error()})
-		return
+		return err
 	}
 
-	game, _ := m.gameManager.GetGame(nickname)
+	var out protocol.Message = &protocol.ChatMessage{From: nickname, Text: body}
+	if kind == models.ChatKindEmote {
+		out = &protocol.EmoteMessage{From: nickname, Emote: body}
+	}
+	m.hub.Send(nickname, out)
+	if m.chat.IsMuted(opponent, nickname) {
+		return nil
+	}
+	m.hub.Send(opponent, out)
+	return nil
+}
+
+func (m *WSManager) handleMuteOpponent(nickname string, msg *protocol.MuteOpponent) error {
+	game, ok := m.gameManager.ChatGame(nickname)
+	if !ok {
+		return services.ErrNoActiveGame
+	}
+	opponent := game.Opponent(nickname)
 
-	// Для игры с ботом отправляем только игроку
-	if game.IsBotGame {
-		_ = conn.WriteJSON(moveMsg)
+	muted := !m.chat.IsMuted(nickname, opponent)
+	if msg.Muted != nil {
+		muted = *msg.Muted
+	}
+	m.chat.SetMuted(nickname, opponent, muted)
+	m.hub.Send(nickname, &protocol.OpponentMuted{Opponent: opponent, Muted: muted})
+	return nil
+}
 
-		if resultMsg != nil {
-			_ = conn.WriteJSON(resultMsg)
-			game.IsFinished = true
-			m.gameManager.RecordGameResult(m.redis, nickname)
-		} else {
-			// Проверяем ничью
-			boardFull := true
-			for _, cellVal := range game.Board {
-				if cellVal == "" {
-					boardFull = false
-					break
-				}
-			}
-			if boardFull {
-				drawMsg := map[string]interface{}{
-					"type":   "game_over",
-					"result": "draw",
-				}
-				_ = conn.WriteJSON(drawMsg)
-				game.IsFinished = true
-				m.gameManager.RecordGameResult(m.redis, nickname)
-			} else {
-				go func() {
-					time.Sleep(500 * time.Millisecond)
-					m.makeBotMove(nickname)
-				}()
-			}
-		}
-	} else {
-		// Оригинальная логика для PvP
-		m.sendToGame(nickname, moveMsg)
-		if resultMsg != nil {
-			m.sendToGame(nickname, resultMsg)
-			game.IsFinished = true
-			m.gameManager.RecordGameResult(m.redis, nickname)
-		} else {
-			boardFull := true
-			for _, cellVal := range game.Board {
-				if cellVal == "" {
-					boardFull = false
-					break
-				}
-			}
-			if boardFull {
-				drawMsg := map[string]interface{}{
-					"type":   "game_over",
-					"result": "draw",
-				}
-				m.sendToGame(nickname, drawMsg)
-				game.IsFinished = true
-				m.gameManager.RecordGameResult(m.redis, nickname)
-			}
-		}
+// spectateTarget возвращает game_id или, если он не указан, ник игрока.
+func spectateTarget(msg *protocol.Spectate) string {
+	if msg.GameID != "" {
+		return msg.GameID
 	}
+	return msg.Nickname
 }
 
-func (m *WSManager) sendToGame(sender string, msg any) {
-	game, ok := m.gameManager.GetGame(sender)
+// handleSpectate подписывает соединение на партию по game_id или нику игрока.
+func (m *WSManager) handleSpectate(nickname string, msg *protocol.Spectate) error {
+	target := spectateTarget(msg)
+	if target == "" {
+		return protocol.Errorf(protocol.CodeBadRequest, "game_id or nickname required")
+	}
+
+	state, err := m.gameManager.Spectate(nickname, target)
+	if err != nil {
+		return err
+	}
+	m.hub.Send(nickname, state)
+	m.broadcastSpectatorCount(state.PlayerX)
+	return nil
+}
+
+func (m *WSManager) handleStopSpectating(nickname string) {
+	if player, ok := m.gameManager.StopSpectating(nickname); ok {
+		m.broadcastSpectatorCount(player)
+	}
+}
+
+func (m *WSManager) broadcastSpectatorCount(player string) {
+	m.sendEvent(player, &protocol.Spectators{Count: m.gameManager.SpectatorCount(player)})
+}
+
+func (m *WSManager) handleExportGame(nickname string) error {
+	record, ok := m.gameManager.FinishedRecord(nickname)
 	if !ok {
-		return
+		return protocol.Errorf(protocol.CodeGameNotFound, "no finished game")
 	}
-	for _, p := range []string{game.PlayerX, game.PlayerO} {
-		if val, ok := m.clients.Load(p); ok {
-			conn := val.(*websocket.Conn)
-			_ = conn.WriteJSON(msg)
+	text, err := notation.Encode(notation.FromRecord(record))
+	if err != nil {
+		return protocol.Errorf(protocol.CodeInternal, "%s", err)
+	}
+	m.hub.Send(nickname, &protocol.GameExport{GameID: record.ID, Notation: text})
+	return nil
+}
+
+// handleRestored вызывается для партии, поднятой из снимка: игрокам, которые
+// уже подключены, отправляется game_state, а бот ходит, если его очередь.
+func (m *WSManager) handleRestored(game *models.Game) {
+	player := game.PlayerX
+	if game.IsBotGame && game.BotSymbol == rules.X {
+		player = game.PlayerO
+	}
+	if state, ok := m.gameManager.GameState(player); ok {
+		m.sendToGame(player, state)
+	}
+	if !game.IsFinished && m.gameManager.IsBotTurn(player) {
+		time.AfterFunc(500*time.Millisecond, func() {
+			m.makeBotMove(player)
+		})
+	}
+}
+
+// handleTimeout вызывается GameManager, когда у игрока упал флажок или
+// отключившийся игрок не вернулся вовремя.
+func (m *WSManager) handleTimeout(nickname string, resultMsg *protocol.GameOver) {
+	m.sendToGame(nickname, resultMsg)
+	m.gameManager.RecordGameResult(m.redis, nickname)
+}
+
+func (m *WSManager) handleMove(nickname string, msg *protocol.Move) error {
+	// symbol нужен только в wild, где игрок сам выбирает метку
+	moveMsg, resultMsg, err := m.gameManager.HandleMove(nickname, rules.Move{Cell: msg.Cell, Symbol: msg.Symbol}, msg.ID)
+	if errors.Is(err, services.ErrDuplicateMove) {
+		// Повтор уже сделанного хода: подтверждаем его еще раз
+		if moveMsg != nil {
+			m.hub.Send(nickname, moveMsg)
+		} else if state, ok := m.gameManager.GameState(nickname); ok {
+			m.hub.Send(nickname, state)
 		}
+		return nil
 	}
+	if err != nil {
+		return err
+	}
+
+	m.broadcastMove(nickname, moveMsg, resultMsg)
+
+	// Для игры с ботом передаем ход боту
+	if resultMsg == nil && m.gameManager.IsBotTurn(nickname) {
+		go func() {
+			time.Sleep(500 * time.Millisecond)
+			m.makeBotMove(nickname)
+		}()
+	}
+	return nil
 }
 
-func intFrom(v interface{}) (int, bool) {
-	f, ok := v.(float64)
-	return int(f), ok
+// broadcastMove рассылает результат хода участникам и, если партия
+// завершена, записывает результат.
+func (m *WSManager) broadcastMove(nickname string, moveMsg *protocol.MoveMade, resultMsg *protocol.GameOver) {
+	m.sendToGame(nickname, moveMsg)
+	if resultMsg != nil {
+		m.sendToGame(nickname, resultMsg)
+		m.gameManager.RecordGameResult(m.redis, nickname)
+	}
 }
 
-func (m *WSManager) handleFindBotMatch(conn *websocket.Conn, nickname string, difficulty models.BotDifficulty) {
-	// Проверяем валидность сложности
-	if difficulty != models.DifficultyEasy &&
-		difficulty != models.DifficultyMedium &&
-		difficulty != models.DifficultyHard {
-		_ = conn.WriteJSON(map[string]string{"type": "error", "message": "invalid difficulty"})
-		return
+// sendEvent нумерует событие партии sender и рассылает его участникам.
+func (m *WSManager) sendEvent(sender string, event protocol.Event) {
+	m.gameManager.RecordEvent(sender, event)
+	m.sendToGame(sender, event)
+}
+
+// sendToGame рассылает сообщение игрокам и зрителям партии sender.
+func (m *WSManager) sendToGame(sender string, msg protocol.Message) {
+	for _, p := range m.gameManager.Recipients(sender) {
+		m.hub.Send(p, msg)
+	}
+}
+
+// gameSettings переводит настройки партии из сообщения. Если указан только
+// размер доски, выбирается режим gomoku. Контроль времени задается в
+// секундах: {"initial": 30, "increment": 2} или {"per_move": 10}.
+func gameSettings(s protocol.GameSettings) (rules.Options, models.TimeControl) {
+	opts := rules.Options{Variant: rules.VariantClassic, Size: s.Size, WinLength: s.WinLength}
+	if s.Variant != "" {
+		opts.Variant = s.Variant
+	} else if opts.Size != 0 {
+		opts.Variant = rules.VariantGomoku
+	}
+
+	var tc models.TimeControl
+	if s.TimeControl != nil {
+		tc = models.TimeControl{
+			Initial:   time.Duration(s.TimeControl.Initial) * time.Second,
+			Increment: time.Duration(s.TimeControl.Increment) * time.Second,
+			PerMove:   time.Duration(s.TimeControl.PerMove) * time.Second,
+		}
+	}
+	return opts, tc
+}
+
+// handleCreateRoom открывает приватную комнату. Поле rated по умолчанию true,
+// symbol - "X", "O" или "random", bot занимает второе место ботом.
+func (m *WSManager) handleCreateRoom(nickname string, msg *protocol.CreateRoom) error {
+	opts, tc := gameSettings(msg.GameSettings)
+	settings := services.RoomSettings{
+		Options:     opts,
+		TimeControl: tc,
+		Rated:       true,
+	}
+	if msg.Rated != nil {
+		settings.Rated = *msg.Rated
+	}
+	if msg.Symbol != "random" {
+		settings.HostSymbol = strings.ToUpper(msg.Symbol)
+	}
+	if msg.Bot != "" {
+		settings.BotDifficulty = models.BotDifficulty(msg.Bot)
+		if !validDifficulty(settings.BotDifficulty) {
+			return errInvalidDifficulty
+		}
 	}
 
+	room, err := m.rooms.CreateRoom(nickname, settings)
+	if err != nil {
+		return err
+	}
+	if room.BotDifficulty != "" {
+		m.startBotGame(nickname, room.BotDifficulty.Bot(), room.Rules, room.HostSymbol, room.Rated)
+	}
+	return nil
+}
+
+func validDifficulty(difficulty models.BotDifficulty) bool {
+	return difficulty == models.DifficultyEasy ||
+		difficulty == models.DifficultyMedium ||
+		difficulty == models.DifficultyHard
+}
+
+// handleFindBotMatch начинает партию с ботом, выбранным по уровню сложности,
+// по рейтингу elo или по характеру. Характер без elo играет на свой
+// рейтинг. Поле rated по умолчанию true.
+func (m *WSManager) handleFindBotMatch(nickname string, msg *protocol.FindBotMatch) error {
+	var bot models.BotPlayer
+	switch {
+	case msg.Elo != 0:
+		if msg.Elo < models.MinBotElo || msg.Elo > models.MaxBotElo {
+			return errInvalidBotElo
+		}
+		bot = models.BotPlayer{Elo: msg.Elo}
+	case msg.Personality != "":
+		// Рейтинг возьмем у характера
+	case validDifficulty(models.BotDifficulty(msg.Difficulty)):
+		bot = models.BotDifficulty(msg.Difficulty).Bot()
+	default:
+		return errInvalidDifficulty
+	}
+	if msg.Personality != "" {
+		personality, ok := services.FindBotPersonality(msg.Personality)
+		if !ok {
+			return errUnknownPersonality
+		}
+		bot.Personality = personality
+		if bot.Elo == 0 {
+			bot.Elo = personality.Elo
+		}
+	}
+
+	opts, _ := gameSettings(msg.GameSettings)
+	gameRules, err := rules.New(opts)
+	if err != nil {
+		return err
+	}
+	rated := msg.Rated == nil || *msg.Rated
+	// Партия с ботом заменяет поиск соперника
+	m.matchmaker.LeaveQueue(nickname)
+	m.startBotGame(nickname, bot, gameRules, "", rated)
+	return nil
+}
+
+func (m *WSManager) startBotGame(nickname string, bot models.BotPlayer, gameRules rules.GameRules, symbol string, rated bool) {
+	opts := gameRules.Options()
+
 	// Создаем игру с ботом
-	playerSymbol := m.gameManager.CreateBotGame(nickname, difficulty)
-	botName := fmt.Sprintf("Bot_%s", difficulty)
+	playerSymbol := m.gameManager.CreateBotGame(nickname, bot, gameRules, symbol, rated)
+	game, _ := m.gameManager.GetGame(nickname)
 
 	ctx := context.Background()
 	if err := m.redis.Incr(ctx, "active_games").Err(); err != nil {
@@ -363,13 +677,24 @@ func (m *WSManager) handleFindBotMatch(conn *websocket.Conn, nickname string, di
 	}
 
 	// Отправляем подтверждение игроку
-	_ = conn.WriteJSON(map[string]interface{}{
-		"type":       "match_found",
-		"symbol":     playerSymbol,
-		"opponent":   botName,
-		"isBot":      true,
-		"difficulty": string(difficulty),
-	})
+	found := &protocol.MatchFound{
+		GameID:     game.ID,
+		Symbol:     playerSymbol,
+		Opponent:   bot.Name(),
+		Rated:      &rated,
+		IsBot:      true,
+		Difficulty: string(bot.Difficulty),
+		BotElo:     bot.Elo,
+		Board: protocol.Board{
+			Variant:   opts.Variant,
+			Size:      opts.Size,
+			WinLength: opts.WinLength,
+		},
+	}
+	if bot.Personality != nil {
+		found.Personality = bot.Personality.ID
+	}
+	m.hub.Send(nickname, found)
 
 	// Если бот ходит первым, делаем его ход
 	if playerSymbol == "O" {
@@ -380,55 +705,34 @@ func (m *WSManager) handleFindBotMatch(conn *websocket.Conn, nickname string, di
 }
 
 func (m *WSManager) makeBotMove(nickname string) {
-	game, ok := m.gameManager.GetGame(nickname)
-	if !ok || !game.IsBotGame || game.IsFinished {
+	// Позиция скопирована под блокировкой: пока бот думает, партию можно менять
+	pos, ok := m.gameManager.BotPosition(nickname)
+	if !ok {
 		return
 	}
 
+	// Имя бота общее для всех его партий, поэтому ход и сдача идут по
+	// нику игрока и id партии
 	botService := services.NewBotService()
-	cell := botService.GetBotMove(game.Board, game.BotDifficulty, game.BotSymbol)
-	if cell == -1 {
+	move, err := botService.GetBotMove(pos.Rules, pos.State, pos.Elo, pos.Personality)
+	if err != nil {
+		// Внешний движок завис, упал или сходил не по правилам: бот сдается
+		logger.Warn("Bot engine failed in game ", pos.GameID, ": ", err)
+		if resultMsg, ok := m.gameManager.ForfeitBot(nickname, pos.GameID, models.ReasonForfeit); ok {
+			m.sendToGame(nickname, resultMsg)
+			m.gameManager.RecordGameResult(m.redis, nickname)
+		}
 		return
 	}
-
-	// Определяем имя бота (тот игрок, чей символ == BotSymbol)
-	botName := game.PlayerX
-	if game.BotSymbol == "O" {
-		// если бот O, то бот находится в PlayerO
-		botName = game.PlayerO
+	if move.Cell == -1 {
+		return
 	}
 
-	// Выполняем ход как бот (передаём имя бота)
-	moveMsg, resultMsg, err := m.gameManager.HandleMove(botName, cell)
+	moveMsg, resultMsg, err := m.gameManager.HandleBotMove(nickname, pos.GameID, move)
 	if err != nil {
 		logger.Warn("Bot move error:", err)
 		return
 	}
 
-	// Отправляем ответ игроку (и потенциально всем участникам игры)
-	// Используем общую sendToGame, чтобы рассылка была единообразной.
-	m.sendToGame(botName, moveMsg)
-	if resultMsg != nil {
-		m.sendToGame(botName, resultMsg)
-		game.IsFinished = true
-		m.gameManager.RecordGameResult(m.redis, nickname)
-	} else {
-		// проверяем ничью
-		boardFull := true
-		for _, cellVal := range game.Board {
-			if cellVal == "" {
-				boardFull = false
-				break
-			}
-		}
-		if boardFull {
-			drawMsg := map[string]interface{}{
-				"type":   "game_over",
-				"result": "draw",
-			}
-			m.sendToGame(botName, drawMsg)
-			game.IsFinished = true
-			m.gameManager.RecordGameResult(m.redis, nickname)
-		}
-	}
+	m.broadcastMove(nickname, moveMsg, resultMsg)
 }
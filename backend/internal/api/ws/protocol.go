@@ -0,0 +1,102 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/protocol"
+	"tictactoe/internal/rules"
+	"tictactoe/internal/services"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	errInvalidDifficulty = protocol.Errorf(protocol.CodeInvalidSettings, "invalid difficulty")
+	errInvalidBotElo     = protocol.Errorf(protocol.CodeInvalidSettings,
+		"bot elo must be between %d and %d", models.MinBotElo, models.MaxBotElo)
+	errUnknownPersonality = protocol.Errorf(protocol.CodeInvalidSettings, "unknown bot personality")
+)
+
+// errorCodes сопоставляет ошибки сервисов кодам протокола.
+var errorCodes = []struct {
+	err  error
+	code protocol.ErrorCode
+}{
+	{rules.ErrInvalidMove, protocol.CodeInvalidMove},
+	{rules.ErrInvalidOptions, protocol.CodeInvalidSettings},
+	{services.ErrInvalidTimeControl, protocol.CodeInvalidSettings},
+	{services.ErrBotTimeControl, protocol.CodeInvalidSettings},
+	{services.ErrNoActiveGame, protocol.CodeNoActiveGame},
+	{services.ErrNotAPlayer, protocol.CodeNoActiveGame},
+	{services.ErrNotYourTurn, protocol.CodeNotYourTurn},
+	{services.ErrTimeUp, protocol.CodeTimeUp},
+	{services.ErrInGame, protocol.CodeInGame},
+	{services.ErrGameNotFound, protocol.CodeGameNotFound},
+	{services.ErrAlreadyQueued, protocol.CodeAlreadyQueued},
+	{services.ErrNotQueued, protocol.CodeNotQueued},
+	{services.ErrRoomNotFound, protocol.CodeRoomNotFound},
+	{services.ErrAlreadyHost, protocol.CodeAlreadyHosting},
+	{services.ErrChallengeNotFound, protocol.CodeChallengeNotFound},
+	{services.ErrPlayerOffline, protocol.CodePlayerOffline},
+	{services.ErrPlayerBusy, protocol.CodePlayerBusy},
+	{services.ErrRateLimited, protocol.CodeRateLimited},
+}
+
+// errorMessage превращает ошибку в сообщение error. Ошибки без известного
+// кода получают CodeRejected.
+func errorMessage(err error) *protocol.Error {
+	var protoErr *protocol.Error
+	if errors.As(err, &protoErr) {
+		copied := *protoErr
+		return &copied
+	}
+	code := protocol.CodeRejected
+	for _, known := range errorCodes {
+		if errors.Is(err, known.err) {
+			code = known.code
+			break
+		}
+	}
+	return &protocol.Error{Code: code, Message: err.Error()}
+}
+
+// negotiateVersion возвращает версию протокола и кодек соединения. Клиент
+// без подпротокола получает текущую версию в JSON; false означает, что ни
+// один из предложенных подпротоколов не поддерживается.
+func negotiateVersion(r *http.Request, conn *websocket.Conn) (int, protocol.Codec, bool) {
+	if version, codec, ok := protocol.ParseSubprotocol(conn.Subprotocol()); ok {
+		return version, codec, true
+	}
+	return protocol.Version, protocol.JSON, len(websocket.Subprotocols(r)) == 0
+}
+
+// rejectVersion сообщает клиенту о неподдерживаемой версии и закрывает соединение.
+func rejectVersion(conn *websocket.Conn) {
+	defer conn.Close()
+	payload, err := protocol.Encode(protocol.Errorf(protocol.CodeUnsupportedVersion,
+		"supported protocol versions: %d to %d", protocol.MinVersion, protocol.Version))
+	if err != nil {
+		return
+	}
+	_ = conn.WriteMessage(websocket.TextMessage, payload)
+	_ = conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseProtocolError, "unsupported protocol version"),
+		time.Now().Add(time.Second))
+}
+
+// playerDisconnected - служебная команда, которой экземпляр с соединением
+// сообщает владельцу партии, что игрок отключился. В протокол клиента не входит.
+type playerDisconnected struct{}
+
+func (*playerDisconnected) MessageType() string { return "disconnected" }
+
+func isDisconnected(payload []byte) bool {
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal(payload, &envelope) == nil && envelope.Type == (&playerDisconnected{}).MessageType()
+}
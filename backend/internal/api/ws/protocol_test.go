@@ -0,0 +1,39 @@
+package ws
+
+import (
+	"fmt"
+	"testing"
+
+	"tictactoe/internal/protocol"
+	"tictactoe/internal/rules"
+	"tictactoe/internal/services"
+)
+
+func TestErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  error
+		code protocol.ErrorCode
+	}{
+		{rules.ErrInvalidMove, protocol.CodeInvalidMove},
+		{fmt.Errorf("%w: cell 9", rules.ErrInvalidMove), protocol.CodeInvalidMove},
+		{services.ErrNotYourTurn, protocol.CodeNotYourTurn},
+		{services.ErrRoomNotFound, protocol.CodeRoomNotFound},
+		{errInvalidDifficulty, protocol.CodeInvalidSettings},
+		{fmt.Errorf("something else"), protocol.CodeRejected},
+	}
+	for _, tt := range tests {
+		msg := errorMessage(tt.err)
+		if msg.Code != tt.code {
+			t.Errorf("errorMessage(%v).Code = %s, want %s", tt.err, msg.Code, tt.code)
+		}
+		if msg.Message != tt.err.Error() {
+			t.Errorf("errorMessage(%v).Message = %q", tt.err, msg.Message)
+		}
+	}
+
+	// Protocol errors are copied so that setting Request does not touch the original.
+	errorMessage(errInvalidDifficulty).Request = "find_bot_match"
+	if errInvalidDifficulty.Request != "" {
+		t.Error("errorMessage modified a shared error")
+	}
+}
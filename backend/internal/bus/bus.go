@@ -0,0 +1,23 @@
+// Package bus доставляет сообщения между экземплярами сервера.
+//
+// Каждый экземпляр подписывается на свои каналы (по одному на подключенного
+// игрока и один для команд, пересланных экземпляру) и публикует в каналы
+// игроков, подключенных к другим экземплярам. В работе используется
+// RedisBus; LocalBus держит все в одном процессе для тестов и запуска
+// в одном экземпляре.
+package bus
+
+import "context"
+
+// Handler получает содержимое опубликованного сообщения.
+type Handler func(payload []byte)
+
+// Bus - транспорт публикации и подписки. Сообщения одного канала доходят
+// до каждого подписчика в порядке публикации.
+type Bus interface {
+	Publish(ctx context.Context, channel string, payload []byte) error
+	// Subscribe подписывает handler на channel. Возвращенная функция
+	// снимает подписку.
+	Subscribe(channel string, handler Handler) (unsubscribe func(), err error)
+	Close() error
+}
@@ -0,0 +1,104 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// localQueueSize - сколько недоставленных сообщений может ждать подписчика
+const localQueueSize = 256
+
+var ErrClosed = errors.New("bus closed")
+
+// LocalBus - Bus внутри процесса. У каждого подписчика своя горутина, и
+// обработчики вызываются асинхронно, как с Redis.
+type LocalBus struct {
+	mu     sync.Mutex
+	subs   map[string]map[*localSub]struct{}
+	closed bool
+}
+
+type localSub struct {
+	queue chan []byte
+	done  chan struct{}
+	once  sync.Once
+}
+
+func NewLocalBus() *LocalBus {
+	return &LocalBus{subs: make(map[string]map[*localSub]struct{})}
+}
+
+func (b *LocalBus) Publish(ctx context.Context, channel string, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+	for sub := range b.subs[channel] {
+		select {
+		case sub.queue <- payload:
+		case <-sub.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (b *LocalBus) Subscribe(channel string, handler Handler) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+	sub := &localSub{
+		queue: make(chan []byte, localQueueSize),
+		done:  make(chan struct{}),
+	}
+	if b.subs[channel] == nil {
+		b.subs[channel] = make(map[*localSub]struct{})
+	}
+	b.subs[channel][sub] = struct{}{}
+
+	go func() {
+		for {
+			select {
+			case payload := <-sub.queue:
+				handler(payload)
+			case <-sub.done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		b.mu.Lock()
+		delete(b.subs[channel], sub)
+		if len(b.subs[channel]) == 0 {
+			delete(b.subs, channel)
+		}
+		b.mu.Unlock()
+		sub.stop()
+	}, nil
+}
+
+func (b *LocalBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for channel, subs := range b.subs {
+		for sub := range subs {
+			sub.stop()
+		}
+		delete(b.subs, channel)
+	}
+	return nil
+}
+
+func (s *localSub) stop() {
+	s.once.Do(func() { close(s.done) })
+}
@@ -0,0 +1,60 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLocalBusDeliversInOrder(t *testing.T) {
+	b := NewLocalBus()
+	defer b.Close()
+
+	got := make(chan string, 10)
+	unsubscribe, err := b.Subscribe("player:alice", func(payload []byte) {
+		got <- string(payload)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		if err := b.Publish(ctx, "player:alice", []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = b.Publish(ctx, "player:bob", []byte("not for alice"))
+
+	for i := 0; i < 5; i++ {
+		select {
+		case msg := <-got:
+			if msg != fmt.Sprint(i) {
+				t.Fatalf("message %d: got %q", i, msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("message %d was not delivered", i)
+		}
+	}
+
+	unsubscribe()
+	_ = b.Publish(ctx, "player:alice", []byte("late"))
+	select {
+	case msg := <-got:
+		t.Fatalf("received %q after unsubscribe", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestLocalBusClosed(t *testing.T) {
+	b := NewLocalBus()
+	_ = b.Close()
+
+	if err := b.Publish(context.Background(), "x", nil); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	if _, err := b.Subscribe("x", func([]byte) {}); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package bus
+
+import (
+	"context"
+	"sync"
+
+	"tictactoe/internal/logger"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBus реализует Bus на pub/sub Redis. Все подписки экземпляра
+// используют одно соединение с Redis.
+type RedisBus struct {
+	rdb    *redis.Client
+	pubsub *redis.PubSub
+
+	mu       sync.RWMutex
+	handlers map[string]map[*Handler]struct{}
+}
+
+func NewRedisBus(rdb *redis.Client) *RedisBus {
+	b := &RedisBus{
+		rdb:      rdb,
+		pubsub:   rdb.Subscribe(context.Background()),
+		handlers: make(map[string]map[*Handler]struct{}),
+	}
+	go b.run()
+	return b
+}
+
+func (b *RedisBus) Publish(ctx context.Context, channel string, payload []byte) error {
+	return b.rdb.Publish(ctx, channel, payload).Err()
+}
+
+func (b *RedisBus) Subscribe(channel string, handler Handler) (func(), error) {
+	h := &handler
+
+	b.mu.Lock()
+	first := len(b.handlers[channel]) == 0
+	if first {
+		b.handlers[channel] = make(map[*Handler]struct{})
+	}
+	b.handlers[channel][h] = struct{}{}
+	b.mu.Unlock()
+
+	if first {
+		if err := b.pubsub.Subscribe(context.Background(), channel); err != nil {
+			b.remove(channel, h)
+			return nil, err
+		}
+	}
+	return func() { b.remove(channel, h) }, nil
+}
+
+func (b *RedisBus) remove(channel string, h *Handler) {
+	b.mu.Lock()
+	delete(b.handlers[channel], h)
+	last := len(b.handlers[channel]) == 0
+	if last {
+		delete(b.handlers, channel)
+	}
+	b.mu.Unlock()
+
+	if last {
+		if err := b.pubsub.Unsubscribe(context.Background(), channel); err != nil {
+			logger.Warn("bus: unsubscribe failed:", err)
+		}
+	}
+}
+
+// run раздает входящие сообщения. Обработчики вызываются по одному, поэтому
+// сообщения канала идут в порядке публикации.
+func (b *RedisBus) run() {
+	for msg := range b.pubsub.Channel() {
+		b.mu.RLock()
+		handlers := make([]Handler, 0, len(b.handlers[msg.Channel]))
+		for h := range b.handlers[msg.Channel] {
+			handlers = append(handlers, *h)
+		}
+		b.mu.RUnlock()
+
+		for _, h := range handlers {
+			h([]byte(msg.Payload))
+		}
+	}
+}
+
+func (b *RedisBus) Close() error {
+	return b.pubsub.Close()
+}
@@ -1,5 +1,7 @@
 package models
 
+import "fmt"
+
 type BotDifficulty string
 
 const (
@@ -8,7 +10,60 @@ const (
 	DifficultyHard   BotDifficulty = "hard"
 )
 
+// Диапазон рейтингов ботов. Бот с MinBotElo ходит случайно, с MaxBotElo -
+// лучшим найденным ходом.
+const (
+	MinBotElo = 800
+	MaxBotElo = 1800
+)
+
+// Elo - рейтинг бота уровня сложности, 0 для неизвестного уровня.
+func (d BotDifficulty) Elo() int {
+	switch d {
+	case DifficultyEasy:
+		return MinBotElo
+	case DifficultyMedium:
+		return 1200
+	case DifficultyHard:
+		return MaxBotElo
+	}
+	return 0
+}
+
+// BotPersonality - характер бота: стиль игры, дебютная книга и то, как
+// бот выглядит в игре. Elo - сила бота, если игрок не выбрал другую.
+type BotPersonality struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Avatar      string `json:"avatar"`
+	Skin        string `json:"skin"`
+	Description string `json:"description"`
+	Style       string `json:"style"`
+	Elo         int    `json:"elo"`
+}
+
+// BotPlayer - соперник-бот. Difficulty задан, если бот выбран по уровню
+// сложности, Personality - если выбран характер; играет бот всегда на
+// рейтинг Elo.
 type BotPlayer struct {
-	Difficulty BotDifficulty
-	Symbol     string
+	Difficulty  BotDifficulty
+	Elo         int
+	Personality *BotPersonality
+}
+
+// Bot возвращает бота уровня сложности.
+func (d BotDifficulty) Bot() BotPlayer {
+	return BotPlayer{Difficulty: d, Elo: d.Elo()}
+}
+
+// Name - имя бота в партии: Bot_Blaze для характера, Bot_easy для уровня
+// сложности, Bot_1200 для рейтинга.
+func (b BotPlayer) Name() string {
+	switch {
+	case b.Personality != nil:
+		return "Bot_" + b.Personality.Name
+	case b.Difficulty != "":
+		return fmt.Sprintf("Bot_%s", b.Difficulty)
+	}
+	return fmt.Sprintf("Bot_%d", b.Elo)
 }
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"tictactoe/internal/rules"
+)
+
+// Challenge - вызов конкретного игрока на партию.
+type Challenge struct {
+	ID          string
+	From        string
+	To          string
+	Rules       rules.GameRules
+	TimeControl TimeControl
+	CreatedAt   time.Time
+	ExpireTimer *time.Timer
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+const (
+	ChatKindText  = "chat"
+	ChatKindEmote = "emote"
+)
+
+// ChatMessage - сообщение чата партии. Партия определяется игроками и
+// временем ее начала, чтобы жалобы могли ссылаться на переписку.
+type ChatMessage struct {
+	ID            int       `json:"id"`
+	PlayerX       string    `json:"player_x"`
+	PlayerO       string    `json:"player_o"`
+	GameStartedAt time.Time `json:"game_started_at"`
+	Sender        string    `json:"sender"`
+	Kind          string    `json:"kind"`
+	Body          string    `json:"body"`
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -1,12 +1,30 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"tictactoe/internal/rules"
+)
+
+// TimeControl задает контроль времени партии: либо часы с добавлением
+// (Initial + Increment), либо лимит на каждый ход (PerMove).
+// Нулевое значение означает игру без часов.
+type TimeControl struct {
+	Initial   time.Duration
+	Increment time.Duration
+	PerMove   time.Duration
+}
+
+func (tc TimeControl) Enabled() bool {
+	return tc.Initial > 0 || tc.PerMove > 0
+}
 
 type Game struct {
+	ID            string
 	PlayerX       string
 	PlayerO       string
-	Turn          string
-	Board         [9]string
+	Rules         rules.GameRules
+	State         *rules.State
 	IsFinished    bool
 	Winner        string
 	PlayAgainX    bool
@@ -14,7 +32,55 @@ type Game struct {
 	RematchTimer  *time.Timer
 	IsBotGame     bool
 	BotDifficulty BotDifficulty
-	BotSymbol     string
-	LastActivity  time.Time
-	StatsRecorded bool
+	// BotElo - рейтинг, на который играет бот
+	BotElo int
+	// BotPersonality - id характера бота или пустая строка
+	BotPersonality string
+	BotSymbol      string
+	LastActivity   time.Time
+	StatsRecorded  bool
+	StartedAt      time.Time
+	Moves          []GameMove
+	EndReason      string
+	RecordID       int
+	TimeControl    TimeControl
+	ClockX         time.Duration
+	ClockO         time.Duration
+	TurnStarted    time.Time
+	ClockTimer     *time.Timer
+	Spectators     map[string]struct{}
+	// Unrated - партия не меняет Elo (например, в приватной комнате)
+	Unrated bool
+	// Disconnected - игроки, потерявшие соединение во время партии
+	Disconnected map[string]*Absence
+	// Seq - номер последнего события партии. Events - последние события
+	// для resume, MoveIDs - id ходов клиента -> номер их move_made.
+	Seq     int64
+	Events  []Event
+	MoveIDs map[string]int64
+}
+
+// Event - событие в журнале партии. Модель не знает формата сообщений:
+// журнал ведет и читает services, события в нем - сообщения протокола.
+type Event interface {
+	Sequence() int64
+}
+
+// Absence - отключение игрока: до Deadline он может вернуться через
+// rejoin_match, после срабатывания Timer ему засчитывается поражение.
+type Absence struct {
+	Deadline time.Time
+	Timer    *time.Timer
+}
+
+// LiveGame - идущая партия в списке для зрителей.
+type LiveGame struct {
+	ID         string    `json:"id"`
+	PlayerX    string    `json:"player_x"`
+	PlayerO    string    `json:"player_o"`
+	Variant    string    `json:"variant"`
+	MoveCount  int       `json:"move_count"`
+	Spectators int       `json:"spectators"`
+	IsBot      bool      `json:"is_bot"`
+	StartedAt  time.Time `json:"started_at"`
 }
@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// GameMove - ход завершенной партии с серверным временем.
+type GameMove struct {
+	Ply      int       `json:"ply"`
+	Seat     string    `json:"seat"`
+	Cell     int       `json:"cell"`
+	Symbol   string    `json:"symbol"`
+	PlayedAt time.Time `json:"played_at"`
+}
+
+// GameRecord - завершенная партия в том виде, в котором она хранится в БД.
+// Поля Elo пустые для игр с ботом.
+type GameRecord struct {
+	ID            int        `json:"id"`
+	PlayerX       string     `json:"player_x"`
+	PlayerO       string     `json:"player_o"`
+	Variant       string     `json:"variant"`
+	Size          int        `json:"size"`
+	WinLength     int        `json:"win_length"`
+	Mode          string     `json:"mode"`
+	BotDifficulty string     `json:"bot_difficulty,omitempty"`
+	TimeControl   string     `json:"time_control,omitempty"`
+	Result        string     `json:"result"`
+	Reason        string     `json:"reason"`
+	EloXBefore    *int       `json:"elo_x_before"`
+	EloXAfter     *int       `json:"elo_x_after"`
+	EloOBefore    *int       `json:"elo_o_before"`
+	EloOAfter     *int       `json:"elo_o_after"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    time.Time  `json:"finished_at"`
+	Moves         []GameMove `json:"moves,omitempty"`
+}
+
+const (
+	GameModePvP = "pvp"
+	GameModeBot = "bot"
+)
+
+// Причины завершения партии
+const (
+	ReasonLine      = "line"
+	ReasonDraw      = "draw"
+	ReasonTimeout   = "timeout"
+	ReasonForfeit   = "forfeit"
+	ReasonAbandoned = "abandoned"
+)
+
+// GameHistoryEntry - партия с точки зрения одного из игроков.
+type GameHistoryEntry struct {
+	ID              int       `json:"id"`
+	Opponent        string    `json:"opponent"`
+	Symbol          string    `json:"symbol"`
+	Result          string    `json:"result"`
+	Reason          string    `json:"reason"`
+	EloDelta        *int      `json:"elo_delta"`
+	DurationSeconds int       `json:"duration_seconds"`
+	Variant         string    `json:"variant"`
+	Mode            string    `json:"mode"`
+	FinishedAt      time.Time `json:"finished_at"`
+}
+
+type GameHistoryPage struct {
+	Games      []GameHistoryEntry `json:"games"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
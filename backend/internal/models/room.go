@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"tictactoe/internal/rules"
+)
+
+// Room - приватное лобби, в которое второй игрок заходит по коду.
+type Room struct {
+	Code        string
+	Host        string
+	Guest       string
+	Rules       rules.GameRules
+	TimeControl TimeControl
+	Rated       bool
+	// HostSymbol - "X", "O" или пустая строка для случайного выбора
+	HostSymbol    string
+	BotDifficulty BotDifficulty
+	CreatedAt     time.Time
+	ExpireTimer   *time.Timer
+}
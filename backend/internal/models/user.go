@@ -8,6 +8,8 @@ type User struct {
 	Losses       int    `json:"losses"`
 	Draws        int    `json:"draws"`
 	EloRating    int    `json:"elo_rating"`
+	// BotEloRating - отдельный рейтинг в партиях с ботами
+	BotEloRating int `json:"bot_elo_rating"`
 }
 
 type LeaderboardEntry struct {
@@ -0,0 +1,384 @@
+// Package notation читает и записывает партии в текстовом формате наподобие PGN:
+//
+//	[Date "2025.01.15"]
+//	[X "alice"]
+//	[O "bob"]
+//	[Variant "classic"]
+//	[Size "3"]
+//	[WinLength "3"]
+//	[TimeControl "30+2"]
+//	[Result "1-0"]
+//	[Termination "line"]
+//
+//	1. a1 b1 2. a2 b2 3. a3 1-0
+//
+// Клетка называется буквой столбца (a - левый столбец) и номером строки
+// (1 - верхняя строка). В ultimate используются координаты всего поля 9x9.
+// В wild к каждому ходу добавляется поставленный знак: b2=O.
+package notation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/rules"
+)
+
+const dateLayout = "2006.01.02"
+
+// Game - партия в виде нотации. Result - X, O, rules.Draw или "" для
+// незаконченной партии.
+type Game struct {
+	X           string
+	O           string
+	Date        time.Time
+	Options     rules.Options
+	TimeControl string
+	Result      string
+	Reason      string
+	Moves       []rules.Move
+	// Extra - теги без отдельного поля в порядке файла
+	Extra []Tag
+}
+
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// FromRecord переводит сохраненную партию в нотацию.
+func FromRecord(record *models.GameRecord) *Game {
+	g := &Game{
+		X:    record.PlayerX,
+		O:    record.PlayerO,
+		Date: record.StartedAt,
+		Options: rules.Options{
+			Variant:   record.Variant,
+			Size:      record.Size,
+			WinLength: record.WinLength,
+		},
+		TimeControl: record.TimeControl,
+		Result:      record.Result,
+		Reason:      record.Reason,
+	}
+	if record.Mode == models.GameModeBot {
+		g.Extra = append(g.Extra, Tag{Name: "Mode", Value: record.Mode}, Tag{Name: "BotDifficulty", Value: record.BotDifficulty})
+	}
+	for _, move := range record.Moves {
+		g.Moves = append(g.Moves, rules.Move{Cell: move.Cell, Symbol: move.Symbol})
+	}
+	return g
+}
+
+// Encode записывает партию в нотации.
+func Encode(g *Game) (string, error) {
+	gameRules, err := rules.New(g.Options)
+	if err != nil {
+		return "", err
+	}
+	opts := gameRules.Options()
+	wild := opts.Variant == rules.VariantWild
+
+	var b strings.Builder
+	writeTag(&b, "Date", formatDate(g.Date))
+	writeTag(&b, "X", g.X)
+	writeTag(&b, "O", g.O)
+	writeTag(&b, "Variant", opts.Variant)
+	writeTag(&b, "Size", strconv.Itoa(opts.Size))
+	writeTag(&b, "WinLength", strconv.Itoa(opts.WinLength))
+	if g.TimeControl != "" {
+		writeTag(&b, "TimeControl", g.TimeControl)
+	}
+	writeTag(&b, "Result", resultToken(g.Result))
+	if g.Reason != "" {
+		writeTag(&b, "Termination", g.Reason)
+	}
+	for _, tag := range g.Extra {
+		writeTag(&b, tag.Name, tag.Value)
+	}
+	b.WriteString("\n")
+
+	lineLen := 0
+	write := func(token string) {
+		if lineLen > 0 && lineLen+1+len(token) > 80 {
+			b.WriteString("\n")
+			lineLen = 0
+		} else if lineLen > 0 {
+			b.WriteString(" ")
+			lineLen++
+		}
+		b.WriteString(token)
+		lineLen += len(token)
+	}
+
+	for i, move := range g.Moves {
+		square, err := squareName(opts, move.Cell)
+		if err != nil {
+			return "", fmt.Errorf("move %d: %w", i+1, err)
+		}
+		if wild {
+			square += "=" + move.Symbol
+		}
+		if i%2 == 0 {
+			square = strconv.Itoa(i/2+1) + ". " + square
+		}
+		write(square)
+	}
+	write(resultToken(g.Result))
+	b.WriteString("\n")
+
+	return b.String(), nil
+}
+
+// Parse читает партию в нотации. Проверяется только синтаксис; ходы по
+// правилам проверяет Replay.
+func Parse(text string) (*Game, error) {
+	g := &Game{Options: rules.Options{Variant: rules.VariantClassic}}
+	var movetext []string
+	resultTag := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "[") {
+			if line != "" {
+				movetext = append(movetext, line)
+			}
+			continue
+		}
+		if len(movetext) > 0 {
+			return nil, fmt.Errorf("line %d: tag after moves", lineNo)
+		}
+
+		name, value, err := parseTag(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		switch name {
+		case "Date":
+			if value != "" && !strings.Contains(value, "?") {
+				if g.Date, err = time.Parse(dateLayout, value); err != nil {
+					return nil, fmt.Errorf("line %d: invalid date %q", lineNo, value)
+				}
+			}
+		case "X":
+			g.X = value
+		case "O":
+			g.O = value
+		case "Variant":
+			g.Options.Variant = value
+		case "Size", "WinLength":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s %q", lineNo, name, value)
+			}
+			if name == "Size" {
+				g.Options.Size = n
+			} else {
+				g.Options.WinLength = n
+			}
+		case "TimeControl":
+			g.TimeControl = value
+		case "Result":
+			resultTag = value
+		case "Termination":
+			g.Reason = value
+		default:
+			g.Extra = append(g.Extra, Tag{Name: name, Value: value})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	gameRules, err := rules.New(g.Options)
+	if err != nil {
+		return nil, err
+	}
+	opts := gameRules.Options()
+	g.Options = opts
+
+	result, ok := parseResult(resultTag)
+	if resultTag != "" && !ok {
+		return nil, fmt.Errorf("invalid result %q", resultTag)
+	}
+	g.Result = result
+
+	for _, token := range strings.Fields(strings.Join(movetext, " ")) {
+		token = stripMoveNumber(token)
+		if token == "" {
+			continue
+		}
+		if result, ok := parseResult(token); ok {
+			if resultTag != "" && result != g.Result {
+				return nil, fmt.Errorf("result %q does not match the Result tag", token)
+			}
+			g.Result = result
+			continue
+		}
+
+		move, err := parseMove(opts, token)
+		if err != nil {
+			return nil, fmt.Errorf("move %d: %w", len(g.Moves)+1, err)
+		}
+		g.Moves = append(g.Moves, move)
+	}
+
+	return g, nil
+}
+
+// Replay проигрывает ходы по правилам варианта партии и проверяет, что
+// результат совпадает с итоговой позицией.
+func Replay(g *Game) (*rules.State, rules.Outcome, error) {
+	gameRules, err := rules.New(g.Options)
+	if err != nil {
+		return nil, rules.Outcome{}, err
+	}
+
+	state := gameRules.NewState()
+	outcome := gameRules.Outcome(state)
+	for i, move := range g.Moves {
+		if outcome.Finished {
+			return nil, outcome, fmt.Errorf("move %d: game is already over", i+1)
+		}
+		if err := gameRules.Apply(state, move); err != nil {
+			return nil, outcome, fmt.Errorf("move %d: %w", i+1, err)
+		}
+		outcome = gameRules.Outcome(state)
+	}
+
+	switch {
+	case outcome.Finished && g.Result != "" && g.Result != outcome.Winner:
+		return nil, outcome, errors.New("result does not match the final position")
+	case !outcome.Finished && g.Result != "" && (g.Reason == "" || g.Reason == models.ReasonLine || g.Reason == models.ReasonDraw):
+		return nil, outcome, errors.New("game is not over on the board")
+	}
+	return state, outcome, nil
+}
+
+func writeTag(b *strings.Builder, name, value string) {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	fmt.Fprintf(b, "[%s \"%s\"]\n", name, value)
+}
+
+func parseTag(line string) (string, string, error) {
+	if !strings.HasSuffix(line, "]") {
+		return "", "", errors.New("unterminated tag")
+	}
+	body := strings.TrimSpace(line[1 : len(line)-1])
+	name, quoted, ok := strings.Cut(body, " ")
+	if !ok || name == "" {
+		return "", "", errors.New("malformed tag")
+	}
+	value, err := strconv.Unquote(strings.TrimSpace(quoted))
+	if err != nil {
+		return "", "", fmt.Errorf("malformed value for tag %s", name)
+	}
+	return name, value, nil
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "????.??.??"
+	}
+	return t.Format(dateLayout)
+}
+
+func resultToken(result string) string {
+	switch result {
+	case rules.X:
+		return "1-0"
+	case rules.O:
+		return "0-1"
+	case rules.Draw:
+		return "1/2-1/2"
+	}
+	return "*"
+}
+
+func parseResult(token string) (string, bool) {
+	switch token {
+	case "1-0":
+		return rules.X, true
+	case "0-1":
+		return rules.O, true
+	case "1/2-1/2":
+		return rules.Draw, true
+	case "*":
+		return "", true
+	}
+	return "", false
+}
+
+// stripMoveNumber убирает номер хода вида "12." в начале токена.
+func stripMoveNumber(token string) string {
+	i := 0
+	for i < len(token) && token[i] >= '0' && token[i] <= '9' {
+		i++
+	}
+	if i > 0 && i < len(token) && token[i] == '.' {
+		return strings.TrimLeft(token[i:], ".")
+	}
+	return token
+}
+
+func parseMove(opts rules.Options, token string) (rules.Move, error) {
+	square, symbol, hasSymbol := strings.Cut(token, "=")
+	wild := opts.Variant == rules.VariantWild
+	if wild != hasSymbol {
+		if wild {
+			return rules.Move{}, fmt.Errorf("%q: wild moves must name the mark, e.g. b2=O", token)
+		}
+		return rules.Move{}, fmt.Errorf("%q: only wild moves name the mark", token)
+	}
+	if hasSymbol && symbol != rules.X && symbol != rules.O {
+		return rules.Move{}, fmt.Errorf("%q: invalid mark", token)
+	}
+
+	cell, err := parseSquare(opts, square)
+	if err != nil {
+		return rules.Move{}, err
+	}
+	return rules.Move{Cell: cell, Symbol: symbol}, nil
+}
+
+func squareName(opts rules.Options, cell int) (string, error) {
+	if cell < 0 || cell >= opts.Size*opts.Size {
+		return "", fmt.Errorf("cell %d is off the board", cell)
+	}
+	row, col := cell/opts.Size, cell%opts.Size
+	if opts.Variant == rules.VariantUltimate {
+		board, local := cell/9, cell%9
+		row = board/3*3 + local/3
+		col = board%3*3 + local%3
+	}
+	return string(rune('a'+col)) + strconv.Itoa(row+1), nil
+}
+
+func parseSquare(opts rules.Options, square string) (int, error) {
+	if len(square) < 2 || square[0] < 'a' || square[0] > 'z' {
+		return 0, fmt.Errorf("invalid square %q", square)
+	}
+	col := int(square[0] - 'a')
+	row, err := strconv.Atoi(square[1:])
+	if err != nil {
+		return 0, fmt.Errorf("invalid square %q", square)
+	}
+	row--
+	if row < 0 || row >= opts.Size || col >= opts.Size {
+		return 0, fmt.Errorf("square %q is off the board", square)
+	}
+
+	if opts.Variant == rules.VariantUltimate {
+		board := row/3*3 + col/3
+		local := row%3*3 + col%3
+		return board*9 + local, nil
+	}
+	return row*opts.Size + col, nil
+}
@@ -0,0 +1,152 @@
+package notation
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"tictactoe/internal/rules"
+)
+
+// TestRoundTrip plays random games in every variant, writes them out, reads
+// them back and replays them.
+func TestRoundTrip(t *testing.T) {
+	variants := []rules.Options{
+		{Variant: rules.VariantClassic},
+		{Variant: rules.VariantGomoku, Size: 4, WinLength: 4},
+		{Variant: rules.VariantGomoku, Size: 15, WinLength: 5},
+		{Variant: rules.VariantUltimate},
+		{Variant: rules.VariantMisere},
+		{Variant: rules.VariantWild},
+	}
+	r := rand.New(rand.NewSource(1))
+
+	for _, opts := range variants {
+		t.Run(opts.Key(), func(t *testing.T) {
+			for i := 0; i < 50; i++ {
+				original := randomGame(t, r, opts)
+
+				text, err := Encode(original)
+				if err != nil {
+					t.Fatal(err)
+				}
+				parsed, err := Parse(text)
+				if err != nil {
+					t.Fatalf("parse: %v\n%s", err, text)
+				}
+				if _, _, err := Replay(parsed); err != nil {
+					t.Fatalf("replay: %v\n%s", err, text)
+				}
+
+				if parsed.X != original.X || parsed.O != original.O || !parsed.Date.Equal(original.Date) {
+					t.Fatalf("players or date changed:\n%s", text)
+				}
+				if parsed.Options != original.Options || parsed.Result != original.Result ||
+					parsed.Reason != original.Reason || parsed.TimeControl != original.TimeControl {
+					t.Fatalf("tags changed: %+v -> %+v", original, parsed)
+				}
+				if len(parsed.Moves) != len(original.Moves) {
+					t.Fatalf("expected %d moves, got %d", len(original.Moves), len(parsed.Moves))
+				}
+				for j := range original.Moves {
+					if parsed.Moves[j] != original.Moves[j] {
+						t.Fatalf("move %d: expected %+v, got %+v", j+1, original.Moves[j], parsed.Moves[j])
+					}
+				}
+
+				again, err := Encode(parsed)
+				if err != nil || again != text {
+					t.Fatalf("encoding is not stable:\n%s\n%s", text, again)
+				}
+			}
+		})
+	}
+}
+
+func randomGame(t *testing.T, r *rand.Rand, opts rules.Options) *Game {
+	gameRules, err := rules.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &Game{
+		X:           "alice",
+		O:           "bob",
+		Date:        time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC),
+		Options:     gameRules.Options(),
+		TimeControl: "30+2",
+	}
+
+	state := gameRules.NewState()
+	outcome := gameRules.Outcome(state)
+	for !outcome.Finished {
+		moves := gameRules.LegalMoves(state)
+		move := moves[r.Intn(len(moves))]
+		if err := gameRules.Apply(state, move); err != nil {
+			t.Fatal(err)
+		}
+		g.Moves = append(g.Moves, move)
+		outcome = gameRules.Outcome(state)
+	}
+	g.Result = outcome.Winner
+	g.Reason = "line"
+	if outcome.Winner == rules.Draw {
+		g.Reason = "draw"
+	}
+	return g
+}
+
+func TestParse(t *testing.T) {
+	text := `[Date "2025.01.15"]
+[X "alice"]
+[O "bob"]
+[Result "1-0"]
+[Event "Club night"]
+
+1. a1 b1 2. a2 b2 3. a3 1-0
+`
+	g, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Options.Variant != rules.VariantClassic || len(g.Moves) != 5 || g.Result != rules.X {
+		t.Fatalf("unexpected game %+v", g)
+	}
+	if g.Moves[0].Cell != 0 || g.Moves[1].Cell != 1 || g.Moves[4].Cell != 6 {
+		t.Fatalf("unexpected cells %+v", g.Moves)
+	}
+	if len(g.Extra) != 1 || g.Extra[0].Value != "Club night" {
+		t.Fatalf("expected the Event tag to be kept, got %+v", g.Extra)
+	}
+
+	if _, outcome, err := Replay(g); err != nil || outcome.Winner != rules.X {
+		t.Fatalf("expected X to win, got %+v, %v", outcome, err)
+	}
+}
+
+func TestReplayRejectsInvalidGames(t *testing.T) {
+	invalid := map[string]string{
+		"occupied cell":      "1. b2 b2 *",
+		"move after the end": "[Result \"1-0\"]\n\n1. a1 b1 2. a2 b2 3. a3 c3 1-0",
+		"wrong result":       "[Result \"0-1\"]\n\n1. a1 b1 2. a2 b2 3. a3 0-1",
+		"unfinished win":     "[Result \"1-0\"]\n\n1. a1 b1 1-0",
+	}
+	for name, text := range invalid {
+		t.Run(name, func(t *testing.T) {
+			g, err := Parse(text)
+			if err == nil {
+				_, _, err = Replay(g)
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+
+	if _, err := Parse("1. b2=O *"); err == nil || !strings.Contains(err.Error(), "wild") {
+		t.Fatalf("expected a mark outside wild to be rejected, got %v", err)
+	}
+	if _, err := Parse("1. z9 *"); err == nil {
+		t.Fatal("expected a square off the board to be rejected")
+	}
+}
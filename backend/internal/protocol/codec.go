@@ -0,0 +1,182 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/ugorji/go/codec"
+)
+
+// Codec - формат передачи сообщений протокола. Поля сообщений и их имена
+// одинаковы во всех форматах, отличается только кодирование.
+type Codec interface {
+	// Name - имя формата; для всех, кроме JSON, это еще и окончание его
+	// подпротоколов.
+	Name() string
+	// Binary сообщает, идут ли сообщения в двоичных кадрах WebSocket.
+	Binary() bool
+	Encode(msg Message) ([]byte, error)
+	// Decode разбирает сообщение клиента. Ошибки всегда *Error.
+	Decode(data []byte) (Message, error)
+	// FromJSON перекодирует сообщение в JSON, пришедшее от другого
+	// экземпляра через шину.
+	FromJSON(payload []byte) ([]byte, error)
+}
+
+// Форматы, которые понимает сервер
+var (
+	JSON        Codec = jsonCodec{}
+	MessagePack Codec = msgpackCodec{}
+)
+
+// codecs - форматы в порядке предпочтения при согласовании
+var codecs = []Codec{MessagePack, JSON}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                            { return "json" }
+func (jsonCodec) Binary() bool                            { return false }
+func (jsonCodec) Encode(msg Message) ([]byte, error)      { return Encode(msg) }
+func (jsonCodec) Decode(data []byte) (Message, error)     { return Decode(data) }
+func (jsonCodec) FromJSON(payload []byte) ([]byte, error) { return payload, nil }
+
+// msgpackCodec кодирует сообщения в словари MessagePack с теми же ключами,
+// что у объектов JSON, включая "type".
+type msgpackCodec struct{}
+
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{}
+	// Строки как str, а не bin, чтобы клиенты получали строки
+	h.WriteExt = true
+	h.RawToString = true
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	return h
+}()
+
+func (msgpackCodec) Name() string { return "msgpack" }
+func (msgpackCodec) Binary() bool { return true }
+
+// Encode записывает структуру сообщения словарем, а затем вставляет перед
+// ее полями запись "type" и исправляет длину словаря.
+func (msgpackCodec) Encode(msg Message) ([]byte, error) {
+	var body []byte
+	if err := codec.NewEncoderBytes(&body, msgpackHandle).Encode(msg); err != nil {
+		return nil, err
+	}
+	n, header, ok := msgpackMapHeader(body)
+	if !ok {
+		return nil, fmt.Errorf("protocol: %T does not encode to a map", msg)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(body) + len(msg.MessageType()) + 12)
+	writeMsgpackMapHeader(&buf, n+1)
+	writeMsgpackString(&buf, "type")
+	writeMsgpackString(&buf, msg.MessageType())
+	buf.Write(body[header:])
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Decode(data []byte) (Message, error) {
+	var fields map[string]interface{}
+	if err := codec.NewDecoderBytes(data, msgpackHandle).Decode(&fields); err != nil {
+		return nil, Errorf(CodeBadRequest, "malformed MessagePack")
+	}
+	msgType, _ := fields["type"].(string)
+	msg, err := newInbound(msgType, func(name string) bool {
+		_, ok := fields[name]
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := codec.NewDecoderBytes(data, msgpackHandle).Decode(msg); err != nil {
+		return nil, &Error{Code: CodeBadRequest, Message: "invalid message", Request: msgType}
+	}
+	return msg, nil
+}
+
+// FromJSON разбирает JSON без типа сообщения и кодирует результат. Числа
+// без дробной части остаются целыми.
+func (msgpackCodec) FromJSON(payload []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	var out []byte
+	err := codec.NewEncoderBytes(&out, msgpackHandle).Encode(integers(value))
+	return out, err
+}
+
+// integers заменяет значения json.Number на int64 или float64.
+func integers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = integers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = integers(item)
+		}
+	}
+	return value
+}
+
+// msgpackMapHeader возвращает длину словаря в начале data и размер его
+// заголовка.
+func msgpackMapHeader(data []byte) (n, size int, ok bool) {
+	if len(data) == 0 {
+		return 0, 0, false
+	}
+	switch b := data[0]; {
+	case b&0xf0 == 0x80:
+		return int(b & 0x0f), 1, true
+	case b == 0xde && len(data) >= 3:
+		return int(binary.BigEndian.Uint16(data[1:])), 3, true
+	case b == 0xdf && len(data) >= 5:
+		return int(binary.BigEndian.Uint32(data[1:])), 5, true
+	}
+	return 0, 0, false
+}
+
+func writeMsgpackMapHeader(buf *bytes.Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.WriteByte(0x80 | byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(0xde)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdf)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func writeMsgpackString(buf *bytes.Buffer, s string) {
+	switch n := len(s); {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n <= 0xff:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(0xda)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdb)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.WriteString(s)
+}
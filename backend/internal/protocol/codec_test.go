@@ -0,0 +1,267 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ugorji/go/codec"
+)
+
+// generic разбирает кадр MessagePack в обычные значения Go.
+func generic(t testing.TB, data []byte) map[string]interface{} {
+	t.Helper()
+	var out map[string]interface{}
+	if err := codec.NewDecoderBytes(data, msgpackHandle).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func sampleGameState() *GameState {
+	cells := make([]string, 225)
+	for i := 0; i < 40; i++ {
+		cells[i*5] = []string{"X", "O"}[i%2]
+	}
+	return &GameState{
+		GameID:      "g-123",
+		Seq:         87,
+		PlayerX:     "alice",
+		PlayerO:     "bob",
+		Board:       Board{Variant: "gomoku", Size: 15, WinLength: 5},
+		Cells:       cells,
+		Turn:        "X",
+		TimeControl: &TimeControl{Initial: 300, Increment: 5},
+		Clocks:      Clocks{"X": 241300, "O": 198750},
+	}
+}
+
+func sampleMoveMade() *MoveMade {
+	return &MoveMade{
+		Sequenced: Sequenced{Seq: 88},
+		MoveID:    "1712000000000-112",
+		Cell:      112,
+		By:        "X",
+		Symbol:    "X",
+		Clocks:    Clocks{"X": 239100, "O": 198750},
+	}
+}
+
+func TestMessagePackEncode(t *testing.T) {
+	data, err := MessagePack.Encode(sampleMoveMade())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := generic(t, data)
+	want := map[string]interface{}{
+		"type":    "move_made",
+		"seq":     int64(88),
+		"move_id": "1712000000000-112",
+		"cell":    int64(112),
+		"by":      "X",
+		"symbol":  "X",
+		"clocks":  map[string]interface{}{"X": int64(239100), "O": int64(198750)},
+	}
+	if !reflect.DeepEqual(normalize(got), normalize(want)) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	data, err = MessagePack.Encode(&MatchCancelled{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := generic(t, data); len(got) != 1 || got["type"] != "match_cancelled" {
+		t.Fatalf("empty message encoded as %v", got)
+	}
+}
+
+// normalize приводит целые к одному типу: для положительных значений
+// декодер может вернуть uint64.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case uint64:
+		return int64(v)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = normalize(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = normalize(item)
+		}
+		return out
+	}
+	return value
+}
+
+func TestMessagePackMapHeader(t *testing.T) {
+	for _, n := range []int{0, 15, 16, 0xffff, 0x10000} {
+		var buf bytes.Buffer
+		writeMsgpackMapHeader(&buf, n)
+		got, size, ok := msgpackMapHeader(buf.Bytes())
+		if !ok || got != n || size != buf.Len() {
+			t.Errorf("header for %d parsed as %d (%d bytes, %v)", n, got, size, ok)
+		}
+	}
+
+	// С "type" у game_state из 15 полей нужен заголовок map16
+	state := sampleGameState()
+	state.Disconnected = map[string]int{"bob": 20}
+	data, err := MessagePack.Encode(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != 0xde {
+		t.Fatalf("expected a map16 header, got %#x", data[0])
+	}
+	got := generic(t, data)
+	if got["type"] != "game_state" || got["game_id"] != "g-123" || len(got["board"].([]interface{})) != 225 {
+		t.Fatalf("unexpected game_state %v", got)
+	}
+}
+
+func TestMessagePackDecode(t *testing.T) {
+	for _, msg := range inboundTypes {
+		data, err := MessagePack.Encode(msg)
+		if err != nil {
+			t.Fatalf("%s: %v", msg.MessageType(), err)
+		}
+		decoded, err := MessagePack.Decode(data)
+		if err != nil {
+			t.Fatalf("%s: %v", msg.MessageType(), err)
+		}
+		if reflect.TypeOf(decoded) != reflect.TypeOf(msg) {
+			t.Fatalf("%s decoded as %T", msg.MessageType(), decoded)
+		}
+	}
+
+	data, _ := MessagePack.Encode(&Move{Cell: 7, Symbol: "O", ID: "m1"})
+	msg, err := MessagePack.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if move := msg.(*Move); move.Cell != 7 || move.Symbol != "O" || move.ID != "m1" {
+		t.Fatalf("decoded %#v", move)
+	}
+}
+
+func TestMessagePackDecodeErrors(t *testing.T) {
+	encode := func(value interface{}) []byte {
+		var out []byte
+		if err := codec.NewEncoderBytes(&out, msgpackHandle).Encode(value); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	tests := []struct {
+		name string
+		data []byte
+		code ErrorCode
+	}{
+		{"garbage", []byte{0xc1}, CodeBadRequest},
+		{"not a map", encode([]int{1, 2}), CodeBadRequest},
+		{"no type", encode(map[string]interface{}{"cell": 4}), CodeBadRequest},
+		{"unknown", encode(map[string]interface{}{"type": "teleport"}), CodeUnknownType},
+		{"missing field", encode(map[string]interface{}{"type": "move"}), CodeBadRequest},
+		{"wrong type", encode(map[string]interface{}{"type": "move", "cell": "four"}), CodeBadRequest},
+	}
+	for _, tt := range tests {
+		_, err := MessagePack.Decode(tt.data)
+		protoErr, ok := err.(*Error)
+		if !ok || protoErr.Code != tt.code {
+			t.Errorf("%s: got %v, want %s", tt.name, err, tt.code)
+		}
+	}
+}
+
+func TestMessagePackFromJSON(t *testing.T) {
+	msg := sampleMoveMade()
+	payload, err := JSON.Encode(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcoded, err := MessagePack.FromJSON(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	direct, _ := MessagePack.Encode(msg)
+	if !reflect.DeepEqual(normalize(generic(t, transcoded)), normalize(generic(t, direct))) {
+		t.Fatalf("transcoded %v, encoded %v", generic(t, transcoded), generic(t, direct))
+	}
+
+	same, _ := JSON.FromJSON(payload)
+	if string(same) != string(payload) {
+		t.Fatal("JSON should pass payloads through")
+	}
+}
+
+func TestCodecsAgree(t *testing.T) {
+	for _, msg := range []Message{sampleGameState(), sampleMoveMade(), &GameOver{Result: "draw", Reason: "draw"}} {
+		jsonData, _ := JSON.Encode(msg)
+		packed, _ := MessagePack.Encode(msg)
+
+		dec := json.NewDecoder(bytes.NewReader(jsonData))
+		dec.UseNumber()
+		var fromJSON interface{}
+		if err := dec.Decode(&fromJSON); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(normalize(integers(fromJSON)), normalize(generic(t, packed))) {
+			t.Errorf("%s differs between codecs", msg.MessageType())
+		}
+	}
+}
+
+func benchmarkEncode(b *testing.B, c Codec, msg Message) {
+	data, err := c.Encode(msg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Encode(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(len(data)), "B/frame")
+}
+
+// Бенчмарки JSON измеряют путь, который сейчас проходит каждый текстовый кадр.
+func BenchmarkEncodeGameStateJSON(b *testing.B) { benchmarkEncode(b, JSON, sampleGameState()) }
+func BenchmarkEncodeGameStateMessagePack(b *testing.B) {
+	benchmarkEncode(b, MessagePack, sampleGameState())
+}
+func BenchmarkEncodeMoveMadeJSON(b *testing.B) { benchmarkEncode(b, JSON, sampleMoveMade()) }
+func BenchmarkEncodeMoveMadeMessagePack(b *testing.B) {
+	benchmarkEncode(b, MessagePack, sampleMoveMade())
+}
+
+func BenchmarkTranscodeMoveMadeMessagePack(b *testing.B) {
+	payload, _ := JSON.Encode(sampleMoveMade())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := MessagePack.FromJSON(payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkDecode(b *testing.B, c Codec) {
+	data, _ := c.Encode(&Move{Cell: 112, ID: "1712000000000-112"})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Decode(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(len(data)), "B/frame")
+}
+
+func BenchmarkDecodeMoveJSON(b *testing.B)        { benchmarkDecode(b, JSON) }
+func BenchmarkDecodeMoveMessagePack(b *testing.B) { benchmarkDecode(b, MessagePack) }
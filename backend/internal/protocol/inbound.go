@@ -0,0 +1,198 @@
+package protocol
+
+import "reflect"
+
+// Сообщения клиентов. Поля без omitempty обязательны; тег enum перечисляет
+// допустимые значения и попадает в схему.
+
+// TimeControl - контроль времени в секундах: либо Initial и Increment за
+// ход, либо PerMove на каждый ход. Нулевое значение - партия без часов.
+type TimeControl struct {
+	Initial   int `json:"initial,omitempty"`
+	Increment int `json:"increment,omitempty"`
+	PerMove   int `json:"per_move,omitempty"`
+}
+
+// GameSettings выбирает вариант. Size без Variant означает гомоку.
+type GameSettings struct {
+	Variant     string       `json:"variant,omitempty" enum:"classic,gomoku,ultimate,misere,wild"`
+	Size        int          `json:"size,omitempty"`
+	WinLength   int          `json:"win_length,omitempty"`
+	TimeControl *TimeControl `json:"time_control,omitempty"`
+}
+
+// FindMatch ставит игрока в рейтинговую очередь подбора.
+type FindMatch struct {
+	GameSettings
+}
+
+// CancelMatch снимает игрока с очереди подбора.
+type CancelMatch struct{}
+
+// FindBotMatch начинает партию с ботом, выбранным по Difficulty или по
+// рейтингу Elo, на котором он играет (от 800 до 1800). Personality - id бота
+// из GET /api/bots; он играет на своем рейтинге, если Elo не задан. Rated
+// по умолчанию true и влияет на отдельный рейтинг игрока против ботов.
+type FindBotMatch struct {
+	Difficulty  string `json:"difficulty,omitempty" enum:"easy,medium,hard"`
+	Elo         int    `json:"elo,omitempty"`
+	Personality string `json:"personality,omitempty"`
+	Rated       *bool  `json:"rated,omitempty"`
+	GameSettings
+}
+
+// CreateRoom открывает закрытую комнату. Rated по умолчанию true.
+type CreateRoom struct {
+	GameSettings
+	Rated  *bool  `json:"rated,omitempty"`
+	Symbol string `json:"symbol,omitempty" enum:"X,O,random"`
+	// Bot занимает второе место ботом этого уровня.
+	Bot string `json:"bot,omitempty" enum:"easy,medium,hard"`
+}
+
+// JoinRoom занимает второе место в комнате.
+type JoinRoom struct {
+	Code string `json:"code"`
+}
+
+// LeaveRoom закрывает открытую комнату игрока.
+type LeaveRoom struct{}
+
+// Challenge вызывает игрока в сети на партию.
+type Challenge struct {
+	Nickname string `json:"nickname"`
+	GameSettings
+}
+
+// AcceptChallenge принимает полученный вызов.
+type AcceptChallenge struct {
+	ChallengeID string `json:"challenge_id"`
+}
+
+// DeclineChallenge отклоняет полученный вызов.
+type DeclineChallenge struct {
+	ChallengeID string `json:"challenge_id"`
+}
+
+// CancelChallenge отзывает вызов игрока, пока на него не ответили.
+type CancelChallenge struct{}
+
+// RefuseChallenges отключает или снова включает входящие вызовы.
+type RefuseChallenges struct {
+	Refuse bool `json:"refuse"`
+}
+
+// Move - ход в клетку. Symbol нужен только в wild, где игрок выбирает знак.
+// ID выбирает клиент: ход, присланный повторно с тем же ID, не делается
+// дважды, вместо этого сервер повторяет его move_made.
+type Move struct {
+	Cell   int    `json:"cell"`
+	Symbol string `json:"symbol,omitempty" enum:"X,O"`
+	ID     string `json:"id,omitempty"`
+}
+
+// Forfeit сдает текущую партию.
+type Forfeit struct{}
+
+// RequestRematch предлагает сопернику еще одну партию.
+type RequestRematch struct{}
+
+// AcceptRematch соглашается на реванш.
+type AcceptRematch struct{}
+
+// DeclineRematch отказывается от реванша.
+type DeclineRematch struct{}
+
+// RejoinMatch возвращает игрока в партию после переподключения.
+type RejoinMatch struct{}
+
+// Resume возвращает в партию и повторяет события после LastSeq.
+type Resume struct {
+	GameID  string `json:"game_id"`
+	LastSeq int64  `json:"last_seq"`
+}
+
+// ExportGame запрашивает законченную партию в текстовой нотации.
+type ExportGame struct{}
+
+// Spectate подключает зрителем к идущей партии по ее id или нику одного из игроков.
+type Spectate struct {
+	GameID   string `json:"game_id,omitempty"`
+	Nickname string `json:"nickname,omitempty"`
+}
+
+// StopSpectating отключает зрителя.
+type StopSpectating struct{}
+
+// SendChat отправляет сопернику сообщение в чат.
+type SendChat struct {
+	Text string `json:"text"`
+}
+
+// SendEmote отправляет сопернику эмоцию.
+type SendEmote struct {
+	Emote string `json:"emote" enum:"gg,wave,thumbs_up,laugh,think,wow,sad"`
+}
+
+// MuteOpponent скрывает или показывает чат соперника. Без Muted переключает.
+type MuteOpponent struct {
+	Muted *bool `json:"muted,omitempty"`
+}
+
+func (*FindMatch) MessageType() string        { return "find_match" }
+func (*CancelMatch) MessageType() string      { return "cancel_match" }
+func (*FindBotMatch) MessageType() string     { return "find_bot_match" }
+func (*CreateRoom) MessageType() string       { return "create_room" }
+func (*JoinRoom) MessageType() string         { return "join_room" }
+func (*LeaveRoom) MessageType() string        { return "leave_room" }
+func (*Challenge) MessageType() string        { return "challenge" }
+func (*AcceptChallenge) MessageType() string  { return "accept_challenge" }
+func (*DeclineChallenge) MessageType() string { return "decline_challenge" }
+func (*CancelChallenge) MessageType() string  { return "cancel_challenge" }
+func (*RefuseChallenges) MessageType() string { return "refuse_challenges" }
+func (*Move) MessageType() string             { return "move" }
+func (*Forfeit) MessageType() string          { return "forfeit" }
+func (*RequestRematch) MessageType() string   { return "request_rematch" }
+func (*AcceptRematch) MessageType() string    { return "accept_rematch" }
+func (*DeclineRematch) MessageType() string   { return "decline_rematch" }
+func (*RejoinMatch) MessageType() string      { return "rejoin_match" }
+func (*Resume) MessageType() string           { return "resume" }
+func (*ExportGame) MessageType() string       { return "export_game" }
+func (*Spectate) MessageType() string         { return "spectate" }
+func (*StopSpectating) MessageType() string   { return "stop_spectating" }
+func (*SendChat) MessageType() string         { return "chat" }
+func (*SendEmote) MessageType() string        { return "emote" }
+func (*MuteOpponent) MessageType() string     { return "mute_opponent" }
+
+// inbound - тип сообщения клиента -> его регистрация
+var inbound = map[string]inboundType{}
+
+type inboundType struct {
+	new      func() Message
+	required []string
+}
+
+// inboundTypes хранит порядок регистрации для схемы
+var inboundTypes []Message
+
+func registerInbound(msgs ...Message) {
+	for _, msg := range msgs {
+		msg := msg
+		inboundTypes = append(inboundTypes, msg)
+		inbound[msg.MessageType()] = inboundType{
+			new:      func() Message { return newOf(msg) },
+			required: requiredFields(reflect.TypeOf(msg).Elem()),
+		}
+	}
+}
+
+func init() {
+	registerInbound(
+		&FindMatch{}, &CancelMatch{}, &FindBotMatch{},
+		&CreateRoom{}, &JoinRoom{}, &LeaveRoom{},
+		&Challenge{}, &AcceptChallenge{}, &DeclineChallenge{}, &CancelChallenge{}, &RefuseChallenges{},
+		&Move{}, &Forfeit{}, &RequestRematch{}, &AcceptRematch{}, &DeclineRematch{}, &RejoinMatch{}, &Resume{},
+		&ExportGame{}, &Spectate{}, &StopSpectating{},
+		&SendChat{}, &SendEmote{}, &MuteOpponent{},
+	)
+}
@@ -0,0 +1,283 @@
+package protocol
+
+// Сообщения сервера.
+
+// Clocks - оставшееся время сторон "X" и "O" в миллисекундах.
+type Clocks map[string]int64
+
+// Welcome - первое сообщение в каждом соединении.
+type Welcome struct {
+	Version    int    `json:"version"`
+	MinVersion int    `json:"min_version"`
+	MaxVersion int    `json:"max_version"`
+	Codec      string `json:"codec" enum:"json,msgpack"`
+	Nickname   string `json:"nickname"`
+}
+
+// Board описывает вариант партии.
+type Board struct {
+	Variant   string `json:"variant" enum:"classic,gomoku,ultimate,misere,wild"`
+	Size      int    `json:"size"`
+	WinLength int    `json:"win_length"`
+}
+
+// SubBoards - состояние малых досок в ultimate. ForcedBoard равен -1,
+// если можно играть на любой открытой доске.
+type SubBoards struct {
+	ForcedBoard *int     `json:"forced_board,omitempty"`
+	SubWinners  []string `json:"sub_winners,omitempty"`
+}
+
+// Event - сообщение партии с номером, которое хранится в журнале событий
+// партии, чтобы клиент, вернувшийся через resume, получил ровно то, что
+// пропустил.
+type Event interface {
+	Message
+	Sequence() int64
+	SetSequence(seq int64)
+}
+
+// Sequenced реализует Event для сообщения, в которое встроен. Seq
+// начинается с 1 и растет на единицу с каждым событием партии, в том числе
+// через реванши.
+type Sequenced struct {
+	Seq int64 `json:"seq"`
+}
+
+func (s *Sequenced) Sequence() int64 { return s.Seq }
+
+func (s *Sequenced) SetSequence(seq int64) { s.Seq = seq }
+
+// MatchFound сообщает о новой партии. Room задан для партий из комнат,
+// Rated - для партий из комнат и с ботом, а IsBot, BotElo и, для ботов,
+// выбранных по уровню или характеру, Difficulty или Personality - для
+// партий с ботом.
+type MatchFound struct {
+	GameID   string `json:"game_id"`
+	Symbol   string `json:"symbol" enum:"X,O"`
+	Opponent string `json:"opponent"`
+	Board
+	TimeControl *TimeControl `json:"time_control,omitempty"`
+	Room        string       `json:"room,omitempty"`
+	Rated       *bool        `json:"rated,omitempty"`
+	IsBot       bool         `json:"isBot,omitempty"`
+	Difficulty  string       `json:"difficulty,omitempty"`
+	BotElo      int          `json:"bot_elo,omitempty"`
+	Personality string       `json:"personality,omitempty"`
+}
+
+// RatingWindow - диапазон рейтингов, который сейчас принимает подбор.
+type RatingWindow struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// Searching сообщает о ходе подбора. EstimatedWait - в секундах.
+type Searching struct {
+	Rating        int          `json:"rating"`
+	Window        RatingWindow `json:"window"`
+	EstimatedWait *int         `json:"estimated_wait,omitempty"`
+}
+
+// MatchCancelled подтверждает cancel_match.
+type MatchCancelled struct{}
+
+// MoveMade - ход в партии. By - сторона, сделавшая ход, Symbol -
+// поставленный знак; они различаются только в wild. MoveID повторяет id
+// сообщения move, чтобы игрок видел, что ход принят.
+type MoveMade struct {
+	Sequenced
+	MoveID string `json:"move_id,omitempty"`
+	Cell   int    `json:"cell"`
+	By     string `json:"by" enum:"X,O"`
+	Symbol string `json:"symbol" enum:"X,O"`
+	SubBoards
+	Clocks Clocks `json:"clocks,omitempty"`
+}
+
+// GameState - полное состояние партии, отправляется на rejoin_match,
+// spectate и после перезапуска сервера. Seq - последнее событие, которое
+// учтено в состоянии. Disconnected - отключившиеся игроки и сколько секунд
+// у них осталось на возвращение.
+type GameState struct {
+	GameID  string `json:"game_id"`
+	Seq     int64  `json:"seq"`
+	PlayerX string `json:"player_x"`
+	PlayerO string `json:"player_o"`
+	Board
+	Cells      []string `json:"board"`
+	Turn       string   `json:"turn" enum:"X,O"`
+	IsFinished bool     `json:"isFinished"`
+	Winner     string   `json:"winner"`
+	Spectators int      `json:"spectators"`
+	SubBoards
+	TimeControl  *TimeControl   `json:"time_control,omitempty"`
+	Clocks       Clocks         `json:"clocks,omitempty"`
+	Disconnected map[string]int `json:"disconnected,omitempty"`
+	Spectating   bool           `json:"spectating,omitempty"`
+}
+
+// GameOver завершает партию. Result - победившая сторона или "draw".
+type GameOver struct {
+	Sequenced
+	Result         string `json:"result" enum:"X,O,draw"`
+	Reason         string `json:"reason" enum:"line,draw,timeout,forfeit,abandoned"`
+	WinningPattern []int  `json:"winningPattern,omitempty"`
+	Clocks         Clocks `json:"clocks,omitempty"`
+}
+
+// OpponentLeft сообщает игроку, что соперник ушел после партии.
+type OpponentLeft struct{}
+
+// OpponentDisconnected сообщает остальным, что игрок потерял соединение, и
+// сколько секунд у него есть, чтобы вернуться, пока партию не засчитали.
+type OpponentDisconnected struct {
+	Sequenced
+	Nickname string `json:"nickname"`
+	Seconds  int    `json:"seconds"`
+}
+
+// OpponentReconnected сообщает остальным, что игрок вернулся.
+type OpponentReconnected struct {
+	Sequenced
+	Nickname string `json:"nickname"`
+}
+
+// RematchRequested сообщает игроку, что соперник хочет реванш.
+type RematchRequested struct {
+	Opponent string `json:"opponent"`
+}
+
+// RematchDeclined закрывает предложение реванша.
+type RematchDeclined struct{}
+
+// Rematch начинает реванш с новыми сторонами.
+type Rematch struct {
+	Symbol   string `json:"symbol" enum:"X,O"`
+	Opponent string `json:"opponent"`
+}
+
+// RoomUpdate сообщает состояние закрытой комнаты. ExpiresAt - время Unix,
+// задается только пока комната ждет второго игрока.
+type RoomUpdate struct {
+	Code       string `json:"code"`
+	Status     string `json:"status" enum:"waiting,started,closed,expired"`
+	Host       string `json:"host"`
+	Guest      string `json:"guest,omitempty"`
+	Rated      bool   `json:"rated"`
+	HostSymbol string `json:"host_symbol" enum:"X,O,random"`
+	Board
+	TimeControl *TimeControl `json:"time_control,omitempty"`
+	ExpiresAt   int64        `json:"expires_at,omitempty"`
+}
+
+// ChallengeInfo описывает вызов. ExpiresAt - время Unix.
+type ChallengeInfo struct {
+	ChallengeID string `json:"challenge_id"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Board
+	TimeControl *TimeControl `json:"time_control,omitempty"`
+	ExpiresAt   int64        `json:"expires_at"`
+}
+
+// ChallengeReceived получает вызванный игрок, с рейтингом вызвавшего.
+type ChallengeReceived struct {
+	ChallengeInfo
+	Elo int `json:"elo,omitempty"`
+}
+
+// ChallengeSent подтверждает вызов вызвавшему.
+type ChallengeSent struct {
+	ChallengeInfo
+}
+
+// ChallengeUpdate сообщает, чем закончился вызов.
+type ChallengeUpdate struct {
+	ChallengeID string `json:"challenge_id"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Status      string `json:"status" enum:"accepted,declined,cancelled,expired"`
+}
+
+// ChallengeSettings подтверждает refuse_challenges.
+type ChallengeSettings struct {
+	Refuse bool `json:"refuse"`
+}
+
+// ChatMessage - сообщение чата, приходит обоим игрокам.
+type ChatMessage struct {
+	From string `json:"from"`
+	Text string `json:"text"`
+}
+
+// EmoteMessage - эмоция, приходит обоим игрокам.
+type EmoteMessage struct {
+	From  string `json:"from"`
+	Emote string `json:"emote"`
+}
+
+// OpponentMuted подтверждает mute_opponent.
+type OpponentMuted struct {
+	Opponent string `json:"opponent"`
+	Muted    bool   `json:"muted"`
+}
+
+// Spectators - число зрителей партии.
+type Spectators struct {
+	Sequenced
+	Count int `json:"count"`
+}
+
+// Resumed завершает повтор пропущенных событий после resume. Seq -
+// последнее событие партии; Replayed - сколько событий отправлено, или -1,
+// если журнал уже не доставал до last_seq и вместо событий ушел game_state.
+type Resumed struct {
+	GameID   string `json:"game_id"`
+	Seq      int64  `json:"seq"`
+	Replayed int    `json:"replayed"`
+}
+
+// GameExport - законченная партия в текстовой нотации.
+type GameExport struct {
+	GameID   int    `json:"game_id"`
+	Notation string `json:"notation"`
+}
+
+func (*Welcome) MessageType() string              { return "welcome" }
+func (*MatchFound) MessageType() string           { return "match_found" }
+func (*Searching) MessageType() string            { return "searching" }
+func (*MatchCancelled) MessageType() string       { return "match_cancelled" }
+func (*MoveMade) MessageType() string             { return "move_made" }
+func (*GameState) MessageType() string            { return "game_state" }
+func (*GameOver) MessageType() string             { return "game_over" }
+func (*OpponentLeft) MessageType() string         { return "opponent_left" }
+func (*OpponentDisconnected) MessageType() string { return "opponent_disconnected" }
+func (*OpponentReconnected) MessageType() string  { return "opponent_reconnected" }
+func (*RematchRequested) MessageType() string     { return "rematch_requested" }
+func (*RematchDeclined) MessageType() string      { return "rematch_declined" }
+func (*Rematch) MessageType() string              { return "rematch" }
+func (*RoomUpdate) MessageType() string           { return "room_update" }
+func (*ChallengeReceived) MessageType() string    { return "challenge_received" }
+func (*ChallengeSent) MessageType() string        { return "challenge_sent" }
+func (*ChallengeUpdate) MessageType() string      { return "challenge_update" }
+func (*ChallengeSettings) MessageType() string    { return "challenge_settings" }
+func (*ChatMessage) MessageType() string          { return "chat" }
+func (*EmoteMessage) MessageType() string         { return "emote" }
+func (*OpponentMuted) MessageType() string        { return "opponent_muted" }
+func (*Spectators) MessageType() string           { return "spectators" }
+func (*Resumed) MessageType() string              { return "resumed" }
+func (*GameExport) MessageType() string           { return "game_export" }
+
+// outboundTypes - все сообщения сервера в порядке схемы
+var outboundTypes = []Message{
+	&Welcome{}, &Error{},
+	&MatchFound{}, &Searching{}, &MatchCancelled{},
+	&MoveMade{}, &GameState{}, &GameOver{},
+	&OpponentLeft{}, &OpponentDisconnected{}, &OpponentReconnected{},
+	&RematchRequested{}, &RematchDeclined{}, &Rematch{},
+	&RoomUpdate{},
+	&ChallengeReceived{}, &ChallengeSent{}, &ChallengeUpdate{}, &ChallengeSettings{},
+	&ChatMessage{}, &EmoteMessage{}, &OpponentMuted{},
+	&Spectators{}, &Resumed{}, &GameExport{},
+}
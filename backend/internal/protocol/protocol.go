@@ -0,0 +1,230 @@
+// Package protocol описывает протокол WebSocket между игровым сервером и
+// клиентами: структуру для каждого сообщения в обе стороны, сообщение об
+// ошибке с машиночитаемыми кодами, согласование версии протокола и JSON
+// Schema, построенную по типам сообщений.
+//
+// Каждое сообщение - объект JSON, поле "type" которого называет сообщение.
+// Остальные поля - поля структуры Go, зарегистрированной для этого типа.
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Версии протокола, которые понимает сервер. Клиент выбирает версию,
+// предлагая подходящий подпротокол WebSocket (см. Subprotocol); клиент без
+// подпротокола получает Version в JSON.
+const (
+	Version    = 1
+	MinVersion = 1
+)
+
+const subprotocolPrefix = "tictactoe.v"
+
+// Subprotocol возвращает имя подпротокола WebSocket для версии протокола
+// в JSON, например "tictactoe.v1".
+func Subprotocol(version int) string {
+	return subprotocolPrefix + strconv.Itoa(version)
+}
+
+// CodecSubprotocol возвращает имя подпротокола для версии протокола в
+// формате c, например "tictactoe.v1.msgpack".
+func CodecSubprotocol(version int, c Codec) string {
+	if c == JSON {
+		return Subprotocol(version)
+	}
+	return Subprotocol(version) + "." + c.Name()
+}
+
+// Subprotocols перечисляет подпротоколы, которые принимает сервер, в
+// порядке предпочтения: сначала новые версии, двоичные форматы раньше JSON.
+// Одно имя формата, например "msgpack", выбирает текущую версию.
+func Subprotocols() []string {
+	names := make([]string, 0, (Version-MinVersion+1)*len(codecs)+1)
+	for v := Version; v >= MinVersion; v-- {
+		for _, c := range codecs {
+			names = append(names, CodecSubprotocol(v, c))
+		}
+	}
+	return append(names, MessagePack.Name())
+}
+
+// ParseSubprotocol возвращает версию протокола и формат по имени подпротокола.
+func ParseSubprotocol(name string) (int, Codec, bool) {
+	if name == MessagePack.Name() {
+		return Version, MessagePack, true
+	}
+	if !strings.HasPrefix(name, subprotocolPrefix) {
+		return 0, nil, false
+	}
+	version, suffix, dotted := strings.Cut(strings.TrimPrefix(name, subprotocolPrefix), ".")
+	v, err := strconv.Atoi(version)
+	if err != nil || v < MinVersion || v > Version {
+		return 0, nil, false
+	}
+	if !dotted {
+		return v, JSON, true
+	}
+	for _, c := range codecs {
+		if c != JSON && c.Name() == suffix {
+			return v, c, true
+		}
+	}
+	return 0, nil, false
+}
+
+// Message реализуют все сообщения протокола.
+type Message interface {
+	MessageType() string
+}
+
+// Encode записывает msg объектом JSON, где поле "type" идет первым.
+func Encode(msg Message) ([]byte, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < 2 || body[0] != '{' {
+		return nil, fmt.Errorf("protocol: %T does not encode to an object", msg)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(body) + len(msg.MessageType()) + 10)
+	buf.WriteString(`{"type":`)
+	typ, _ := json.Marshal(msg.MessageType())
+	buf.Write(typ)
+	if len(body) > 2 {
+		buf.WriteByte(',')
+	}
+	buf.Write(body[1:])
+	return buf.Bytes(), nil
+}
+
+// Decode разбирает сообщение клиента. Ошибки всегда *Error и готовы к
+// отправке клиенту.
+func Decode(data []byte) (Message, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, Errorf(CodeBadRequest, "malformed JSON")
+	}
+	var msgType string
+	_ = json.Unmarshal(fields["type"], &msgType)
+	msg, err := newInbound(msgType, func(name string) bool {
+		_, ok := fields[name]
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, msg); err != nil {
+		message := "invalid message"
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			message = "invalid field: " + typeErr.Field
+		}
+		return nil, &Error{Code: CodeBadRequest, Message: message, Request: msgType}
+	}
+	return msg, nil
+}
+
+// newInbound возвращает пустое сообщение клиента типа msgType, проверив
+// через has, что все обязательные поля присутствуют.
+func newInbound(msgType string, has func(name string) bool) (Message, error) {
+	if msgType == "" {
+		return nil, Errorf(CodeBadRequest, "missing message type")
+	}
+	registered, ok := inbound[msgType]
+	if !ok {
+		return nil, &Error{Code: CodeUnknownType, Message: "unknown message type: " + msgType, Request: msgType}
+	}
+	for _, name := range registered.required {
+		if !has(name) {
+			return nil, &Error{Code: CodeBadRequest, Message: "missing field: " + name, Request: msgType}
+		}
+	}
+	return registered.new(), nil
+}
+
+// IsInbound сообщает, могут ли клиенты присылать сообщения этого типа.
+func IsInbound(msgType string) bool {
+	_, ok := inbound[msgType]
+	return ok
+}
+
+// ErrorCode - машиночитаемая причина в сообщении об ошибке.
+type ErrorCode string
+
+const (
+	// CodeBadRequest: сообщение - не JSON или не соответствует схеме.
+	CodeBadRequest ErrorCode = "bad_request"
+	// CodeUnknownType: сервер не знает такого типа сообщения.
+	CodeUnknownType ErrorCode = "unknown_type"
+	// CodeUnsupportedVersion: ни одна из предложенных версий протокола не поддерживается.
+	CodeUnsupportedVersion ErrorCode = "unsupported_version"
+	// CodeInvalidSettings: неверные настройки партии (вариант, доска, часы).
+	CodeInvalidSettings ErrorCode = "invalid_settings"
+	// CodeInvalidMove: ход нарушает правила варианта.
+	CodeInvalidMove ErrorCode = "invalid_move"
+	// CodeNotYourTurn: ход прислан не в свою очередь.
+	CodeNotYourTurn ErrorCode = "not_your_turn"
+	// CodeTimeUp: у игрока кончилось время.
+	CodeTimeUp ErrorCode = "time_up"
+	// CodeNoActiveGame: команде нужна партия, а игрок не играет.
+	CodeNoActiveGame ErrorCode = "no_active_game"
+	// CodeInGame: команда недоступна во время партии.
+	CodeInGame ErrorCode = "in_game"
+	// CodeGameNotFound: партии нет или она закончилась.
+	CodeGameNotFound ErrorCode = "game_not_found"
+	// CodeAlreadyQueued: игрок уже ищет соперника.
+	CodeAlreadyQueued ErrorCode = "already_queued"
+	// CodeNotQueued: игрок не ищет соперника.
+	CodeNotQueued ErrorCode = "not_queued"
+	// CodeRoomNotFound: нет открытой комнаты с таким кодом.
+	CodeRoomNotFound ErrorCode = "room_not_found"
+	// CodeAlreadyHosting: у игрока уже есть открытая комната.
+	CodeAlreadyHosting ErrorCode = "already_hosting"
+	// CodeChallengeNotFound: вызова нет или он истек.
+	CodeChallengeNotFound ErrorCode = "challenge_not_found"
+	// CodePlayerOffline: другой игрок не подключен.
+	CodePlayerOffline ErrorCode = "player_offline"
+	// CodePlayerBusy: другой игрок играет или ищет соперника.
+	CodePlayerBusy ErrorCode = "player_busy"
+	// CodeRateLimited: игрок присылает сообщения слишком часто.
+	CodeRateLimited ErrorCode = "rate_limited"
+	// CodeRejected: команда верна, но сейчас недоступна.
+	CodeRejected ErrorCode = "rejected"
+	// CodeInternal: сервер не смог выполнить команду.
+	CodeInternal ErrorCode = "internal"
+)
+
+// ErrorCodes - все коды ошибок в порядке описания.
+var ErrorCodes = []ErrorCode{
+	CodeBadRequest, CodeUnknownType, CodeUnsupportedVersion, CodeInvalidSettings,
+	CodeInvalidMove, CodeNotYourTurn, CodeTimeUp, CodeNoActiveGame, CodeInGame,
+	CodeGameNotFound, CodeAlreadyQueued, CodeNotQueued, CodeRoomNotFound,
+	CodeAlreadyHosting, CodeChallengeNotFound, CodePlayerOffline, CodePlayerBusy,
+	CodeRateLimited, CodeRejected, CodeInternal,
+}
+
+// Error - сообщение "error". Оно же ошибка Go, чтобы Decode и обработчики
+// команд могли сразу его вернуть.
+type Error struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	// Request - тип сообщения, вызвавшего ошибку, если он известен.
+	Request string `json:"request,omitempty"`
+}
+
+func (*Error) MessageType() string { return "error" }
+
+func (e *Error) Error() string { return e.Message }
+
+// Errorf создает Error с отформатированным текстом.
+func Errorf(code ErrorCode, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
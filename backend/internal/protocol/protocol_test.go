@@ -0,0 +1,171 @@
+package protocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeAddsType(t *testing.T) {
+	data, err := Encode(&GameOver{Result: "X", Reason: "line", WinningPattern: []int{0, 1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"game_over","seq":0,"result":"X","reason":"line","winningPattern":[0,1,2]}`
+	if string(data) != want {
+		t.Fatalf("Encode = %s, want %s", data, want)
+	}
+
+	data, err = Encode(&MatchCancelled{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"type":"match_cancelled"}` {
+		t.Fatalf("Encode of an empty message = %s", data)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	msg, err := Decode([]byte(`{"type":"move","cell":4,"symbol":"O"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	move, ok := msg.(*Move)
+	if !ok || move.Cell != 4 || move.Symbol != "O" {
+		t.Fatalf("Decode = %#v", msg)
+	}
+
+	msg, err = Decode([]byte(`{"type":"find_match","size":15,"win_length":5,"time_control":{"initial":30,"increment":2}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	find := msg.(*FindMatch)
+	if find.Size != 15 || find.WinLength != 5 || find.TimeControl == nil || find.TimeControl.Increment != 2 {
+		t.Fatalf("embedded settings not decoded: %#v", find)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		code    ErrorCode
+		request string
+	}{
+		{`{"type":"move",`, CodeBadRequest, ""},
+		{`[1,2]`, CodeBadRequest, ""},
+		{`{"cell":4}`, CodeBadRequest, ""},
+		{`{"type":"teleport"}`, CodeUnknownType, "teleport"},
+		{`{"type":"move"}`, CodeBadRequest, "move"},
+		{`{"type":"move","cell":"4"}`, CodeBadRequest, "move"},
+		{`{"type":"move","cell":4.5}`, CodeBadRequest, "move"},
+		{`{"type":"join_room"}`, CodeBadRequest, "join_room"},
+	}
+	for _, tt := range tests {
+		_, err := Decode([]byte(tt.input))
+		protoErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("%s: expected *Error, got %v", tt.input, err)
+			continue
+		}
+		if protoErr.Code != tt.code || protoErr.Request != tt.request {
+			t.Errorf("%s: got %s/%q, want %s/%q", tt.input, protoErr.Code, protoErr.Request, tt.code, tt.request)
+		}
+	}
+}
+
+func TestInboundRoundTrip(t *testing.T) {
+	for _, msg := range inboundTypes {
+		data, err := Encode(msg)
+		if err != nil {
+			t.Fatalf("%s: %v", msg.MessageType(), err)
+		}
+		decoded, err := Decode(data)
+		if err != nil {
+			t.Fatalf("%s: %v", msg.MessageType(), err)
+		}
+		if reflect.TypeOf(decoded) != reflect.TypeOf(msg) {
+			t.Fatalf("%s decoded as %T", msg.MessageType(), decoded)
+		}
+	}
+}
+
+func TestMessageTypesAreUnique(t *testing.T) {
+	for name, list := range map[string][]Message{"inbound": inboundTypes, "outbound": outboundTypes} {
+		seen := make(map[string]bool)
+		for _, msg := range list {
+			if seen[msg.MessageType()] {
+				t.Errorf("%s type %q registered twice", name, msg.MessageType())
+			}
+			seen[msg.MessageType()] = true
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	schema := Schema()
+	if _, err := json.Marshal(schema); err != nil {
+		t.Fatal(err)
+	}
+	defs := schema["$defs"].(map[string]interface{})
+	for _, msg := range inboundTypes {
+		if defs["client."+msg.MessageType()] == nil {
+			t.Errorf("no schema for client message %s", msg.MessageType())
+		}
+	}
+	for _, msg := range outboundTypes {
+		if defs["server."+msg.MessageType()] == nil {
+			t.Errorf("no schema for server message %s", msg.MessageType())
+		}
+	}
+
+	move := defs["client.move"].(map[string]interface{})
+	if got := move["required"].([]string); !reflect.DeepEqual(got, []string{"type", "cell"}) {
+		t.Errorf("move requires %v", got)
+	}
+	props := move["properties"].(map[string]interface{})
+	if props["type"].(map[string]interface{})["const"] != "move" {
+		t.Error("move schema does not pin the type")
+	}
+	if props["cell"].(map[string]interface{})["type"] != "integer" {
+		t.Error("cell should be an integer")
+	}
+
+	// Embedded structs are flattened like encoding/json does.
+	found := defs["server.match_found"].(map[string]interface{})["properties"].(map[string]interface{})
+	for _, name := range []string{"variant", "size", "win_length", "time_control"} {
+		if found[name] == nil {
+			t.Errorf("match_found schema lacks %s", name)
+		}
+	}
+
+	code := defs["server.error"].(map[string]interface{})["properties"].(map[string]interface{})["code"].(map[string]interface{})
+	if len(code["enum"].([]string)) != len(ErrorCodes) {
+		t.Error("error schema does not list every code")
+	}
+}
+
+func TestSubprotocol(t *testing.T) {
+	name := Subprotocol(Version)
+	if !strings.HasPrefix(name, "tictactoe.") {
+		t.Fatalf("unexpected subprotocol %q", name)
+	}
+	if v, c, ok := ParseSubprotocol(name); !ok || v != Version || c != JSON {
+		t.Fatalf("ParseSubprotocol(%q) = %d, %v, %v", name, v, c, ok)
+	}
+	for _, name := range []string{CodecSubprotocol(Version, MessagePack), "msgpack"} {
+		if v, c, ok := ParseSubprotocol(name); !ok || v != Version || c != MessagePack {
+			t.Fatalf("ParseSubprotocol(%q) = %d, %v, %v", name, v, c, ok)
+		}
+	}
+	for _, offered := range Subprotocols() {
+		if _, _, ok := ParseSubprotocol(offered); !ok {
+			t.Errorf("offered subprotocol %q does not parse", offered)
+		}
+	}
+	for _, bad := range []string{"", "tictactoe.v0", "tictactoe.v99", "chat", "tictactoe.vx", "tictactoe.v1.xml", "tictactoe.v1."} {
+		if _, _, ok := ParseSubprotocol(bad); ok {
+			t.Errorf("ParseSubprotocol(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package protocol
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Schema возвращает JSON Schema (draft 2020-12) всех сообщений текущей
+// версии протокола. Схема строится по структурам сообщений, поэтому не
+// расходится с тем, что сервер на самом деле отправляет и принимает.
+//
+// Сообщения клиента описаны в $defs как "client.<type>", сообщения сервера -
+// как "server.<type>"; "client_message" и "server_message" - объединения
+// сообщений каждого направления.
+func Schema() map[string]interface{} {
+	defs := make(map[string]interface{})
+	clientRefs := make([]interface{}, 0, len(inboundTypes))
+	for _, msg := range inboundTypes {
+		name := "client." + msg.MessageType()
+		defs[name] = messageSchema(msg)
+		clientRefs = append(clientRefs, ref(name))
+	}
+	serverRefs := make([]interface{}, 0, len(outboundTypes))
+	for _, msg := range outboundTypes {
+		name := "server." + msg.MessageType()
+		defs[name] = messageSchema(msg)
+		serverRefs = append(serverRefs, ref(name))
+	}
+	defs["client_message"] = map[string]interface{}{"oneOf": clientRefs}
+	defs["server_message"] = map[string]interface{}{"oneOf": serverRefs}
+
+	codes := make([]string, len(ErrorCodes))
+	for i, code := range ErrorCodes {
+		codes[i] = string(code)
+	}
+	errorDef := defs["server.error"].(map[string]interface{})
+	errorDef["properties"].(map[string]interface{})["code"] = map[string]interface{}{
+		"type": "string",
+		"enum": codes,
+	}
+
+	return map[string]interface{}{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         "/api/ws-schema",
+		"title":       "Tic-tac-toe WebSocket protocol",
+		"version":     Version,
+		"subprotocol": Subprotocol(Version),
+		// Те же сообщения доступны и как словари MessagePack
+		"subprotocols": Subprotocols(),
+		"anyOf":        []interface{}{ref("client_message"), ref("server_message")},
+		"$defs":        defs,
+	}
+}
+
+func ref(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/$defs/" + name}
+}
+
+func messageSchema(msg Message) map[string]interface{} {
+	schema := objectSchema(reflect.TypeOf(msg).Elem())
+	schema["title"] = msg.MessageType()
+	schema["properties"].(map[string]interface{})["type"] = map[string]interface{}{"const": msg.MessageType()}
+	schema["required"] = append([]string{"type"}, schema["required"].([]string)...)
+	return schema
+}
+
+func objectSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+	for _, f := range jsonFields(t) {
+		properties[f.name] = typeSchema(f.typ, f.enum)
+		if !f.omitempty {
+			required = append(required, f.name)
+		}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+func typeSchema(t reflect.Type, enum []string) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var schema map[string]interface{}
+	switch t.Kind() {
+	case reflect.String:
+		schema = map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		schema = map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema = map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		schema = map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		schema = map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), enum)}
+		enum = nil
+	case reflect.Map:
+		schema = map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), nil)}
+	case reflect.Struct:
+		schema = objectSchema(t)
+	default:
+		schema = map[string]interface{}{}
+	}
+	if len(enum) > 0 {
+		schema["enum"] = enum
+	}
+	return schema
+}
+
+type jsonField struct {
+	name      string
+	typ       reflect.Type
+	omitempty bool
+	enum      []string
+}
+
+// jsonFields перечисляет поля JSON структуры так, как их видит
+// encoding/json: поля встроенных структур поднимаются наверх.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, jsonFields(f.Type)...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		field := jsonField{name: name, typ: f.Type, omitempty: strings.Contains(opts, "omitempty")}
+		if enum := f.Tag.Get("enum"); enum != "" {
+			field.enum = strings.Split(enum, ",")
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// requiredFields возвращает имена JSON полей без omitempty.
+func requiredFields(t reflect.Type) []string {
+	var names []string
+	for _, f := range jsonFields(t) {
+		if !f.omitempty {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// newOf возвращает новое пустое сообщение того же типа, что msg.
+func newOf(msg Message) Message {
+	return reflect.New(reflect.TypeOf(msg).Elem()).Interface().(Message)
+}
@@ -0,0 +1,55 @@
+package rules
+
+// ClassicLines - восемь выигрышных линий доски 3x3.
+var ClassicLines = [][]int{
+	{0, 1, 2}, {3, 4, 5}, {6, 7, 8}, // строки
+	{0, 3, 6}, {1, 4, 7}, {2, 5, 8}, // столбцы
+	{0, 4, 8}, {2, 4, 6}, // диагонали
+}
+
+// Classic - обычная игра на доске 3x3 до трех в ряд.
+type Classic struct{}
+
+func (Classic) Options() Options {
+	return Options{Variant: VariantClassic, Size: 3, WinLength: 3}
+}
+
+func (Classic) NewState() *State {
+	return NewState(9)
+}
+
+func (Classic) LegalMoves(s *State) []Move {
+	return emptyCells(s.Board)
+}
+
+func (Classic) Apply(s *State, m Move) error {
+	if m.Cell < 0 || m.Cell >= len(s.Board) || s.Board[m.Cell] != "" {
+		return ErrInvalidMove
+	}
+	s.Board[m.Cell] = s.Turn
+	s.LastMove = m.Cell
+	s.MoveCount++
+	s.Turn = Opposite(s.Turn)
+	return nil
+}
+
+func (Classic) Outcome(s *State) Outcome {
+	if mark, line := findLine(s.Board); mark != "" {
+		return Outcome{Finished: true, Winner: mark, Line: line}
+	}
+	if s.MoveCount == len(s.Board) {
+		return Outcome{Finished: true, Winner: Draw}
+	}
+	return Outcome{}
+}
+
+// findLine возвращает знак и клетки первой собранной линии на доске 3x3.
+func findLine(board []string) (string, []int) {
+	for _, line := range ClassicLines {
+		a, b, c := line[0], line[1], line[2]
+		if board[a] != "" && board[a] == board[b] && board[b] == board[c] {
+			return board[a], line
+		}
+	}
+	return "", nil
+}
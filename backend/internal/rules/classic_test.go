@@ -0,0 +1,56 @@
+package rules
+
+import "testing"
+
+func TestClassicOutcome(t *testing.T) {
+	tests := []struct {
+		name   string
+		moves  []int
+		winner string
+		line   []int
+	}{
+		{"X wins on a row", []int{0, 3, 1, 4, 2}, X, []int{0, 1, 2}},
+		{"O wins on a diagonal", []int{1, 2, 3, 4, 8, 6}, O, []int{2, 4, 6}},
+		{"Draw on a full board", []int{0, 1, 2, 4, 3, 5, 7, 6, 8}, Draw, nil},
+		{"Game still in progress", []int{0, 4}, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Classic{}
+			s := r.NewState()
+			for _, cell := range tt.moves {
+				if err := r.Apply(s, Move{Cell: cell}); err != nil {
+					t.Fatalf("apply %d: %v", cell, err)
+				}
+			}
+
+			outcome := r.Outcome(s)
+			if outcome.Winner != tt.winner || outcome.Finished != (tt.winner != "") {
+				t.Fatalf("expected winner %q, got %+v", tt.winner, outcome)
+			}
+			if len(outcome.Line) != len(tt.line) {
+				t.Fatalf("expected line %v, got %v", tt.line, outcome.Line)
+			}
+			for i := range tt.line {
+				if outcome.Line[i] != tt.line[i] {
+					t.Fatalf("expected line %v, got %v", tt.line, outcome.Line)
+				}
+			}
+		})
+	}
+}
+
+func TestClassicRejectsOccupiedCell(t *testing.T) {
+	r := Classic{}
+	s := r.NewState()
+	if err := r.Apply(s, Move{Cell: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Apply(s, Move{Cell: 4}); err != ErrInvalidMove {
+		t.Fatalf("expected ErrInvalidMove, got %v", err)
+	}
+	if err := r.Apply(s, Move{Cell: 9}); err != ErrInvalidMove {
+		t.Fatalf("expected ErrInvalidMove, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package rules
+
+// directions - шаги по строке и столбцу для четырех линий через клетку
+var directions = [4][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
+
+// Gomoku - доска N×N, выигрывают WinLength знаков в ряд.
+type Gomoku struct {
+	Size      int
+	WinLength int
+}
+
+func (g Gomoku) Options() Options {
+	return Options{Variant: VariantGomoku, Size: g.Size, WinLength: g.WinLength}
+}
+
+func (g Gomoku) NewState() *State {
+	return NewState(g.Size * g.Size)
+}
+
+func (g Gomoku) LegalMoves(s *State) []Move {
+	return emptyCells(s.Board)
+}
+
+func (g Gomoku) Apply(s *State, m Move) error {
+	if m.Cell < 0 || m.Cell >= len(s.Board) || s.Board[m.Cell] != "" {
+		return ErrInvalidMove
+	}
+	s.Board[m.Cell] = s.Turn
+	s.LastMove = m.Cell
+	s.MoveCount++
+	s.Turn = Opposite(s.Turn)
+	return nil
+}
+
+// Outcome смотрит только на четыре линии через последний ход: более ранняя
+// победа уже закончила бы партию.
+func (g Gomoku) Outcome(s *State) Outcome {
+	if s.LastMove < 0 {
+		return Outcome{}
+	}
+	if line := runThrough(s.Board, g.Size, s.LastMove, g.WinLength); line != nil {
+		return Outcome{Finished: true, Winner: s.Board[s.LastMove], Line: line}
+	}
+	if s.MoveCount == len(s.Board) {
+		return Outcome{Finished: true, Winner: Draw}
+	}
+	return Outcome{}
+}
+
+// runThrough возвращает клетки ряда из не менее чем winLength одинаковых
+// знаков через cell на доске size×size или nil, если такого ряда нет.
+func runThrough(board []string, size, cell, winLength int) []int {
+	mark := board[cell]
+	if mark == "" {
+		return nil
+	}
+	row, col := cell/size, cell%size
+
+	for _, d := range directions {
+		start := 0
+		for r, c := row-d[0], col-d[1]; inBounds(r, c, size) && board[r*size+c] == mark; r, c = r-d[0], c-d[1] {
+			start--
+		}
+		end := 0
+		for r, c := row+d[0], col+d[1]; inBounds(r, c, size) && board[r*size+c] == mark; r, c = r+d[0], c+d[1] {
+			end++
+		}
+		if end-start+1 < winLength {
+			continue
+		}
+
+		line := make([]int, 0, end-start+1)
+		for i := start; i <= end; i++ {
+			line = append(line, (row+i*d[0])*size+col+i*d[1])
+		}
+		return line
+	}
+	return nil
+}
+
+func inBounds(row, col, size int) bool {
+	return row >= 0 && row < size && col >= 0 && col < size
+}
@@ -0,0 +1,94 @@
+package rules
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestGomokuWinAroundLastMove(t *testing.T) {
+	g := Gomoku{Size: 9, WinLength: 5}
+	s := g.NewState()
+
+	// X builds an anti-diagonal, O plays along the top row.
+	xs := []int{4*9 + 4, 3*9 + 5, 5*9 + 3, 2*9 + 6, 6*9 + 2}
+	os := []int{0, 1, 2, 3}
+	for i, cell := range xs {
+		if err := g.Apply(s, Move{Cell: cell}); err != nil {
+			t.Fatal(err)
+		}
+		if i < len(os) {
+			if err := g.Apply(s, Move{Cell: os[i]}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	outcome := g.Outcome(s)
+	if !outcome.Finished || outcome.Winner != X {
+		t.Fatalf("expected X to win, got %+v", outcome)
+	}
+	if len(outcome.Line) != 5 {
+		t.Fatalf("expected a line of 5, got %v", outcome.Line)
+	}
+}
+
+// TestGomokuMatchesFullScan plays random games and compares the incremental
+// check against a scan of every line on the board.
+func TestGomokuMatchesFullScan(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, g := range []Gomoku{{Size: 4, WinLength: 4}, {Size: 6, WinLength: 4}, {Size: 9, WinLength: 5}} {
+		for game := 0; game < 200; game++ {
+			s := g.NewState()
+			for {
+				moves := g.LegalMoves(s)
+				if err := g.Apply(s, moves[r.Intn(len(moves))]); err != nil {
+					t.Fatal(err)
+				}
+
+				outcome := g.Outcome(s)
+				winner := scanWinner(s.Board, g.Size, g.WinLength)
+				if winner != "" && outcome.Winner != winner {
+					t.Fatalf("%+v: scan found %s, incremental check got %+v", g, winner, outcome)
+				}
+				if winner == "" && outcome.Finished && outcome.Winner != Draw {
+					t.Fatalf("%+v: incremental check reported %+v on a board without a line", g, outcome)
+				}
+				if outcome.Finished {
+					break
+				}
+			}
+		}
+	}
+}
+
+func scanWinner(board []string, size, winLength int) string {
+	for cell := range board {
+		if line := runThrough(board, size, cell, winLength); line != nil {
+			return board[cell]
+		}
+	}
+	return ""
+}
+
+func TestNewValidatesOptions(t *testing.T) {
+	invalid := []Options{
+		{Variant: VariantGomoku, Size: 2, WinLength: 3},
+		{Variant: VariantGomoku, Size: 20, WinLength: 5},
+		{Variant: VariantGomoku, Size: 4, WinLength: 5},
+		{Variant: VariantGomoku, Size: 9, WinLength: 2},
+		{Variant: "unknown"},
+	}
+	for _, opts := range invalid {
+		if _, err := New(opts); err == nil {
+			t.Errorf("expected %+v to be rejected", opts)
+		}
+	}
+
+	r, err := New(Options{Variant: VariantGomoku, Size: 15, WinLength: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Options().Key(); got != "gomoku:15x5" {
+		t.Errorf("unexpected key %s", got)
+	}
+}
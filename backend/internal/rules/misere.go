@@ -0,0 +1,21 @@
+package rules
+
+// Misere играется на классической доске, но собравший три в ряд
+// проигрывает. В Outcome.Line - проигрышная линия.
+type Misere struct {
+	Classic
+}
+
+func (Misere) Options() Options {
+	return Options{Variant: VariantMisere, Size: 3, WinLength: 3}
+}
+
+func (Misere) Outcome(s *State) Outcome {
+	if mark, line := findLine(s.Board); mark != "" {
+		return Outcome{Finished: true, Winner: Opposite(mark), Line: line}
+	}
+	if s.MoveCount == len(s.Board) {
+		return Outcome{Finished: true, Winner: Draw}
+	}
+	return Outcome{}
+}
@@ -0,0 +1,157 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	X    = "X"
+	O    = "O"
+	Draw = "draw"
+)
+
+const (
+	VariantClassic  = "classic"
+	VariantGomoku   = "gomoku"
+	VariantUltimate = "ultimate"
+	VariantMisere   = "misere"
+	VariantWild     = "wild"
+)
+
+const (
+	MinBoardSize = 3
+	MaxBoardSize = 19
+)
+
+var (
+	ErrInvalidMove = errors.New("invalid move")
+	// ErrInvalidOptions оборачивает все ошибки New.
+	ErrInvalidOptions = errors.New("invalid game options")
+)
+
+// Options - вариант игры и его параметры. Size и WinLength имеют смысл
+// только для вариантов с настраиваемой доской.
+type Options struct {
+	Variant   string `json:"variant"`
+	Size      int    `json:"size,omitempty"`
+	WinLength int    `json:"win_length,omitempty"`
+}
+
+// Key - ключ настроек: по нему группируются игроки, которые ждут одинаковую партию.
+func (o Options) Key() string {
+	if o.Variant == VariantGomoku {
+		return fmt.Sprintf("%s:%dx%d", o.Variant, o.Size, o.WinLength)
+	}
+	return o.Variant
+}
+
+// New проверяет настройки и возвращает подходящие правила.
+func New(opts Options) (GameRules, error) {
+	switch opts.Variant {
+	case "", VariantClassic:
+		return Classic{}, nil
+	case VariantGomoku:
+		if opts.Size < MinBoardSize || opts.Size > MaxBoardSize {
+			return nil, fmt.Errorf("%w: board size must be between %d and %d", ErrInvalidOptions, MinBoardSize, MaxBoardSize)
+		}
+		if opts.WinLength < 3 || opts.WinLength > opts.Size {
+			return nil, fmt.Errorf("%w: win length must be between 3 and the board size", ErrInvalidOptions)
+		}
+		if opts.Size == 3 {
+			return Classic{}, nil
+		}
+		return Gomoku{Size: opts.Size, WinLength: opts.WinLength}, nil
+	case VariantUltimate:
+		return Ultimate{}, nil
+	case VariantMisere:
+		return Misere{}, nil
+	case VariantWild:
+		return Wild{}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown variant %s", ErrInvalidOptions, opts.Variant)
+	}
+}
+
+// Move - один ход на доске. Symbol читают только варианты, где игрок сам
+// выбирает, какой знак поставить.
+type Move struct {
+	Cell   int
+	Symbol string
+}
+
+// State - изменяемая позиция партии. Board - доска построчно в одном срезе,
+// "" - пустая клетка.
+type State struct {
+	Board     []string
+	Turn      string
+	LastMove  int
+	MoveCount int
+}
+
+func NewState(cells int) *State {
+	return &State{
+		Board:    make([]string, cells),
+		Turn:     X,
+		LastMove: -1,
+	}
+}
+
+func (s *State) Clone() *State {
+	board := make([]string, len(s.Board))
+	copy(board, s.Board)
+	return &State{
+		Board:     board,
+		Turn:      s.Turn,
+		LastMove:  s.LastMove,
+		MoveCount: s.MoveCount,
+	}
+}
+
+// LastMover возвращает сторону, сделавшую последний ход.
+func (s *State) LastMover() string {
+	return Opposite(s.Turn)
+}
+
+// Outcome описывает, закончена ли партия. Winner - X, O или Draw, и это
+// всегда сторона, а не знак на доске.
+type Outcome struct {
+	Finished bool
+	Winner   string
+	Line     []int
+}
+
+// GameRules описывает вариант крестиков-ноликов. Outcome проверяется после
+// каждого хода, поэтому реализации могут смотреть только на окрестность LastMove.
+type GameRules interface {
+	Options() Options
+	NewState() *State
+	LegalMoves(s *State) []Move
+	Apply(s *State, m Move) error
+	Outcome(s *State) Outcome
+}
+
+// SubBoards реализуют варианты, состоящие из нескольких малых досок.
+// ForcedBoard возвращает -1, если следующий ход можно сделать на любой
+// открытой малой доске.
+type SubBoards interface {
+	SubWinners(s *State) []string
+	ForcedBoard(s *State) int
+}
+
+func Opposite(s string) string {
+	if s == X {
+		return O
+	}
+	return X
+}
+
+func emptyCells(board []string) []Move {
+	moves := make([]Move, 0, len(board))
+	for i, cell := range board {
+		if cell == "" {
+			moves = append(moves, Move{Cell: i})
+		}
+	}
+	return moves
+}
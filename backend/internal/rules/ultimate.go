@@ -0,0 +1,110 @@
+package rules
+
+// Ultimate - поле 3x3 из классических досок. Клетки нумеруются по доскам
+// (cell = board*9 + local), и номер клетки хода внутри доски отправляет
+// соперника на малую доску с тем же номером. В Outcome.Line - номера малых
+// досок, а не клетки.
+type Ultimate struct{}
+
+func (Ultimate) Options() Options {
+	return Options{Variant: VariantUltimate, Size: 9, WinLength: 3}
+}
+
+func (Ultimate) NewState() *State {
+	return NewState(81)
+}
+
+func (u Ultimate) LegalMoves(s *State) []Move {
+	if forced := u.ForcedBoard(s); forced >= 0 {
+		return emptyCellsIn(s.Board, forced)
+	}
+
+	moves := make([]Move, 0, len(s.Board)-s.MoveCount)
+	for sub := 0; sub < 9; sub++ {
+		if subBoardWinner(s.Board, sub) == "" {
+			moves = append(moves, emptyCellsIn(s.Board, sub)...)
+		}
+	}
+	return moves
+}
+
+func (u Ultimate) Apply(s *State, m Move) error {
+	if m.Cell < 0 || m.Cell >= len(s.Board) || s.Board[m.Cell] != "" {
+		return ErrInvalidMove
+	}
+	sub := m.Cell / 9
+	if forced := u.ForcedBoard(s); forced >= 0 && sub != forced {
+		return ErrInvalidMove
+	}
+	if subBoardWinner(s.Board, sub) != "" {
+		return ErrInvalidMove
+	}
+
+	s.Board[m.Cell] = s.Turn
+	s.LastMove = m.Cell
+	s.MoveCount++
+	s.Turn = Opposite(s.Turn)
+	return nil
+}
+
+func (u Ultimate) Outcome(s *State) Outcome {
+	winners := u.SubWinners(s)
+	for _, line := range ClassicLines {
+		a, b, c := winners[line[0]], winners[line[1]], winners[line[2]]
+		if (a == X || a == O) && a == b && b == c {
+			return Outcome{Finished: true, Winner: a, Line: line}
+		}
+	}
+	for _, w := range winners {
+		if w == "" {
+			return Outcome{}
+		}
+	}
+	return Outcome{Finished: true, Winner: Draw}
+}
+
+// SubWinners возвращает X, O или Draw для решенных малых досок и "" для
+// тех, где игра еще идет.
+func (Ultimate) SubWinners(s *State) []string {
+	winners := make([]string, 9)
+	for sub := range winners {
+		winners[sub] = subBoardWinner(s.Board, sub)
+	}
+	return winners
+}
+
+// ForcedBoard возвращает малую доску, на которой нужно сделать следующий
+// ход, или -1, если можно выбрать любую, где игра еще идет.
+func (Ultimate) ForcedBoard(s *State) int {
+	if s.LastMove < 0 {
+		return -1
+	}
+	forced := s.LastMove % 9
+	if subBoardWinner(s.Board, forced) != "" {
+		return -1
+	}
+	return forced
+}
+
+func subBoardWinner(board []string, sub int) string {
+	cells := board[sub*9 : sub*9+9]
+	if mark, _ := findLine(cells); mark != "" {
+		return mark
+	}
+	for _, cell := range cells {
+		if cell == "" {
+			return ""
+		}
+	}
+	return Draw
+}
+
+func emptyCellsIn(board []string, sub int) []Move {
+	moves := make([]Move, 0, 9)
+	for i := sub * 9; i < sub*9+9; i++ {
+		if board[i] == "" {
+			moves = append(moves, Move{Cell: i})
+		}
+	}
+	return moves
+}
@@ -0,0 +1,55 @@
+package rules
+
+import "testing"
+
+func TestUltimateForcedBoard(t *testing.T) {
+	u := Ultimate{}
+	s := u.NewState()
+
+	// X plays the top-right cell of the center board, so O is sent to board 2.
+	if err := u.Apply(s, Move{Cell: 4*9 + 2}); err != nil {
+		t.Fatal(err)
+	}
+	if got := u.ForcedBoard(s); got != 2 {
+		t.Fatalf("expected forced board 2, got %d", got)
+	}
+	if err := u.Apply(s, Move{Cell: 0}); err != ErrInvalidMove {
+		t.Fatalf("expected move outside the forced board to be rejected, got %v", err)
+	}
+	for _, m := range u.LegalMoves(s) {
+		if m.Cell/9 != 2 {
+			t.Fatalf("legal move %d is outside board 2", m.Cell)
+		}
+	}
+}
+
+func TestUltimateWinnerAndFreeMove(t *testing.T) {
+	u := Ultimate{}
+	s := u.NewState()
+	// X has won boards 0 and 1 and is sent to board 0 by O's last move.
+	s.Board[0], s.Board[1], s.Board[2] = X, X, X
+	s.Board[9], s.Board[10], s.Board[11] = X, X, X
+	s.Board[27] = O
+	s.MoveCount = 7
+	s.LastMove = 27 // sends X to board 0, which is already won
+	s.Turn = X
+
+	if got := u.ForcedBoard(s); got != -1 {
+		t.Fatalf("expected a free move after being sent to a won board, got %d", got)
+	}
+	if err := u.Apply(s, Move{Cell: 5}); err != ErrInvalidMove {
+		t.Fatalf("expected move into a won board to be rejected, got %v", err)
+	}
+	if err := u.Apply(s, Move{Cell: 18}); err != nil {
+		t.Fatal(err)
+	}
+	s.Board[19], s.Board[20] = X, X
+
+	outcome := u.Outcome(s)
+	if !outcome.Finished || outcome.Winner != X {
+		t.Fatalf("expected X to win, got %+v", outcome)
+	}
+	if len(outcome.Line) != 3 || outcome.Line[0] != 0 || outcome.Line[2] != 2 {
+		t.Fatalf("expected the top row of boards, got %v", outcome.Line)
+	}
+}
@@ -0,0 +1,45 @@
+package rules
+
+// Wild - каждый игрок своим ходом ставит любой знак. Выигрывает тот, кто
+// собрал линию, из каких бы знаков она ни состояла.
+type Wild struct {
+	Classic
+}
+
+func (Wild) Options() Options {
+	return Options{Variant: VariantWild, Size: 3, WinLength: 3}
+}
+
+func (Wild) LegalMoves(s *State) []Move {
+	moves := make([]Move, 0, 2*(len(s.Board)-s.MoveCount))
+	for i, cell := range s.Board {
+		if cell == "" {
+			moves = append(moves, Move{Cell: i, Symbol: X}, Move{Cell: i, Symbol: O})
+		}
+	}
+	return moves
+}
+
+func (Wild) Apply(s *State, m Move) error {
+	if m.Symbol != X && m.Symbol != O {
+		return ErrInvalidMove
+	}
+	if m.Cell < 0 || m.Cell >= len(s.Board) || s.Board[m.Cell] != "" {
+		return ErrInvalidMove
+	}
+	s.Board[m.Cell] = m.Symbol
+	s.LastMove = m.Cell
+	s.MoveCount++
+	s.Turn = Opposite(s.Turn)
+	return nil
+}
+
+func (Wild) Outcome(s *State) Outcome {
+	if mark, line := findLine(s.Board); mark != "" {
+		return Outcome{Finished: true, Winner: s.LastMover(), Line: line}
+	}
+	if s.MoveCount == len(s.Board) {
+		return Outcome{Finished: true, Winner: Draw}
+	}
+	return Outcome{}
+}
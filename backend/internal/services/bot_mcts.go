@@ -0,0 +1,312 @@
+package services
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"tictactoe/internal/rules"
+)
+
+const (
+	// defaultMCTSPlayouts и defaultMCTSBudget - бюджет хода бота по умолчанию
+	defaultMCTSPlayouts = 50000
+	defaultMCTSBudget   = 800 * time.Millisecond
+	// mctsClockCheck - как часто (в симуляциях) сверяться с часами
+	mctsClockCheck = 64
+	// gomokuReach - в гомоку рассматриваются только клетки не дальше
+	// этого расстояния от уже занятых
+	gomokuReach = 2
+)
+
+// MCTSConfig задает бюджет поиска. Поиск останавливается, как только
+// исчерпан любой из лимитов; нулевой лимит не действует. Если оба нулевые,
+// используется defaultMCTSPlayouts.
+type MCTSConfig struct {
+	Playouts int
+	Budget   time.Duration
+	// Exploration - константа UCT, по умолчанию sqrt(2)
+	Exploration float64
+	// Seed задает генератор случайных чисел; с одинаковым Seed и без
+	// лимита по времени поиск воспроизводим
+	Seed int64
+}
+
+// MCTSEngine - поиск по дереву методом Монте-Карло (UCT). Ему нужны только
+// правила варианта, поэтому он играет на любой доске, в том числе там, где
+// полный перебор невозможен. Движок не безопасен для одновременного
+// использования из нескольких горутин.
+type MCTSEngine struct {
+	config MCTSConfig
+	rand   *rand.Rand
+}
+
+func NewMCTSEngine(config MCTSConfig) *MCTSEngine {
+	if config.Playouts <= 0 && config.Budget <= 0 {
+		config.Playouts = defaultMCTSPlayouts
+	}
+	if config.Exploration <= 0 {
+		config.Exploration = math.Sqrt2
+	}
+	return &MCTSEngine{config: config, rand: rand.New(rand.NewSource(config.Seed))}
+}
+
+// mctsNode - позиция в дереве поиска. wins считаются с точки зрения
+// стороны mover, которая сделала ход move в эту позицию.
+type mctsNode struct {
+	parent   *mctsNode
+	move     rules.Move
+	mover    string
+	state    *rules.State
+	outcome  rules.Outcome
+	untried  []rules.Move
+	children []*mctsNode
+	visits   int
+	wins     float64
+}
+
+func newMCTSNode(gameRules rules.GameRules, parent *mctsNode, move rules.Move, state *rules.State) *mctsNode {
+	node := &mctsNode{
+		parent:  parent,
+		move:    move,
+		mover:   state.LastMover(),
+		state:   state,
+		outcome: gameRules.Outcome(state),
+	}
+	if !node.outcome.Finished {
+		node.untried = candidateMoves(gameRules, state)
+	}
+	return node
+}
+
+// candidateMoves - ходы, которые стоит исследовать. На большой доске гомоку
+// ходы вдали от камней почти никогда не лучшие, а без отсечения дерево
+// слишком широкое для бюджета одного хода.
+func candidateMoves(gameRules rules.GameRules, state *rules.State) []rules.Move {
+	g, ok := gameRules.(rules.Gomoku)
+	if !ok || state.MoveCount == 0 {
+		if ok {
+			return []rules.Move{{Cell: g.Size/2*g.Size + g.Size/2}}
+		}
+		return gameRules.LegalMoves(state)
+	}
+
+	var moves []rules.Move
+	for cell, mark := range state.Board {
+		if mark == "" && nearStone(state.Board, g.Size, cell) {
+			moves = append(moves, rules.Move{Cell: cell})
+		}
+	}
+	return moves
+}
+
+func nearStone(board []string, size, cell int) bool {
+	row, col := cell/size, cell%size
+	for r := max(row-gomokuReach, 0); r <= min(row+gomokuReach, size-1); r++ {
+		for c := max(col-gomokuReach, 0); c <= min(col+gomokuReach, size-1); c++ {
+			if board[r*size+c] != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (e *MCTSEngine) BestMove(gameRules rules.GameRules, state *rules.State) rules.Move {
+	moves := candidateMoves(gameRules, state)
+	if gameRules.Outcome(state).Finished || len(moves) == 0 {
+		return rules.Move{Cell: -1}
+	}
+	if len(moves) == 1 {
+		return moves[0]
+	}
+	if move, ok := forcedMove(gameRules, state, moves); ok {
+		return move
+	}
+
+	root := e.search(gameRules, state)
+	// Самый посещаемый ход надежнее хода с лучшей средней оценкой
+	best := root.children[0]
+	for _, child := range root.children[1:] {
+		if child.visits > best.visits {
+			best = child
+		}
+	}
+	return best.move
+}
+
+// ScoreMoves оценивает исследованные ходы долей выигранных через них
+// симуляций. Выигрыш в один ход всегда получает оценку 1.
+func (e *MCTSEngine) ScoreMoves(gameRules rules.GameRules, state *rules.State) []ScoredMove {
+	moves := candidateMoves(gameRules, state)
+	if gameRules.Outcome(state).Finished || len(moves) == 0 {
+		return nil
+	}
+	forced, isForced := forcedMove(gameRules, state, moves)
+
+	root := e.search(gameRules, state)
+	scored := make([]ScoredMove, 0, len(root.children))
+	for _, child := range root.children {
+		score := 2*child.wins/float64(child.visits) - 1
+		if isForced && child.move == forced && winsAt(gameRules, state, forced) {
+			score = 1
+		}
+		scored = append(scored, ScoredMove{Move: child.move, Score: score})
+	}
+	return scored
+}
+
+// search строит дерево из позиции state, пока не исчерпан бюджет.
+func (e *MCTSEngine) search(gameRules rules.GameRules, state *rules.State) *mctsNode {
+	root := newMCTSNode(gameRules, nil, rules.Move{Cell: -1}, state.Clone())
+
+	var deadline time.Time
+	if e.config.Budget > 0 {
+		deadline = time.Now().Add(e.config.Budget)
+	}
+	for i := 0; e.config.Playouts <= 0 || i < e.config.Playouts; i++ {
+		if !deadline.IsZero() && i%mctsClockCheck == 0 && i > 0 && time.Now().After(deadline) {
+			break
+		}
+		node := e.selectNode(root)
+		node = e.expand(gameRules, node)
+		winner := e.playout(gameRules, node)
+		for ; node != nil; node = node.parent {
+			node.visits++
+			switch winner {
+			case node.mover:
+				node.wins++
+			case rules.Draw:
+				node.wins += 0.5
+			}
+		}
+	}
+	return root
+}
+
+// selectNode спускается по дереву, пока не встретит узел с неиспробованными
+// ходами или конец партии.
+func (e *MCTSEngine) selectNode(node *mctsNode) *mctsNode {
+	for len(node.untried) == 0 && len(node.children) > 0 {
+		logVisits := math.Log(float64(node.visits))
+		var best *mctsNode
+		bestScore := math.Inf(-1)
+		for _, child := range node.children {
+			score := child.wins/float64(child.visits) +
+				e.config.Exploration*math.Sqrt(logVisits/float64(child.visits))
+			if score > bestScore {
+				best, bestScore = child, score
+			}
+		}
+		node = best
+	}
+	return node
+}
+
+// expand добавляет узлу случайного еще не испробованного потомка.
+func (e *MCTSEngine) expand(gameRules rules.GameRules, node *mctsNode) *mctsNode {
+	if len(node.untried) == 0 {
+		return node
+	}
+	i := e.rand.Intn(len(node.untried))
+	move := node.untried[i]
+	node.untried[i] = node.untried[len(node.untried)-1]
+	node.untried = node.untried[:len(node.untried)-1]
+
+	next := node.state.Clone()
+	if err := gameRules.Apply(next, move); err != nil {
+		return node
+	}
+	child := newMCTSNode(gameRules, node, move, next)
+	node.children = append(node.children, child)
+	return child
+}
+
+// playout доигрывает партию случайными ходами и возвращает победителя.
+func (e *MCTSEngine) playout(gameRules rules.GameRules, node *mctsNode) string {
+	if node.outcome.Finished {
+		return node.outcome.Winner
+	}
+	state := node.state.Clone()
+	if placesOnEmptyCells(gameRules) {
+		return e.placementPlayout(gameRules, state)
+	}
+	for {
+		moves := gameRules.LegalMoves(state)
+		if len(moves) == 0 {
+			return rules.Draw
+		}
+		if err := gameRules.Apply(state, moves[e.rand.Intn(len(moves))]); err != nil {
+			return rules.Draw
+		}
+		if outcome := gameRules.Outcome(state); outcome.Finished {
+			return outcome.Winner
+		}
+	}
+}
+
+// forcedMove находит выигрыш в один ход, а если его нет - единственную
+// защиту от выигрыша соперника в один ход. Случайные симуляции видят такие
+// ходы плохо, особенно на большой доске.
+func forcedMove(gameRules rules.GameRules, state *rules.State, moves []rules.Move) (rules.Move, bool) {
+	if !placesOnEmptyCells(gameRules) {
+		return rules.Move{}, false
+	}
+	for _, move := range moves {
+		if winsAt(gameRules, state, move) {
+			return move, true
+		}
+	}
+	opponent := state.Clone()
+	opponent.Turn = rules.Opposite(state.Turn)
+	for _, move := range moves {
+		if winsAt(gameRules, opponent, move) {
+			return move, true
+		}
+	}
+	return rules.Move{}, false
+}
+
+// winsAt сообщает, что ход сразу выигрывает партию для стороны, которая ходит.
+func winsAt(gameRules rules.GameRules, state *rules.State, move rules.Move) bool {
+	next := state.Clone()
+	if err := gameRules.Apply(next, move); err != nil {
+		return false
+	}
+	outcome := gameRules.Outcome(next)
+	return outcome.Finished && outcome.Winner == state.Turn
+}
+
+// placesOnEmptyCells сообщает, что в варианте можно ходить в любую пустую
+// клетку своей меткой. Для таких вариантов симуляция обходится без
+// LegalMoves на каждом ходу.
+func placesOnEmptyCells(gameRules rules.GameRules) bool {
+	switch gameRules.(type) {
+	case rules.Classic, rules.Misere, rules.Gomoku:
+		return true
+	}
+	return false
+}
+
+func (e *MCTSEngine) placementPlayout(gameRules rules.GameRules, state *rules.State) string {
+	empty := make([]int, 0, len(state.Board)-state.MoveCount)
+	for i, cell := range state.Board {
+		if cell == "" {
+			empty = append(empty, i)
+		}
+	}
+	for len(empty) > 0 {
+		i := e.rand.Intn(len(empty))
+		cell := empty[i]
+		empty[i] = empty[len(empty)-1]
+		empty = empty[:len(empty)-1]
+
+		if err := gameRules.Apply(state, rules.Move{Cell: cell}); err != nil {
+			return rules.Draw
+		}
+		if outcome := gameRules.Outcome(state); outcome.Finished {
+			return outcome.Winner
+		}
+	}
+	return rules.Draw
+}
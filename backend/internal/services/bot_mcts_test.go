@@ -0,0 +1,169 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"tictactoe/internal/rules"
+)
+
+// play applies the moves in order, alternating seats from X.
+func play(t testing.TB, gameRules rules.GameRules, cells ...int) *rules.State {
+	t.Helper()
+	state := gameRules.NewState()
+	for _, cell := range cells {
+		if err := gameRules.Apply(state, rules.Move{Cell: cell}); err != nil {
+			t.Fatalf("move %d: %v", cell, err)
+		}
+	}
+	return state
+}
+
+func TestMCTSIsDeterministic(t *testing.T) {
+	gomoku := rules.Gomoku{Size: 9, WinLength: 5}
+	state := play(t, gomoku, 40, 41, 31)
+
+	first := NewMCTSEngine(MCTSConfig{Playouts: 2000, Seed: 7}).BestMove(gomoku, state)
+	for i := 0; i < 3; i++ {
+		if move := NewMCTSEngine(MCTSConfig{Playouts: 2000, Seed: 7}).BestMove(gomoku, state); move != first {
+			t.Fatalf("run %d chose %+v, first run chose %+v", i, move, first)
+		}
+	}
+}
+
+func TestMCTSTakesWinsAndBlocks(t *testing.T) {
+	gomoku := rules.Gomoku{Size: 15, WinLength: 5}
+	tests := []struct {
+		name      string
+		gameRules rules.GameRules
+		state     *rules.State
+		want      int
+	}{
+		// X: 0 1, O: 3 4 - X completes the top row
+		{"classic win", rules.Classic{}, play(t, rules.Classic{}, 0, 3, 1, 4), 2},
+		// X: 0 8, O: 4 2 - O threatens the 2-4-6 diagonal
+		{"classic block", rules.Classic{}, play(t, rules.Classic{}, 0, 4, 8, 2), 6},
+		// X has four in a row on row 7, columns 3..6, closed by O on the right
+		{"gomoku win", gomoku, play(t, gomoku, 108, 0, 109, 1, 110, 2, 111, 112), 107},
+		// O has four in a row on row 0, columns 0..3 open at 4
+		{"gomoku block", gomoku, play(t, gomoku, 112, 0, 140, 1, 200, 2, 224, 3), 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			move := NewMCTSEngine(MCTSConfig{Playouts: 1000, Seed: 1}).BestMove(tt.gameRules, tt.state)
+			if move.Cell != tt.want {
+				t.Fatalf("expected cell %d, got %d", tt.want, move.Cell)
+			}
+		})
+	}
+}
+
+// TestMCTSMatchesMinimax checks on a deterministic sample of 3x3 positions
+// that MCTS picks a move keeping the game-theoretic value found by the
+// exhaustive search.
+func TestMCTSMatchesMinimax(t *testing.T) {
+	gameRules := rules.Classic{}
+	positions := reachablePositions(gameRules)
+
+	checked := 0
+	for i := 0; i < len(positions); i += 37 {
+		state := positions[i]
+		engine := NewMCTSEngine(MCTSConfig{Playouts: 20000, Seed: int64(i)})
+		move := engine.BestMove(gameRules, state)
+
+		want := moveValue(gameRules, state, searchEngine{}.BestMove(gameRules, state))
+		if got := moveValue(gameRules, state, move); got != want {
+			t.Errorf("position %v (%s to move): cell %d has value %d, best is %d",
+				state.Board, state.Turn, move.Cell, got, want)
+		}
+		checked++
+	}
+	if checked < 50 {
+		t.Fatalf("only %d positions checked", checked)
+	}
+}
+
+// moveValue returns -1, 0 or 1: the result of the move with perfect play
+// for the side to move.
+func moveValue(gameRules rules.GameRules, state *rules.State, move rules.Move) int {
+	next := state.Clone()
+	if err := gameRules.Apply(next, move); err != nil {
+		return -2
+	}
+	return -sign(solve(gameRules, next))
+}
+
+// reachablePositions lists every non-terminal position in a fixed order.
+func reachablePositions(gameRules rules.GameRules) []*rules.State {
+	seen := make(map[string]bool)
+	var positions []*rules.State
+	var walk func(state *rules.State)
+	walk = func(state *rules.State) {
+		key := positionKey(state)
+		if seen[key] || gameRules.Outcome(state).Finished {
+			return
+		}
+		seen[key] = true
+		positions = append(positions, state)
+		for _, move := range gameRules.LegalMoves(state) {
+			next := state.Clone()
+			if err := gameRules.Apply(next, move); err == nil {
+				walk(next)
+			}
+		}
+	}
+	walk(gameRules.NewState())
+	return positions
+}
+
+func TestMCTSRespectsBudget(t *testing.T) {
+	gomoku := rules.Gomoku{Size: 19, WinLength: 5}
+	state := play(t, gomoku, 180, 181, 161)
+	engine := NewMCTSEngine(MCTSConfig{Budget: 100 * time.Millisecond, Seed: 1})
+
+	start := time.Now()
+	move := engine.BestMove(gomoku, state)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("search took %v with a 100ms budget", elapsed)
+	}
+	if err := gomoku.Apply(state.Clone(), move); err != nil {
+		t.Fatalf("illegal move %+v: %v", move, err)
+	}
+}
+
+func TestHardBotPlaysGomoku(t *testing.T) {
+	gomoku := rules.Gomoku{Size: 15, WinLength: 5}
+	b := &BotService{mcts: NewMCTSEngine(MCTSConfig{Playouts: 500, Seed: 3})}
+	state := gomoku.NewState()
+
+	for i := 0; i < 6; i++ {
+		move := b.getHardMove(gomoku, state)
+		if err := gomoku.Apply(state, move); err != nil {
+			t.Fatalf("move %d: illegal %+v: %v", i, move, err)
+		}
+	}
+}
+
+func BenchmarkSearchClassic(b *testing.B) {
+	state := rules.Classic{}.NewState()
+	for i := 0; i < b.N; i++ {
+		searchEngine{}.BestMove(rules.Classic{}, state)
+	}
+}
+
+func BenchmarkMCTSClassic(b *testing.B) {
+	state := rules.Classic{}.NewState()
+	engine := NewMCTSEngine(MCTSConfig{Playouts: 20000, Seed: 1})
+	for i := 0; i < b.N; i++ {
+		engine.BestMove(rules.Classic{}, state)
+	}
+}
+
+func BenchmarkMCTSGomoku(b *testing.B) {
+	gomoku := rules.Gomoku{Size: 15, WinLength: 5}
+	state := play(b, gomoku, 112, 113, 97)
+	engine := NewMCTSEngine(MCTSConfig{Playouts: 5000, Seed: 1})
+	for i := 0; i < b.N; i++ {
+		engine.BestMove(gomoku, state)
+	}
+}
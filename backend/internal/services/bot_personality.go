@@ -0,0 +1,149 @@
+package services
+
+import (
+	"sort"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/rules"
+)
+
+// Стили игры характеров ботов
+const (
+	// StyleAggressive - строит вилки: ходы, после которых угроз больше одной
+	StyleAggressive = "aggressive"
+	// StyleDefensive - закрывает угрозы и не дает сопернику построить вилку
+	StyleDefensive = "defensive"
+	// StyleTrickster - ставит ловушки: ходы, на которые легко ответить неверно
+	StyleTrickster = "trickster"
+)
+
+// styleWeight - вес стиля в оценке хода. Оценки точного перебора у
+// выигрыша, ничьей и проигрыша отличаются почти на 1, поэтому стиль
+// выбирает только среди ходов с одинаковым результатом и не делает
+// лучшего бота слабее.
+const styleWeight = 0.25
+
+// styledMoves - сколько лучших ходов оценивает стиль. Остальные бот почти
+// не выбирает, а признаки стиля на большой доске дорогие.
+const styledMoves = 8
+
+// BotPersonalities - характеры ботов для find_bot_match и GET /api/bots.
+// Дебютная книга характера - books/<id>.book.
+var BotPersonalities = []models.BotPersonality{
+	{
+		ID:          "blaze",
+		Name:        "Blaze",
+		Avatar:      "🔥",
+		Skin:        "skin_neon",
+		Description: "Attacks from the first move and loves a fork.",
+		Style:       StyleAggressive,
+		Elo:         1500,
+	},
+	{
+		ID:          "warden",
+		Name:        "Warden",
+		Avatar:      "🛡️",
+		Skin:        "skin_retro",
+		Description: "Blocks every line and waits for you to overreach.",
+		Style:       StyleDefensive,
+		Elo:         1400,
+	},
+	{
+		ID:          "jinx",
+		Name:        "Jinx",
+		Avatar:      "🃏",
+		Skin:        "skin_gold",
+		Description: "Plays quiet moves that leave you plenty of ways to go wrong.",
+		Style:       StyleTrickster,
+		Elo:         1600,
+	},
+}
+
+// FindBotPersonality ищет характер по id.
+func FindBotPersonality(id string) (*models.BotPersonality, bool) {
+	for i := range BotPersonalities {
+		if BotPersonalities[i].ID == id {
+			personality := BotPersonalities[i]
+			return &personality, true
+		}
+	}
+	return nil, false
+}
+
+// applyStyle добавляет к оценкам лучших ходов бонус стиля.
+// Порядок scored меняется.
+func applyStyle(gameRules rules.GameRules, state *rules.State, scored []ScoredMove, style string) {
+	if style == "" {
+		return
+	}
+	sort.SliceStable(scored, func(i, j int) bool { return scored[i].Score > scored[j].Score })
+	for i := range scored[:min(len(scored), styledMoves)] {
+		scored[i].Score += styleWeight * styleBonus(gameRules, state, scored[i].Move, style)
+	}
+}
+
+// styleBonus - насколько ход в духе стиля, от 0 до 1.
+func styleBonus(gameRules rules.GameRules, state *rules.State, move rules.Move, style string) float64 {
+	next := state.Clone()
+	if err := gameRules.Apply(next, move); err != nil || gameRules.Outcome(next).Finished {
+		return 0
+	}
+	switch style {
+	case StyleAggressive:
+		// Одна угроза - половина бонуса, вилка - весь
+		return float64(min(threats(gameRules, passTurn(next)), 2)) / 2
+	case StyleDefensive:
+		bonus := 0.0
+		if threats(gameRules, next) < threats(gameRules, passTurn(state)) {
+			bonus = 0.5
+		}
+		forks := countReplies(gameRules, next, func(reply *rules.State) bool {
+			return threats(gameRules, passTurn(reply)) >= 2
+		})
+		return bonus + 0.5*(1-forks)
+	case StyleTrickster:
+		// Доля ответов, после которых бот выигрывает следующим ходом
+		return countReplies(gameRules, next, func(reply *rules.State) bool {
+			return threats(gameRules, reply) > 0
+		})
+	}
+	return 0
+}
+
+// countReplies - доля ответов соперника, после которых выполняется match.
+// Партии, закончившиеся ответом, не считаются.
+func countReplies(gameRules rules.GameRules, state *rules.State, match func(*rules.State) bool) float64 {
+	replies := candidateMoves(gameRules, state)
+	if len(replies) == 0 {
+		return 0
+	}
+	matched := 0
+	for _, reply := range replies {
+		next := state.Clone()
+		if gameRules.Apply(next, reply) != nil || gameRules.Outcome(next).Finished {
+			continue
+		}
+		if match(next) {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(replies))
+}
+
+// threats - сколько ходов сразу выигрывают для стороны, которая ходит.
+func threats(gameRules rules.GameRules, state *rules.State) int {
+	n := 0
+	for _, move := range candidateMoves(gameRules, state) {
+		if winsAt(gameRules, state, move) {
+			n++
+		}
+	}
+	return n
+}
+
+// passTurn - та же позиция, но ход у другой стороны.
+func passTurn(state *rules.State) *rules.State {
+	next := state.Clone()
+	next.Turn = rules.Opposite(state.Turn)
+	return next
+}
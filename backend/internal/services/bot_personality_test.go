@@ -0,0 +1,154 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/rules"
+)
+
+func TestEveryPersonalityHasABook(t *testing.T) {
+	for _, p := range BotPersonalities {
+		if book := openingBook(p.ID); book.Size() == 0 {
+			t.Errorf("%s has no opening book", p.ID)
+		}
+		if p.Elo < models.MinBotElo || p.Elo > models.MaxBotElo {
+			t.Errorf("%s plays at %d", p.ID, p.Elo)
+		}
+	}
+}
+
+// TestBookMovesKeepTheResult checks every book line on the solved variants:
+// a book reply must not change the game-theoretic result of the position.
+func TestBookMovesKeepTheResult(t *testing.T) {
+	for _, gameRules := range []rules.GameRules{rules.Classic{}, rules.Misere{}, rules.Wild{}} {
+		positions := reachablePositions(gameRules)
+		for _, p := range BotPersonalities {
+			book := openingBook(p.ID)
+			found := 0
+			for _, state := range positions {
+				moves := book.Moves(gameRules, state)
+				if len(moves) == 0 {
+					continue
+				}
+				found++
+				value := sign(solve(gameRules, state))
+				for _, move := range moves {
+					next := state.Clone()
+					if err := gameRules.Apply(next, move); err != nil {
+						t.Fatal(err)
+					}
+					if got := -sign(solve(gameRules, next)); !gameRules.Outcome(next).Finished && got != value {
+						t.Errorf("%s %s: %d in %v changes the result from %d to %d", p.ID, gameRules.Options().Key(), move.Cell, state.Board, value, got)
+					}
+				}
+			}
+			if want := len(book.positions[gameRules.Options().Key()]); found != want {
+				t.Errorf("%s %s: %d of %d book positions are reachable", p.ID, gameRules.Options().Key(), found, want)
+			}
+		}
+	}
+}
+
+func TestOpeningBookIgnoresMoveOrder(t *testing.T) {
+	book, err := ParseOpeningBook(strings.NewReader("[classic]\n0 4 8 > 2 # comment\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves := book.Moves(rules.Classic{}, play(t, rules.Classic{}, 8, 4, 0))
+	if len(moves) != 1 || moves[0].Cell != 2 {
+		t.Errorf("transposed position got %+v", moves)
+	}
+	if moves := book.Moves(rules.Misere{}, play(t, rules.Misere{}, 0, 4, 8)); moves != nil {
+		t.Errorf("book for classic answered misere with %+v", moves)
+	}
+}
+
+func TestParseOpeningBookRejectsBadLines(t *testing.T) {
+	for _, input := range []string{
+		"> 4",                      // no section
+		"[chess]\n> 4",             // unknown variant
+		"[gomoku:big]\n> 4",        // bad parameters
+		"[classic]\n4",             // no replies separator
+		"[classic]\n4 4 > 0",       // occupied cell
+		"[classic]\n> 9",           // off the board
+		"[classic]\n0 3 1 4 2 > 5", // game already over
+		"[classic]\n4 > 4",         // reply on an occupied cell
+		"[wild]\n> 4",              // wild needs a mark
+		"[classic]\nfoo > 4",       // not a cell
+	} {
+		if _, err := ParseOpeningBook(strings.NewReader(input)); err == nil {
+			t.Errorf("%q parsed without error", input)
+		}
+	}
+}
+
+func TestLoadOpeningBooksReplacesBook(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "blaze.book"), []byte("[classic]\n> 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	original := openingBook("blaze")
+	defer func() {
+		booksMu.Lock()
+		openingBooks["blaze"] = original
+		booksMu.Unlock()
+	}()
+
+	if err := LoadOpeningBooks(dir); err != nil {
+		t.Fatal(err)
+	}
+	b := NewBotService()
+	if move, _ := b.GetBotMove(rules.Classic{}, rules.Classic{}.NewState(), 1500, "blaze"); move.Cell != 1 {
+		t.Errorf("bot ignored the loaded book and played %d", move.Cell)
+	}
+	if openingBook("warden") == nil {
+		t.Error("loading one book dropped the others")
+	}
+}
+
+func TestStyleBonus(t *testing.T) {
+	classic := rules.Classic{}
+
+	// X: 0, 8; O: 4, 1. X at 6 threatens 3 and 7, X at 2 only 5.
+	state := play(t, classic, 0, 4, 8, 1)
+	if fork, single := styleBonus(classic, state, rules.Move{Cell: 6}, StyleAggressive), styleBonus(classic, state, rules.Move{Cell: 2}, StyleAggressive); fork <= single {
+		t.Errorf("aggressive bot rates a fork %v and a single threat %v", fork, single)
+	}
+
+	// X: 0, 1; O: 4. O at 2 blocks the top row.
+	state = play(t, classic, 0, 4, 1)
+	if block, other := styleBonus(classic, state, rules.Move{Cell: 2}, StyleDefensive), styleBonus(classic, state, rules.Move{Cell: 6}, StyleDefensive); block <= other {
+		t.Errorf("defensive bot rates a block %v and a quiet move %v", block, other)
+	}
+
+	// X: 4, 8; O: 0. After O at 2 every X reply but 1 loses; after O at 5
+	// none does.
+	state = play(t, classic, 4, 0, 8)
+	if trap, quiet := styleBonus(classic, state, rules.Move{Cell: 2}, StyleTrickster), styleBonus(classic, state, rules.Move{Cell: 5}, StyleTrickster); trap <= quiet {
+		t.Errorf("trickster rates a trap %v and a quiet move %v", trap, quiet)
+	}
+}
+
+func TestPersonalityBotName(t *testing.T) {
+	p, ok := FindBotPersonality("warden")
+	if !ok {
+		t.Fatal("warden not found")
+	}
+	g := NewGameManager(nil, nil)
+	g.CreateBotGame("alice", models.BotPlayer{Elo: p.Elo, Personality: p}, rules.Classic{}, rules.X, false)
+
+	game, ok := g.GetGame("alice")
+	if !ok {
+		t.Fatal("game not created")
+	}
+	if game.PlayerO != "Bot_Warden" || game.BotPersonality != "warden" || game.BotElo != p.Elo {
+		t.Errorf("unexpected bot game %s personality=%q elo=%d", game.PlayerO, game.BotPersonality, game.BotElo)
+	}
+	if _, ok := FindBotPersonality("nobody"); ok {
+		t.Error("unknown personality found")
+	}
+}
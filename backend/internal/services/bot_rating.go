@@ -0,0 +1,127 @@
+package services
+
+import (
+	"math"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/rules"
+)
+
+// CalibrationPoint - рейтинг, который бот с температурой Temperature
+// показал в партиях с другими ботами.
+type CalibrationPoint struct {
+	Temperature float64
+	Elo         int
+}
+
+// botCalibration переводит рейтинг бота в температуру. Получено
+// go run ./cmd/calibrate -games 200 на классической доске; отсортировано
+// по возрастанию рейтинга.
+var botCalibration = []CalibrationPoint{
+	{Temperature: math.Inf(1), Elo: 800},
+	{Temperature: 2.5, Elo: 914},
+	{Temperature: 1.5, Elo: 1011},
+	{Temperature: 1, Elo: 1124},
+	{Temperature: 0.7, Elo: 1249},
+	{Temperature: 0.45, Elo: 1429},
+	{Temperature: 0.3, Elo: 1608},
+	{Temperature: 0.2, Elo: 1729},
+	{Temperature: 0.15, Elo: 1751},
+	{Temperature: 0.1, Elo: 1755},
+	{Temperature: 0.02, Elo: 1758},
+	{Temperature: 0, Elo: 1800},
+}
+
+// botTemperature - температура, при которой бот играет на рейтинг elo.
+// Ноль означает лучший ход, +Inf - случайный. Между точками калибровки
+// температура интерполируется по ее логарифму.
+func botTemperature(elo int) float64 {
+	if elo >= models.MaxBotElo {
+		return 0
+	}
+	if len(botCalibration) == 0 || elo <= botCalibration[0].Elo {
+		return math.Inf(1)
+	}
+	for i := 1; i < len(botCalibration); i++ {
+		lo, hi := botCalibration[i-1], botCalibration[i]
+		if elo > hi.Elo {
+			continue
+		}
+		if hi.Temperature == 0 || math.IsInf(lo.Temperature, 1) {
+			// На концах интерполировать логарифм нельзя: берем ближайшую точку
+			if elo-lo.Elo < hi.Elo-elo {
+				return lo.Temperature
+			}
+			return hi.Temperature
+		}
+		t := float64(elo-lo.Elo) / float64(hi.Elo-lo.Elo)
+		return math.Exp(math.Log(lo.Temperature) + t*(math.Log(hi.Temperature)-math.Log(lo.Temperature)))
+	}
+	return 0
+}
+
+// policyMove выбирает ход с вероятностью, пропорциональной
+// exp(оценка / temperature): при нулевой температуре - лучший ход, при
+// бесконечной - любой с равной вероятностью. Непустой style добавляет к
+// оценкам бонус стиля (см. applyStyle). Для движков без оценок лучший ход
+// делается с вероятностью exp(-temperature), иначе случайный.
+func (b *BotService) policyMove(gameRules rules.GameRules, state *rules.State, temperature float64, style string) rules.Move {
+	if math.IsInf(temperature, 1) {
+		return b.getEasyMove(gameRules, state)
+	}
+	engine := b.engine(gameRules)
+	scorer, ok := engine.(MoveScorer)
+	if !ok {
+		if b.rand.Float64() < math.Exp(-temperature) {
+			return b.getHardMove(gameRules, state)
+		}
+		return b.getEasyMove(gameRules, state)
+	}
+	if temperature == 0 && style == "" {
+		return b.getHardMove(gameRules, state)
+	}
+
+	scored := scorer.ScoreMoves(gameRules, state)
+	if len(scored) == 0 {
+		return b.getEasyMove(gameRules, state)
+	}
+	applyStyle(gameRules, state, scored, style)
+	if temperature == 0 {
+		return bestScored(scored)
+	}
+	return b.sampleMove(scored, temperature)
+}
+
+// bestScored - ход с наибольшей оценкой, при равенстве - первый.
+func bestScored(scored []ScoredMove) rules.Move {
+	best := scored[0]
+	for _, m := range scored[1:] {
+		if m.Score > best.Score {
+			best = m
+		}
+	}
+	return best.Move
+}
+
+// sampleMove выбирает ход по распределению softmax с температурой temperature.
+func (b *BotService) sampleMove(scored []ScoredMove, temperature float64) rules.Move {
+	best := scored[0].Score
+	for _, m := range scored[1:] {
+		best = math.Max(best, m.Score)
+	}
+	weights := make([]float64, len(scored))
+	total := 0.0
+	for i, m := range scored {
+		// Вычитаем лучшую оценку, чтобы exp не переполнялся
+		weights[i] = math.Exp((m.Score - best) / temperature)
+		total += weights[i]
+	}
+	r := b.rand.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return scored[i].Move
+		}
+		r -= w
+	}
+	return scored[len(scored)-1].Move
+}
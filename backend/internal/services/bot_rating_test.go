@@ -0,0 +1,128 @@
+package services
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/rules"
+)
+
+func TestBotTemperatureFollowsElo(t *testing.T) {
+	if got := botTemperature(models.MinBotElo); !math.IsInf(got, 1) {
+		t.Errorf("weakest bot should play at random, temperature %v", got)
+	}
+	if got := botTemperature(models.MaxBotElo); got != 0 {
+		t.Errorf("strongest bot should play its best move, temperature %v", got)
+	}
+	prev := math.Inf(1)
+	for elo := models.MinBotElo; elo <= models.MaxBotElo; elo += 10 {
+		temperature := botTemperature(elo)
+		if temperature > prev {
+			t.Fatalf("temperature rises from %v to %v at elo %d", prev, temperature, elo)
+		}
+		prev = temperature
+	}
+}
+
+func TestDifficultiesKeepTheirStrength(t *testing.T) {
+	if got := botTemperature(models.DifficultyEasy.Elo()); !math.IsInf(got, 1) {
+		t.Errorf("easy should play at random, temperature %v", got)
+	}
+	if got := botTemperature(models.DifficultyHard.Elo()); got != 0 {
+		t.Errorf("hard should play perfectly, temperature %v", got)
+	}
+}
+
+func TestSampleMoveFollowsTemperature(t *testing.T) {
+	b := &BotService{rand: rand.New(rand.NewSource(1))}
+	scored := []ScoredMove{
+		{Move: rules.Move{Cell: 0}, Score: -1},
+		{Move: rules.Move{Cell: 1}, Score: 0},
+		{Move: rules.Move{Cell: 2}, Score: 1},
+	}
+	count := func(temperature float64) int {
+		best := 0
+		for i := 0; i < 1000; i++ {
+			if b.sampleMove(scored, temperature).Cell == 2 {
+				best++
+			}
+		}
+		return best
+	}
+
+	if cold := count(0.05); cold < 990 {
+		t.Errorf("cold bot chose the best move %d times out of 1000", cold)
+	}
+	if hot := count(100); hot > 400 {
+		t.Errorf("hot bot chose the best move %d times out of 1000", hot)
+	}
+}
+
+func TestRatedBotGameHasBotElo(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	bot := models.BotPlayer{Elo: 1350}
+	g.CreateBotGame("alice", bot, rules.Classic{}, rules.X, false)
+
+	game, ok := g.GetGame("alice")
+	if !ok {
+		t.Fatal("game not created")
+	}
+	if game.PlayerO != "Bot_1350" || game.BotElo != 1350 || !game.Unrated {
+		t.Errorf("unexpected bot game %s elo=%d unrated=%v", game.PlayerO, game.BotElo, game.Unrated)
+	}
+}
+
+// TestSameBotInTwoGames plays the same bot against two players at once:
+// both games share the bot name, yet each gets its own bot moves.
+func TestSameBotInTwoGames(t *testing.T) {
+	warden, _ := FindBotPersonality("warden")
+	for _, bot := range []models.BotPlayer{{Elo: 1200}, {Elo: warden.Elo, Personality: warden}} {
+		g := NewGameManager(nil, nil)
+		b := NewBotService()
+		positions := make(map[string]BotPosition)
+		for i, player := range []string{"alice", "bob"} {
+			g.CreateBotGame(player, bot, rules.Classic{}, rules.X, false)
+			if _, _, err := g.HandleMove(player, rules.Move{Cell: i * 8}, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, player := range []string{"alice", "bob"} {
+			pos, ok := g.BotPosition(player)
+			if !ok {
+				t.Fatalf("%s: %s does not move", player, bot.Name())
+			}
+			move, err := b.GetBotMove(pos.Rules, pos.State, pos.Elo, pos.Personality)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := g.HandleBotMove(player, pos.GameID, move); err != nil {
+				t.Fatalf("%s: %v", player, err)
+			}
+			positions[player] = pos
+		}
+
+		alice, _ := g.GetGame("alice")
+		bob, _ := g.GetGame("bob")
+		if alice == bob || alice.PlayerO != bob.PlayerO {
+			t.Fatalf("%s: games %s and %s", bot.Name(), alice.ID, bob.ID)
+		}
+		for _, game := range []*models.Game{alice, bob} {
+			if len(game.Moves) != 2 || game.Moves[1].Seat != rules.O || game.State.Turn != rules.X {
+				t.Errorf("%s: game %s has moves %+v", bot.Name(), game.ID, game.Moves)
+			}
+		}
+
+		// A move or a forfeit for one game never reaches the other
+		if _, _, err := g.HandleBotMove("bob", positions["alice"].GameID, rules.Move{Cell: 4}); err == nil {
+			t.Error("bot moved in bob's game with alice's game ID")
+		}
+		if _, ok := g.ForfeitBot("alice", positions["alice"].GameID, models.ReasonForfeit); !ok {
+			t.Fatal("bot could not forfeit alice's game")
+		}
+		if !alice.IsFinished || bob.IsFinished {
+			t.Errorf("%s: forfeit finished alice=%v bob=%v", bot.Name(), alice.IsFinished, bob.IsFinished)
+		}
+	}
+}
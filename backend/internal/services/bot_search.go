@@ -0,0 +1,288 @@
+package services
+
+import (
+	"math/rand"
+	"sort"
+
+	"tictactoe/internal/rules"
+)
+
+const (
+	// searchWinScore - оценка выигрыша; из нее вычитается число ходов до
+	// конца партии, чтобы быстрые победы ценились выше
+	searchWinScore = 1000
+	// maxSolvedSize - самая большая доска гомоку, которую бот решает полным
+	// перебором, а не MCTS
+	maxSolvedSize = 4
+)
+
+// Виды оценок в таблице транспозиций
+const (
+	boundExact = iota
+	boundLower
+	boundUpper
+)
+
+type ttEntry struct {
+	score int
+	bound uint8
+}
+
+// searchEngine - полный перебор для вариантов, где ходят в пустую клетку
+// квадратной доски: classic, misere, wild и маленький гомоку.
+// Непобедим, так как оценка терминальных позиций берется из правил варианта.
+type searchEngine struct{}
+
+func (searchEngine) BestMove(gameRules rules.GameRules, state *rules.State) rules.Move {
+	s := newSearcher(gameRules, state)
+	bestMove := rules.Move{Cell: -1}
+	alpha := -searchWinScore - 1
+	for _, move := range s.orderedMoves(s.state) {
+		score, ok := s.child(move, 0, alpha, searchWinScore+1)
+		if !ok {
+			continue
+		}
+		if score > alpha || bestMove.Cell == -1 {
+			alpha, bestMove = score, move
+		}
+	}
+	return bestMove
+}
+
+// ScoreMoves точно оценивает каждый ход: 1 - выигрыш, 0 - ничья, -1 -
+// проигрыш. Быстрый выигрыш оценивается чуть выше медленного.
+func (searchEngine) ScoreMoves(gameRules rules.GameRules, state *rules.State) []ScoredMove {
+	s := newSearcher(gameRules, state)
+	var scored []ScoredMove
+	for _, move := range s.orderedMoves(s.state) {
+		score, ok := s.child(move, 0, -searchWinScore-1, searchWinScore+1)
+		if !ok {
+			continue
+		}
+		scored = append(scored, ScoredMove{Move: move, Score: float64(score) / searchWinScore})
+	}
+	return scored
+}
+
+// solve возвращает оценку позиции для стороны, которая ходит:
+// больше нуля - выигрыш, ноль - ничья, меньше нуля - проигрыш.
+func solve(gameRules rules.GameRules, state *rules.State) int {
+	s := newSearcher(gameRules, state)
+	return s.negamax(0, -searchWinScore-1, searchWinScore+1)
+}
+
+// searcher - negamax с alpha-beta отсечением и таблицей транспозиций.
+// Позиции хешируются по Зобристу с точностью до симметрий квадрата (поворотов
+// и отражений), поэтому симметричные позиции считаются один раз.
+// Ходы делаются и откатываются на одной копии доски.
+type searcher struct {
+	gameRules rules.GameRules
+	state     *rules.State
+	// symmetries[t][cell] - куда клетка переходит при t-й симметрии
+	symmetries [8][]int
+	zobrist    [][2]uint64
+	turnKey    uint64
+	// hashes[t] - хеш доски после t-й симметрии
+	hashes [8]uint64
+	// rank - порядок перебора клеток: от центра к краям
+	rank  []int
+	table map[uint64]ttEntry
+}
+
+func newSearcher(gameRules rules.GameRules, state *rules.State) *searcher {
+	size := boardSize(state)
+	cells := len(state.Board)
+	s := &searcher{
+		gameRules:  gameRules,
+		state:      state.Clone(),
+		symmetries: squareSymmetries(size),
+		zobrist:    make([][2]uint64, cells),
+		rank:       make([]int, cells),
+		table:      make(map[uint64]ttEntry),
+	}
+
+	// Ключи фиксированы, чтобы поиск был воспроизводим
+	r := rand.New(rand.NewSource(int64(cells)))
+	for i := range s.zobrist {
+		s.zobrist[i] = [2]uint64{r.Uint64(), r.Uint64()}
+	}
+	s.turnKey = r.Uint64()
+
+	for cell, mark := range s.state.Board {
+		if mark != "" {
+			s.toggle(cell, mark)
+		}
+		row, col := cell/size, cell%size
+		s.rank[cell] = abs(2*row-size+1) + abs(2*col-size+1)
+	}
+	return s
+}
+
+// boardSize - сторона квадратной доски
+func boardSize(state *rules.State) int {
+	size := 1
+	for size*size < len(state.Board) {
+		size++
+	}
+	return size
+}
+
+// squareSymmetries перечисляет восемь симметрий квадрата size×size
+// как перестановки клеток.
+func squareSymmetries(size int) [8][]int {
+	transforms := [8]func(row, col int) (int, int){
+		func(r, c int) (int, int) { return r, c },
+		func(r, c int) (int, int) { return c, size - 1 - r },
+		func(r, c int) (int, int) { return size - 1 - r, size - 1 - c },
+		func(r, c int) (int, int) { return size - 1 - c, r },
+		func(r, c int) (int, int) { return r, size - 1 - c },
+		func(r, c int) (int, int) { return size - 1 - r, c },
+		func(r, c int) (int, int) { return c, r },
+		func(r, c int) (int, int) { return size - 1 - c, size - 1 - r },
+	}
+	var symmetries [8][]int
+	for t, transform := range transforms {
+		symmetries[t] = make([]int, size*size)
+		for cell := range symmetries[t] {
+			row, col := transform(cell/size, cell%size)
+			symmetries[t][cell] = row*size + col
+		}
+	}
+	return symmetries
+}
+
+// toggle добавляет метку в хеши всех симметрий или убирает ее оттуда.
+func (s *searcher) toggle(cell int, mark string) {
+	m := 0
+	if mark == rules.O {
+		m = 1
+	}
+	for t := range s.hashes {
+		s.hashes[t] ^= s.zobrist[s.symmetries[t][cell]][m]
+	}
+}
+
+// key - наименьший из хешей симметричных досок вместе с очередью хода
+func (s *searcher) key() uint64 {
+	key := s.hashes[0]
+	for _, h := range s.hashes[1:] {
+		if h < key {
+			key = h
+		}
+	}
+	if s.state.Turn == rules.O {
+		key ^= s.turnKey
+	}
+	return key
+}
+
+func (s *searcher) orderedMoves(state *rules.State) []rules.Move {
+	moves := s.gameRules.LegalMoves(state)
+	sort.SliceStable(moves, func(i, j int) bool {
+		return s.rank[moves[i].Cell] < s.rank[moves[j].Cell]
+	})
+	return moves
+}
+
+// child делает ход, оценивает получившуюся позицию и откатывает ход.
+// Возвращает оценку с точки зрения стороны, сделавшей ход.
+func (s *searcher) child(move rules.Move, ply, alpha, beta int) (int, bool) {
+	state := s.state
+	turn, lastMove, moveCount := state.Turn, state.LastMove, state.MoveCount
+	if err := s.gameRules.Apply(state, move); err != nil {
+		return 0, false
+	}
+	mark := state.Board[move.Cell]
+	s.toggle(move.Cell, mark)
+
+	score := -s.negamax(ply+1, -beta, -alpha)
+
+	s.toggle(move.Cell, mark)
+	state.Board[move.Cell] = ""
+	state.Turn, state.LastMove, state.MoveCount = turn, lastMove, moveCount
+	return score, true
+}
+
+// negamax оценивает текущую позицию для стороны, которая ходит. ply - число
+// ходов от корня поиска.
+func (s *searcher) negamax(ply, alpha, beta int) int {
+	if outcome := s.gameRules.Outcome(s.state); outcome.Finished {
+		switch outcome.Winner {
+		case rules.Draw:
+			return 0
+		case s.state.Turn:
+			return searchWinScore - ply
+		default:
+			return ply - searchWinScore
+		}
+	}
+
+	key := s.key()
+	if entry, ok := s.table[key]; ok {
+		score := fromTable(entry.score, ply)
+		switch entry.bound {
+		case boundExact:
+			return score
+		case boundLower:
+			alpha = max(alpha, score)
+		case boundUpper:
+			beta = min(beta, score)
+		}
+		if alpha >= beta {
+			return score
+		}
+	}
+
+	originalAlpha := alpha
+	best := -searchWinScore - 1
+	for _, move := range s.orderedMoves(s.state) {
+		score, ok := s.child(move, ply, alpha, beta)
+		if !ok {
+			continue
+		}
+		best = max(best, score)
+		alpha = max(alpha, score)
+		if alpha >= beta {
+			break
+		}
+	}
+
+	entry := ttEntry{score: toTable(best, ply), bound: boundExact}
+	switch {
+	case best <= originalAlpha:
+		entry.bound = boundUpper
+	case best >= beta:
+		entry.bound = boundLower
+	}
+	s.table[key] = entry
+	return best
+}
+
+// toTable и fromTable переводят оценку из отсчета от корня поиска в отсчет
+// от самой позиции: одна позиция встречается на разной глубине.
+func toTable(score, ply int) int {
+	switch {
+	case score > 0:
+		return score + ply
+	case score < 0:
+		return score - ply
+	}
+	return 0
+}
+
+func fromTable(score, ply int) int {
+	switch {
+	case score > 0:
+		return score - ply
+	case score < 0:
+		return score + ply
+	}
+	return 0
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"tictactoe/internal/rules"
+)
+
+// referenceMinimax is the plain minimax the bot used before the alpha-beta
+// search, ported to the rules interface. It scores the position for
+// botSymbol, preferring faster wins. The original had no memo; this one is
+// only here to keep the test fast and is keyed by depth too, since the
+// scores depend on it.
+func referenceMinimax(gameRules rules.GameRules, state *rules.State, depth int, botSymbol string, memo map[string]int) int {
+	key := positionKey(state) + strconv.Itoa(depth)
+	if score, ok := memo[key]; ok {
+		return score
+	}
+
+	score := 0
+	if outcome := gameRules.Outcome(state); outcome.Finished {
+		switch outcome.Winner {
+		case botSymbol:
+			score = 10 - depth
+		case rules.Draw:
+			score = 0
+		default:
+			score = depth - 10
+		}
+	} else {
+		isMaximizing := state.Turn == botSymbol
+		score = 1000
+		if isMaximizing {
+			score = -1000
+		}
+		for _, move := range gameRules.LegalMoves(state) {
+			next := state.Clone()
+			if err := gameRules.Apply(next, move); err != nil {
+				continue
+			}
+			child := referenceMinimax(gameRules, next, depth+1, botSymbol, memo)
+			if isMaximizing {
+				score = max(score, child)
+			} else {
+				score = min(score, child)
+			}
+		}
+	}
+	memo[key] = score
+	return score
+}
+
+// searchScore converts a referenceMinimax score to the scale of solve,
+// where a win in n moves is worth searchWinScore - n instead of 10 - n.
+func searchScore(score int) int {
+	switch {
+	case score > 0:
+		return score + searchWinScore - 10
+	case score < 0:
+		return score - searchWinScore + 10
+	}
+	return 0
+}
+
+// positionKey encodes the board and the side to move.
+func positionKey(state *rules.State) string {
+	key := make([]byte, len(state.Board)+1)
+	for i, cell := range state.Board {
+		switch cell {
+		case rules.X:
+			key[i] = 'X'
+		case rules.O:
+			key[i] = 'O'
+		default:
+			key[i] = '.'
+		}
+	}
+	key[len(state.Board)] = state.Turn[0]
+	return string(key)
+}
+
+func TestSearchMatchesReferenceMinimax(t *testing.T) {
+	for _, gameRules := range []rules.GameRules{rules.Classic{}, rules.Misere{}, rules.Wild{}} {
+		t.Run(gameRules.Options().Variant, func(t *testing.T) {
+			memos := map[string]map[string]int{rules.X: {}, rules.O: {}}
+			positions := reachablePositions(gameRules)
+			for _, state := range positions {
+				memo := memos[state.Turn]
+				want := referenceMinimax(gameRules, state, 0, state.Turn, memo)
+				if got := solve(gameRules, state); got != searchScore(want) {
+					t.Fatalf("position %v (%s to move): got %d, want %d", state.Board, state.Turn, got, searchScore(want))
+				}
+
+				// The chosen move must also win or lose as fast as possible
+				move := searchEngine{}.BestMove(gameRules, state)
+				next := state.Clone()
+				if err := gameRules.Apply(next, move); err != nil {
+					t.Fatalf("position %v: best move %+v is illegal: %v", state.Board, move, err)
+				}
+				if got := referenceMinimax(gameRules, next, 1, state.Turn, memo); got != want {
+					t.Fatalf("position %v (%s to move): move %+v scores %d, best is %d", state.Board, state.Turn, move, got, want)
+				}
+			}
+			t.Logf("%d positions", len(positions))
+		})
+	}
+}
+
+func TestSearchSolvesSmallGomoku(t *testing.T) {
+	tests := []struct {
+		gameRules rules.Gomoku
+		want      int
+	}{
+		{rules.Gomoku{Size: 4, WinLength: 3}, 1},
+		{rules.Gomoku{Size: 4, WinLength: 4}, 0},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		if got := sign(solve(tt.gameRules, tt.gameRules.NewState())); got != tt.want {
+			t.Errorf("%dx%d, %d in a row: got %d, want %d", tt.gameRules.Size, tt.gameRules.Size, tt.gameRules.WinLength, got, tt.want)
+		}
+		t.Logf("%dx%d, %d in a row solved in %v", tt.gameRules.Size, tt.gameRules.Size, tt.gameRules.WinLength, time.Since(start))
+	}
+}
+
+func TestSearchKeyIgnoresSymmetry(t *testing.T) {
+	gameRules := rules.Gomoku{Size: 4, WinLength: 4}
+	// b is a rotated by 90 degrees, c is a different position
+	a := play(t, gameRules, 0, 5, 2)
+	b := play(t, gameRules, 3, 6, 11)
+	c := play(t, gameRules, 0, 6, 2)
+
+	keyA := newSearcher(gameRules, a).key()
+	if keyB := newSearcher(gameRules, b).key(); keyB != keyA {
+		t.Errorf("symmetric positions have different keys %x and %x", keyA, keyB)
+	}
+	if keyC := newSearcher(gameRules, c).key(); keyC == keyA {
+		t.Error("different positions share a key")
+	}
+}
+
+func TestSearchPrefersFasterWin(t *testing.T) {
+	// X wins at once with 2 or 7, and still wins later after most other moves
+	state := rules.Classic{}.NewState()
+	state.Board = []string{rules.X, rules.X, "", rules.O, rules.X, "", "", "", rules.O}
+	state.MoveCount = 5
+	move := searchEngine{}.BestMove(rules.Classic{}, state)
+	if move.Cell != 2 && move.Cell != 7 {
+		t.Fatalf("expected an immediate win, got cell %d", move.Cell)
+	}
+}
+
+func BenchmarkSearchWild(b *testing.B) {
+	state := rules.Wild{}.NewState()
+	for i := 0; i < b.N; i++ {
+		searchEngine{}.BestMove(rules.Wild{}, state)
+	}
+}
+
+func BenchmarkSearchGomoku4x4(b *testing.B) {
+	gameRules := rules.Gomoku{Size: 4, WinLength: 4}
+	state := gameRules.NewState()
+	for i := 0; i < b.N; i++ {
+		searchEngine{}.BestMove(gameRules, state)
+	}
+}
+
+func BenchmarkReferenceMinimaxClassic(b *testing.B) {
+	benchmarkReferenceMinimax(b, rules.Classic{})
+}
+
+func BenchmarkReferenceMinimaxWild(b *testing.B) {
+	benchmarkReferenceMinimax(b, rules.Wild{})
+}
+
+func benchmarkReferenceMinimax(b *testing.B, gameRules rules.GameRules) {
+	state := gameRules.NewState()
+	for i := 0; i < b.N; i++ {
+		memo := make(map[string]int)
+		for _, move := range gameRules.LegalMoves(state) {
+			next := state.Clone()
+			if err := gameRules.Apply(next, move); err != nil {
+				b.Fatal(err)
+			}
+			referenceMinimax(gameRules, next, 0, state.Turn, memo)
+		}
+	}
+}
@@ -1,164 +1,127 @@
 package services
 
 import (
+	"math"
 	"math/rand"
 	"tictactoe/internal/models"
+	"tictactoe/internal/rules"
 	"time"
 )
 
+// BotEngine выбирает ход за сторону, которая ходит в state.
+// Если ходов нет, возвращается ход с Cell == -1.
+type BotEngine interface {
+	BestMove(gameRules rules.GameRules, state *rules.State) rules.Move
+}
+
+// ScoredMove - ход с оценкой от -1 (проигрыш) до 1 (выигрыш) для стороны,
+// которая ходит.
+type ScoredMove struct {
+	Move  rules.Move
+	Score float64
+}
+
+// MoveScorer - движок, который оценивает каждый ход, а не только выбирает
+// лучший. Только по таким оценкам бот умеет играть на заданный рейтинг.
+type MoveScorer interface {
+	ScoreMoves(gameRules rules.GameRules, state *rules.State) []ScoredMove
+}
+
 type BotService struct {
 	rand *rand.Rand
+	// mcts играет варианты, для которых нет точного перебора
+	mcts BotEngine
+	// external, если задан, заменяет встроенные движки во всех вариантах
+	external *ExternalEngine
 }
 
 func NewBotService() *BotService {
+	seed := time.Now().UnixNano()
 	return &BotService{
-		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+		rand: rand.New(rand.NewSource(seed)),
+		mcts: NewMCTSEngine(MCTSConfig{
+			Playouts: defaultMCTSPlayouts,
+			Budget:   defaultMCTSBudget,
+			Seed:     seed,
+		}),
+		external: currentExternalEngine(),
 	}
 }
 
-// GetBotMove возвращает ход бота в зависимости от сложности
-func (b *BotService) GetBotMove(board [9]string, difficulty models.BotDifficulty, botSymbol string) int {
-	switch difficulty {
-	case models.DifficultyEasy:
-		return b.getEasyMove(board)
-	case models.DifficultyMedium:
-		return b.getMediumMove(board, botSymbol)
-	case models.DifficultyHard:
-		return b.getHardMove(board, botSymbol)
-	default:
-		return b.getEasyMove(board)
+// GetBotMove возвращает ход бота, который играет на рейтинг elo
+// (от models.MinBotElo до models.MaxBotElo). Бот с характером personality
+// (id или пустая строка) сначала ищет позицию в своей дебютной книге, а
+// среди ходов движка предпочитает ходы в своем стиле. Случайный бот
+// книгой не пользуется.
+// Если ходов нет, возвращается ход с Cell == -1. Ошибка ErrEngineFailed
+// означает, что внешний движок не справился и бот должен сдаться.
+func (b *BotService) GetBotMove(gameRules rules.GameRules, state *rules.State, elo int, personality string) (rules.Move, error) {
+	style := ""
+	if p, ok := FindBotPersonality(personality); ok {
+		style = p.Style
+		if moves := openingBook(p.ID).Moves(gameRules, state); len(moves) > 0 && elo > models.MinBotElo {
+			return moves[b.rand.Intn(len(moves))], nil
+		}
+	}
+	temperature := botTemperature(elo)
+	if b.external != nil {
+		// Внешний движок не оценивает ходы, поэтому, как и для других
+		// таких движков, его ход делается с вероятностью exp(-temperature)
+		if math.IsInf(temperature, 1) || b.rand.Float64() >= math.Exp(-temperature) {
+			return b.getEasyMove(gameRules, state), nil
+		}
+		return b.external.Move(gameRules, state)
 	}
+	return b.policyMove(gameRules, state, temperature, style), nil
 }
 
 // getEasyMove - случайный ход
-func (b *BotService) getEasyMove(board [9]string) int {
-	available := b.getAvailableCells(board)
+func (b *BotService) getEasyMove(gameRules rules.GameRules, state *rules.State) rules.Move {
+	available := gameRules.LegalMoves(state)
 	if len(available) == 0 {
-		return -1
+		return rules.Move{Cell: -1}
 	}
 	return available[b.rand.Intn(len(available))]
 }
 
-// getMediumMove - 50% optimal, 50% random
-func (b *BotService) getMediumMove(board [9]string, botSymbol string) int {
-	// 50% шанс сделать умный ход
-	if b.rand.Float32() < 0.5 {
-		return b.getHardMove(board, botSymbol)
-	}
-	return b.getEasyMove(board)
-}
-
-// getHardMove - minimax алгоритм (непобедимый)
-func (b *BotService) getHardMove(board [9]string, botSymbol string) int {
-	playerSymbol := "X"
-	if botSymbol == "X" {
-		playerSymbol = "O"
-	}
-
-	bestScore := -1000
-	bestMove := -1
-
-	for i := 0; i < 9; i++ {
-		if board[i] == "" {
-			// Пробуем ход
-			testBoard := board
-			testBoard[i] = botSymbol
-			score := b.minimax(testBoard, 0, false, botSymbol, playerSymbol)
-
-			if score > bestScore {
-				bestScore = score
-				bestMove = i
-			}
+// engine выбирает движок для варианта: полный перебор для маленьких досок,
+// поиск с ограниченной глубиной для ultimate и MCTS для остальных.
+func (b *BotService) engine(gameRules rules.GameRules) BotEngine {
+	switch g := gameRules.(type) {
+	case rules.Classic, rules.Misere, rules.Wild:
+		return searchEngine{}
+	case rules.Gomoku:
+		if g.Size <= maxSolvedSize {
+			return searchEngine{}
 		}
+	case rules.Ultimate:
+		return ultimateEngine{b}
 	}
-
-	// Если minimax не нашел ход (не должно случиться), делаем случайный
-	if bestMove == -1 {
-		return b.getEasyMove(board)
-	}
-
-	return bestMove
+	return b.mcts
 }
 
-// minimax - рекурсивный алгоритм для поиска оптимального хода
-func (b *BotService) minimax(board [9]string, depth int, isMaximizing bool, botSymbol, playerSymbol string) int {
-	// Проверяем терминальные состояния
-	winner, _ := b.checkWinner(board)
-
-	if winner == botSymbol {
-		return 10 - depth // Победа бота (приоритет быстрым победам)
-	}
-	if winner == playerSymbol {
-		return depth - 10 // Победа игрока
-	}
-	if b.isBoardFull(board) {
-		return 0 // Ничья
-	}
-
-	if isMaximizing {
-		// Ход бота - максимизируем счет
-		bestScore := -1000
-		for i := 0; i < 9; i++ {
-			if board[i] == "" {
-				testBoard := board
-				testBoard[i] = botSymbol
-				score := b.minimax(testBoard, depth+1, false, botSymbol, playerSymbol)
-				bestScore = max(score, bestScore)
-			}
-		}
-		return bestScore
-	} else {
-		// Ход игрока - минимизируем счет
-		bestScore := 1000
-		for i := 0; i < 9; i++ {
-			if board[i] == "" {
-				testBoard := board
-				testBoard[i] = playerSymbol
-				score := b.minimax(testBoard, depth+1, true, botSymbol, playerSymbol)
-				bestScore = min(score, bestScore)
-			}
-		}
-		return bestScore
+// getHardMove - лучший ход движка варианта
+func (b *BotService) getHardMove(gameRules rules.GameRules, state *rules.State) rules.Move {
+	move := b.engine(gameRules).BestMove(gameRules, state)
+	// Если движок не нашел ход (не должно случиться), делаем случайный
+	if move.Cell == -1 {
+		return b.getEasyMove(gameRules, state)
 	}
+	return move
 }
 
-// checkWinner проверяет победителя на доске
-func (b *BotService) checkWinner(board [9]string) (string, []int) {
-	winPatterns := [][]int{
-		{0, 1, 2}, {3, 4, 5}, {6, 7, 8}, // горизонтали
-		{0, 3, 6}, {1, 4, 7}, {2, 5, 8}, // вертикали
-		{0, 4, 8}, {2, 4, 6}, // диагонали
-	}
-
-	for _, pattern := range winPatterns {
-		a, b, c := pattern[0], pattern[1], pattern[2]
-		if board[a] != "" && board[a] == board[b] && board[b] == board[c] {
-			return board[a], pattern
-		}
-	}
-
-	return "", nil
+// ultimateEngine - поиск с ограниченной глубиной для ultimate,
+// полный перебор там невозможен
+type ultimateEngine struct {
+	b *BotService
 }
 
-// isBoardFull проверяет, заполнена ли доска
-func (b *BotService) isBoardFull(board [9]string) bool {
-	for _, cell := range board {
-		if cell == "" {
-			return false
-		}
-	}
-	return true
+func (e ultimateEngine) BestMove(gameRules rules.GameRules, state *rules.State) rules.Move {
+	return e.b.getUltimateMove(gameRules.(rules.Ultimate), state, state.Turn)
 }
 
-// getAvailableCells возвращает список свободных клеток
-func (b *BotService) getAvailableCells(board [9]string) []int {
-	available := []int{}
-	for i, cell := range board {
-		if cell == "" {
-			available = append(available, i)
-		}
-	}
-	return available
+func (e ultimateEngine) ScoreMoves(gameRules rules.GameRules, state *rules.State) []ScoredMove {
+	return scoreUltimateMoves(gameRules.(rules.Ultimate), state)
 }
 
 func max(a, b int) int {
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"testing"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/rules"
+)
+
+// TestHardBotIsPerfect plays the hard bot, plain and with every
+// personality, against every possible line of the opponent and checks that
+// it never does worse than the game-theoretic value of the starting position.
+func TestHardBotIsPerfect(t *testing.T) {
+	variants := []rules.GameRules{rules.Classic{}, rules.Misere{}, rules.Wild{}}
+	personalities := []string{""}
+	for _, p := range BotPersonalities {
+		personalities = append(personalities, p.ID)
+	}
+	b := NewBotService()
+
+	for _, gameRules := range variants {
+		for _, personality := range personalities {
+			for _, botSymbol := range []string{rules.X, rules.O} {
+				t.Run(gameRules.Options().Variant+"/"+personality+"/"+botSymbol, func(t *testing.T) {
+					state := gameRules.NewState()
+					value := sign(solve(gameRules, state))
+					if botSymbol != state.Turn {
+						value = -value
+					}
+					worst := b.worstResult(t, gameRules, state, botSymbol, personality)
+					if worst < value {
+						t.Fatalf("bot scored %d, position value is %d", worst, value)
+					}
+				})
+			}
+		}
+	}
+}
+
+// worstResult returns -1, 0 or 1 for the worst result the bot gets over all
+// opponent replies from the given position.
+func (b *BotService) worstResult(t *testing.T, gameRules rules.GameRules, state *rules.State, botSymbol, personality string) int {
+	outcome := gameRules.Outcome(state)
+	if outcome.Finished {
+		switch outcome.Winner {
+		case botSymbol:
+			return 1
+		case rules.Draw:
+			return 0
+		default:
+			return -1
+		}
+	}
+
+	if state.Turn == botSymbol {
+		move, err := b.GetBotMove(gameRules, state.Clone(), models.DifficultyHard.Elo(), personality)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next := state.Clone()
+		if err := gameRules.Apply(next, move); err != nil {
+			t.Fatalf("bot made an illegal move %+v: %v", move, err)
+		}
+		return b.worstResult(t, gameRules, next, botSymbol, personality)
+	}
+
+	worst := 1
+	for _, move := range gameRules.LegalMoves(state) {
+		next := state.Clone()
+		if err := gameRules.Apply(next, move); err != nil {
+			t.Fatal(err)
+		}
+		worst = min(worst, b.worstResult(t, gameRules, next, botSymbol, personality))
+	}
+	return worst
+}
+
+func sign(score int) int {
+	switch {
+	case score > 0:
+		return 1
+	case score < 0:
+		return -1
+	}
+	return 0
+}
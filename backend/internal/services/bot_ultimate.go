@@ -0,0 +1,143 @@
+package services
+
+import (
+	"math"
+
+	"tictactoe/internal/rules"
+)
+
+const (
+	// ultimateSearchDepth - глубина поиска для ultimate, полный перебор невозможен
+	ultimateSearchDepth = 5
+	ultimateWinScore    = 100000
+	// ultimateScoreScale - оценка, которая примерно соответствует уверенному
+	// преимуществу; нужна, чтобы привести оценки к отрезку [-1, 1]
+	ultimateScoreScale = 1000
+)
+
+// subBoardWeights - центральная и угловые доски ценнее боковых
+var subBoardWeights = [9]int{3, 2, 3, 2, 4, 2, 3, 2, 3}
+
+// getUltimateMove - alpha-beta поиск с ограниченной глубиной и эвристической оценкой
+func (b *BotService) getUltimateMove(u rules.Ultimate, state *rules.State, botSymbol string) rules.Move {
+	moves := u.LegalMoves(state)
+	if len(moves) == 0 {
+		return rules.Move{Cell: -1}
+	}
+	// Перемешиваем, чтобы при равных оценках бот не играл одинаково
+	b.rand.Shuffle(len(moves), func(i, j int) { moves[i], moves[j] = moves[j], moves[i] })
+
+	bestMove := moves[0]
+	alpha := -ultimateWinScore * 2
+	for _, move := range moves {
+		next := state.Clone()
+		if err := u.Apply(next, move); err != nil {
+			continue
+		}
+		score := ultimateAlphaBeta(u, next, ultimateSearchDepth-1, alpha, ultimateWinScore*2, botSymbol)
+		if score > alpha {
+			alpha = score
+			bestMove = move
+		}
+	}
+	return bestMove
+}
+
+// scoreUltimateMoves оценивает каждый ход поиском с полным окном.
+func scoreUltimateMoves(u rules.Ultimate, state *rules.State) []ScoredMove {
+	botSymbol := state.Turn
+	var scored []ScoredMove
+	for _, move := range u.LegalMoves(state) {
+		next := state.Clone()
+		if err := u.Apply(next, move); err != nil {
+			continue
+		}
+		score := ultimateAlphaBeta(u, next, ultimateSearchDepth-1, -ultimateWinScore*2, ultimateWinScore*2, botSymbol)
+		scored = append(scored, ScoredMove{Move: move, Score: math.Tanh(float64(score) / ultimateScoreScale)})
+	}
+	return scored
+}
+
+func ultimateAlphaBeta(u rules.Ultimate, state *rules.State, depth, alpha, beta int, botSymbol string) int {
+	outcome := u.Outcome(state)
+	if outcome.Finished {
+		switch outcome.Winner {
+		case botSymbol:
+			return ultimateWinScore + depth // Быстрая победа лучше
+		case rules.Draw:
+			return 0
+		default:
+			return -ultimateWinScore - depth
+		}
+	}
+	if depth == 0 {
+		return evaluateUltimate(u, state, botSymbol)
+	}
+
+	isMaximizing := state.Turn == botSymbol
+	for _, move := range u.LegalMoves(state) {
+		next := state.Clone()
+		if err := u.Apply(next, move); err != nil {
+			continue
+		}
+		score := ultimateAlphaBeta(u, next, depth-1, alpha, beta, botSymbol)
+		if isMaximizing {
+			alpha = max(alpha, score)
+		} else {
+			beta = min(beta, score)
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	if isMaximizing {
+		return alpha
+	}
+	return beta
+}
+
+// evaluateUltimate оценивает позицию с точки зрения бота: выигранные малые
+// доски, незавершенные линии на них и линии на большой доске.
+func evaluateUltimate(u rules.Ultimate, state *rules.State, botSymbol string) int {
+	opponent := rules.Opposite(botSymbol)
+	winners := u.SubWinners(state)
+
+	score := 300 * linePotential(winners, botSymbol)
+	for sub, winner := range winners {
+		switch winner {
+		case botSymbol:
+			score += 100 * subBoardWeights[sub]
+		case opponent:
+			score -= 100 * subBoardWeights[sub]
+		case "":
+			score += 10 * subBoardWeights[sub] * linePotential(state.Board[sub*9:sub*9+9], botSymbol)
+		}
+	}
+	return score
+}
+
+// linePotential считает линии с двумя своими метками и пустой клеткой
+// минус такие же линии соперника.
+func linePotential(cells []string, botSymbol string) int {
+	opponent := rules.Opposite(botSymbol)
+	potential := 0
+	for _, line := range rules.ClassicLines {
+		own, other, empty := 0, 0, 0
+		for _, i := range line {
+			switch cells[i] {
+			case botSymbol:
+				own++
+			case opponent:
+				other++
+			case "":
+				empty++
+			}
+		}
+		if empty == 1 && own == 2 {
+			potential++
+		} else if empty == 1 && other == 2 {
+			potential--
+		}
+	}
+	return potential
+}
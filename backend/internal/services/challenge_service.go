@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"tictactoe/internal/logger"
+	"tictactoe/internal/models"
+	"tictactoe/internal/protocol"
+	"tictactoe/internal/rules"
+	"tictactoe/internal/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// challengeTimeout - сколько вызов ждет ответа
+	challengeTimeout = 30 * time.Second
+	// challengeRefusersKey - игроки, которые не принимают вызовы
+	challengeRefusersKey = "challenge_refusers"
+)
+
+var (
+	ErrChallengeNotFound = errors.New("challenge not found")
+	ErrPlayerOffline     = errors.New("player is not online")
+	ErrPlayerBusy        = errors.New("player is busy")
+)
+
+type ChallengeService struct {
+	RDB         *redis.Client
+	Notifier    Notifier
+	GameManager *GameManager
+
+	mu         sync.Mutex
+	challenges map[string]*models.Challenge
+	// outgoing - у каждого игрока не больше одного исходящего вызова
+	outgoing map[string]*models.Challenge
+}
+
+func NewChallengeService(rdb *redis.Client, notifier Notifier, gm *GameManager) *ChallengeService {
+	return &ChallengeService{
+		RDB:         rdb,
+		Notifier:    notifier,
+		GameManager: gm,
+		challenges:  make(map[string]*models.Challenge),
+		outgoing:    make(map[string]*models.Challenge),
+	}
+}
+
+// Challenge отправляет игроку to вызов от from с заданными настройками.
+func (s *ChallengeService) Challenge(from, to string, opts rules.Options, tc models.TimeControl) (*models.Challenge, error) {
+	gameRules, err := rules.New(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTimeControl(tc); err != nil {
+		return nil, err
+	}
+	if from == to {
+		return nil, errors.New("you cannot challenge yourself")
+	}
+	if s.inGame(from) {
+		return nil, ErrInGame
+	}
+	if !s.Notifier.Online(to) {
+		return nil, ErrPlayerOffline
+	}
+	if s.inGame(to) {
+		return nil, ErrPlayerBusy
+	}
+
+	ctx := context.Background()
+	refuses, err := s.RDB.SIsMember(ctx, challengeRefusersKey, to).Result()
+	if err != nil {
+		return nil, err
+	}
+	if refuses {
+		return nil, errors.New("player does not accept challenges")
+	}
+	queued, err := s.RDB.HExists(ctx, matchTicketsKey, to).Result()
+	if err != nil {
+		return nil, err
+	}
+	if queued {
+		return nil, ErrPlayerBusy
+	}
+	elo, err := s.GameManager.Rating(from)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if _, ok := s.outgoing[from]; ok {
+		s.mu.Unlock()
+		return nil, errors.New("you already have a pending challenge")
+	}
+	if pending, ok := s.outgoing[to]; ok && pending.To == from {
+		s.mu.Unlock()
+		return nil, errors.New("this player has already challenged you")
+	}
+	challenge := &models.Challenge{
+		ID:          utils.GenerateGameID(),
+		From:        from,
+		To:          to,
+		Rules:       gameRules,
+		TimeControl: tc,
+		CreatedAt:   time.Now(),
+	}
+	challenge.ExpireTimer = time.AfterFunc(challengeTimeout, func() {
+		s.expire(challenge)
+	})
+	s.challenges[challenge.ID] = challenge
+	s.outgoing[from] = challenge
+	s.GameManager.Owners().Claim(OwnedChallenge, challenge.ID)
+	s.mu.Unlock()
+
+	info := challengeInfo(challenge)
+	s.send(to, &protocol.ChallengeReceived{ChallengeInfo: info, Elo: elo})
+	s.send(from, &protocol.ChallengeSent{ChallengeInfo: info})
+
+	logger.Info(fmt.Sprintf("Challenge sent: %s -> %s", from, to))
+	return challenge, nil
+}
+
+// Accept принимает вызов и начинает партию.
+func (s *ChallengeService) Accept(nickname, id string) (*models.Challenge, error) {
+	// Игрок, который ждет в очереди или в своей комнате, может сначала
+	// выйти оттуда: вызов пока остается в силе
+	ctx := context.Background()
+	owners := s.GameManager.Owners()
+	if err := checkLobby(ctx, s.RDB, owners, nickname); err != nil {
+		return nil, err
+	}
+	challenge, err := s.take(nickname, id)
+	if err != nil {
+		return nil, err
+	}
+	if s.inGame(challenge.From) || s.inGame(nickname) || checkLobby(ctx, s.RDB, owners, challenge.From) != nil {
+		s.send(challenge.From, challengeUpdate(challenge, "cancelled"))
+		return nil, ErrPlayerBusy
+	}
+
+	s.send(challenge.From, challengeUpdate(challenge, "accepted"))
+
+	symbols := []string{rules.X, rules.O}
+	rand.Shuffle(2, func(i, j int) { symbols[i], symbols[j] = symbols[j], symbols[i] })
+	opts := challenge.Rules.Options()
+	gameID := s.GameManager.CreateGame(challenge.From, nickname, symbols[0], symbols[1], challenge.Rules, challenge.TimeControl, true)
+	s.send(challenge.From, matchFoundMessage(gameID, nickname, symbols[0], opts, challenge.TimeControl))
+	s.send(nickname, matchFoundMessage(gameID, challenge.From, symbols[1], opts, challenge.TimeControl))
+
+	if err := s.RDB.Incr(ctx, "active_games").Err(); err != nil {
+		logger.Warn("failed to increment active_games:", err)
+	}
+	logger.Info(fmt.Sprintf("Challenge accepted: %s vs %s", challenge.From, nickname))
+	return challenge, nil
+}
+
+// Decline отклоняет вызов.
+func (s *ChallengeService) Decline(nickname, id string) error {
+	challenge, err := s.take(nickname, id)
+	if err != nil {
+		return err
+	}
+	s.send(challenge.From, challengeUpdate(challenge, "declined"))
+	return nil
+}
+
+// Cancel отзывает исходящий вызов игрока.
+func (s *ChallengeService) Cancel(nickname string) bool {
+	s.mu.Lock()
+	challenge, ok := s.outgoing[nickname]
+	if ok {
+		s.removeLocked(challenge)
+	}
+	s.mu.Unlock()
+
+	if ok {
+		s.send(challenge.To, challengeUpdate(challenge, "cancelled"))
+	}
+	return ok
+}
+
+// Disconnect отзывает вызовы игрока и отклоняет адресованные ему.
+func (s *ChallengeService) Disconnect(nickname string) {
+	s.Cancel(nickname)
+
+	s.mu.Lock()
+	var incoming []*models.Challenge
+	for _, c := range s.challenges {
+		if c.To == nickname {
+			incoming = append(incoming, c)
+			s.removeLocked(c)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, c := range incoming {
+		s.send(c.From, challengeUpdate(c, "declined"))
+	}
+}
+
+// SetRefuse включает или выключает отказ от всех вызовов.
+func (s *ChallengeService) SetRefuse(nickname string, refuse bool) error {
+	ctx := context.Background()
+	if refuse {
+		return s.RDB.SAdd(ctx, challengeRefusersKey, nickname).Err()
+	}
+	return s.RDB.SRem(ctx, challengeRefusersKey, nickname).Err()
+}
+
+// take снимает вызов, адресованный nickname.
+func (s *ChallengeService) take(nickname, id string) (*models.Challenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, ok := s.challenges[id]
+	if !ok || challenge.To != nickname {
+		return nil, ErrChallengeNotFound
+	}
+	s.removeLocked(challenge)
+	return challenge, nil
+}
+
+func (s *ChallengeService) expire(challenge *models.Challenge) {
+	s.mu.Lock()
+	if s.challenges[challenge.ID] != challenge {
+		s.mu.Unlock()
+		return
+	}
+	s.removeLocked(challenge)
+	s.mu.Unlock()
+
+	update := challengeUpdate(challenge, "expired")
+	s.send(challenge.From, update)
+	s.send(challenge.To, update)
+}
+
+func (s *ChallengeService) removeLocked(challenge *models.Challenge) {
+	if challenge.ExpireTimer != nil {
+		challenge.ExpireTimer.Stop()
+	}
+	delete(s.challenges, challenge.ID)
+	s.GameManager.Owners().Release(OwnedChallenge, challenge.ID)
+	if s.outgoing[challenge.From] == challenge {
+		delete(s.outgoing, challenge.From)
+	}
+}
+
+func (s *ChallengeService) inGame(nickname string) bool {
+	return s.GameManager.Playing(nickname)
+}
+
+func (s *ChallengeService) send(nickname string, msg protocol.Message) {
+	s.Notifier.Send(nickname, msg)
+}
+
+func challengeInfo(challenge *models.Challenge) protocol.ChallengeInfo {
+	info := protocol.ChallengeInfo{
+		ChallengeID: challenge.ID,
+		From:        challenge.From,
+		To:          challenge.To,
+		Board:       boardOf(challenge.Rules.Options()),
+		ExpiresAt:   challenge.CreatedAt.Add(challengeTimeout).Unix(),
+	}
+	if challenge.TimeControl.Enabled() {
+		info.TimeControl = timeControlFields(challenge.TimeControl)
+	}
+	return info
+}
+
+func challengeUpdate(challenge *models.Challenge, status string) *protocol.ChallengeUpdate {
+	return &protocol.ChallengeUpdate{
+		ChallengeID: challenge.ID,
+		From:        challenge.From,
+		To:          challenge.To,
+		Status:      status,
+	}
+}
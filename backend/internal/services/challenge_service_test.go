@@ -0,0 +1,56 @@
+package services
+
+import (
+	"testing"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/rules"
+)
+
+func TestChallengeRejectsUnavailableTargets(t *testing.T) {
+	notifier := newTestNotifier()
+	gm := NewGameManager(nil, nil)
+	s := NewChallengeService(nil, notifier, gm)
+	opts := rules.Options{}
+
+	if _, err := s.Challenge("alice", "alice", opts, models.TimeControl{}); err == nil {
+		t.Error("expected self-challenge to be rejected")
+	}
+	if _, err := s.Challenge("alice", "bob", opts, models.TimeControl{}); err != ErrPlayerOffline {
+		t.Errorf("expected ErrPlayerOffline, got %v", err)
+	}
+
+	notifier.online["bob"] = true
+	notifier.online["carol"] = true
+	gm.CreateGame("bob", "carol", "X", "O", rules.Classic{}, models.TimeControl{}, true)
+	if _, err := s.Challenge("alice", "bob", opts, models.TimeControl{}); err != ErrPlayerBusy {
+		t.Errorf("expected ErrPlayerBusy, got %v", err)
+	}
+	if _, err := s.Challenge("carol", "alice", opts, models.TimeControl{}); err != ErrInGame {
+		t.Errorf("expected ErrInGame, got %v", err)
+	}
+}
+
+func TestChallengeAnswerRequiresTarget(t *testing.T) {
+	notifier := newTestNotifier()
+	s := NewChallengeService(nil, notifier, NewGameManager(nil, nil))
+	challenge := &models.Challenge{ID: "c1", From: "alice", To: "bob", Rules: rules.Classic{}}
+	s.challenges[challenge.ID] = challenge
+	s.outgoing[challenge.From] = challenge
+
+	if err := s.Decline("alice", "c1"); err != ErrChallengeNotFound {
+		t.Fatalf("expected the challenger to be refused, got %v", err)
+	}
+	if err := s.Decline("bob", "c1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.outgoing["alice"]; ok {
+		t.Fatal("expected the declined challenge to be removed")
+	}
+	if len(notifier.sent["alice"]) != 1 {
+		t.Fatalf("expected alice to be told about the decline, got %v", notifier.sent["alice"])
+	}
+	if err := s.Decline("bob", "c1"); err != ErrChallengeNotFound {
+		t.Fatalf("expected a second answer to fail, got %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"tictactoe/internal/logger"
+	"tictactoe/internal/models"
+	"tictactoe/internal/store"
+)
+
+const (
+	maxChatLength = 200
+
+	// Не больше chatBurst сообщений подряд, затем одно раз в chatRefill
+	chatBurst  = 5
+	chatRefill = 2 * time.Second
+)
+
+// Emotes - допустимые быстрые эмоции
+var Emotes = map[string]bool{
+	"gg":        true,
+	"wave":      true,
+	"thumbs_up": true,
+	"laugh":     true,
+	"think":     true,
+	"wow":       true,
+	"sad":       true,
+}
+
+// ProfanityFilter проверяет текст сообщения. Возвращает текст, который
+// можно показать собеседнику, или ok == false, если сообщение нужно отклонить.
+type ProfanityFilter interface {
+	Filter(text string) (filtered string, ok bool)
+}
+
+// WordListFilter заменяет звездочками слова из списка без учета регистра.
+type WordListFilter struct {
+	words map[string]bool
+}
+
+func NewWordListFilter(words []string) *WordListFilter {
+	f := &WordListFilter{words: make(map[string]bool, len(words))}
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			f.words[strings.ToLower(w)] = true
+		}
+	}
+	return f
+}
+
+func (f *WordListFilter) Filter(text string) (string, bool) {
+	if len(f.words) == 0 {
+		return text, true
+	}
+
+	var b strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		word := string(runes[i:j])
+		if f.words[strings.ToLower(word)] {
+			word = strings.Repeat("*", j-i)
+		}
+		b.WriteString(word)
+		i = j
+	}
+	return b.String(), true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+type chatBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+type ChatService struct {
+	mu      sync.Mutex
+	filter  ProfanityFilter
+	store   *store.ChatStore
+	buckets map[string]*chatBucket
+	// muted[a][b] - игрок a не получает сообщения от b
+	muted map[string]map[string]bool
+}
+
+func NewChatService(chatStore *store.ChatStore, filter ProfanityFilter) *ChatService {
+	if filter == nil {
+		filter = NewWordListFilter(nil)
+	}
+	return &ChatService{
+		filter:  filter,
+		store:   chatStore,
+		buckets: make(map[string]*chatBucket),
+		muted:   make(map[string]map[string]bool),
+	}
+}
+
+// ErrRateLimited - игрок пишет в чат слишком часто
+var ErrRateLimited = errors.New("too many messages, slow down")
+
+// ChatGame - игроки партии, в которой идет чат. Копия, снятая под
+// блокировкой GameManager: сама партия может меняться, пока чат работает.
+type ChatGame struct {
+	PlayerX   string
+	PlayerO   string
+	StartedAt time.Time
+}
+
+// Opponent возвращает соперника игрока nickname.
+func (c ChatGame) Opponent(nickname string) string {
+	if nickname == c.PlayerO {
+		return c.PlayerX
+	}
+	return c.PlayerO
+}
+
+// ChatGame возвращает игроков партии nickname для чата. В партиях с ботом
+// чата нет.
+func (g *GameManager) ChatGame(nickname string) (ChatGame, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	game, ok := g.games[nickname]
+	if !ok || game.IsBotGame {
+		return ChatGame{}, false
+	}
+	return ChatGame{PlayerX: game.PlayerX, PlayerO: game.PlayerO, StartedAt: game.StartedAt}, true
+}
+
+// Send проверяет сообщение игрока sender в партии game, сохраняет его и
+// возвращает текст, который нужно доставить.
+func (s *ChatService) Send(game ChatGame, sender, kind, body string) (string, error) {
+	switch kind {
+	case models.ChatKindText:
+		body = strings.TrimSpace(body)
+		if body == "" {
+			return "", fmt.Errorf("empty message")
+		}
+		if utf8.RuneCountInString(body) > maxChatLength {
+			return "", fmt.Errorf("message is longer than %d characters", maxChatLength)
+		}
+	case models.ChatKindEmote:
+		if !Emotes[body] {
+			return "", fmt.Errorf("unknown emote")
+		}
+	default:
+		return "", fmt.Errorf("unknown message kind")
+	}
+
+	if !s.allow(sender, time.Now()) {
+		return "", ErrRateLimited
+	}
+
+	if kind == models.ChatKindText {
+		filtered, ok := s.filter.Filter(body)
+		if !ok {
+			return "", fmt.Errorf("message rejected")
+		}
+		body = filtered
+	}
+
+	if s.store != nil {
+		msg := &models.ChatMessage{
+			PlayerX:       game.PlayerX,
+			PlayerO:       game.PlayerO,
+			GameStartedAt: game.StartedAt,
+			Sender:        sender,
+			Kind:          kind,
+			Body:          body,
+			CreatedAt:     time.Now(),
+		}
+		if err := s.store.SaveMessage(msg); err != nil {
+			logger.Error("Failed to save chat message:", err)
+		}
+	}
+
+	return body, nil
+}
+
+// allow реализует token bucket на пользователя.
+func (s *ChatService) allow(nickname string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[nickname]
+	if !ok {
+		b = &chatBucket{tokens: chatBurst, updated: now}
+		s.buckets[nickname] = b
+	}
+	b.tokens += float64(now.Sub(b.updated)) / float64(chatRefill)
+	if b.tokens > chatBurst {
+		b.tokens = chatBurst
+	}
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// SetMuted включает или выключает сообщения от opponent для nickname.
+func (s *ChatService) SetMuted(nickname, opponent string, muted bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !muted {
+		delete(s.muted[nickname], opponent)
+		return
+	}
+	if s.muted[nickname] == nil {
+		s.muted[nickname] = make(map[string]bool)
+	}
+	s.muted[nickname][opponent] = true
+}
+
+func (s *ChatService) IsMuted(receiver, sender string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.muted[receiver][sender]
+}
+
+// EndGame забывает лимиты и отключенные сообщения игроков партии, когда
+// она удалена.
+func (s *ChatService) EndGame(playerX, playerO string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pair := range [][2]string{{playerX, playerO}, {playerO, playerX}} {
+		delete(s.buckets, pair[0])
+		delete(s.muted[pair[0]], pair[1])
+		if len(s.muted[pair[0]]) == 0 {
+			delete(s.muted, pair[0])
+		}
+	}
+}
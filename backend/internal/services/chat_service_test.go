@@ -0,0 +1,90 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/rules"
+)
+
+func TestWordListFilterMasksWords(t *testing.T) {
+	f := NewWordListFilter([]string{"darn", " Heck "})
+
+	got, ok := f.Filter("Darn it, what the heck! darning")
+	if !ok {
+		t.Fatal("filter rejected message")
+	}
+	if want := "**** it, what the ****! darning"; got != want {
+		t.Fatalf("Filter = %q, want %q", got, want)
+	}
+}
+
+func TestChatSendValidation(t *testing.T) {
+	s := NewChatService(nil, nil)
+	game := ChatGame{PlayerX: "alice", PlayerO: "bob"}
+
+	if _, err := s.Send(game, "alice", models.ChatKindText, "   "); err == nil {
+		t.Error("empty message accepted")
+	}
+	if _, err := s.Send(game, "alice", models.ChatKindText, strings.Repeat("a", maxChatLength+1)); err == nil {
+		t.Error("long message accepted")
+	}
+	if _, err := s.Send(game, "alice", models.ChatKindEmote, "dance"); err == nil {
+		t.Error("unknown emote accepted")
+	}
+	if body, err := s.Send(game, "alice", models.ChatKindEmote, "gg"); err != nil || body != "gg" {
+		t.Errorf("Send(gg) = %q, %v", body, err)
+	}
+}
+
+func TestChatRateLimit(t *testing.T) {
+	s := NewChatService(nil, nil)
+	now := time.Now()
+
+	for i := 0; i < chatBurst; i++ {
+		if !s.allow("alice", now) {
+			t.Fatalf("message %d rejected within burst", i+1)
+		}
+	}
+	if s.allow("alice", now) {
+		t.Fatal("message accepted after burst")
+	}
+	if !s.allow("bob", now) {
+		t.Fatal("limit shared between players")
+	}
+	if !s.allow("alice", now.Add(chatRefill)) {
+		t.Fatal("token not refilled")
+	}
+}
+
+func TestChatEndGameForgetsPlayers(t *testing.T) {
+	s := NewChatService(nil, nil)
+	s.SetMuted("alice", "bob", true)
+	s.SetMuted("carol", "bob", true)
+	s.allow("alice", time.Now())
+	s.allow("bob", time.Now())
+
+	s.EndGame("alice", "bob")
+	if s.IsMuted("alice", "bob") || !s.IsMuted("carol", "bob") {
+		t.Error("EndGame touched the wrong mutes")
+	}
+	if len(s.buckets) != 0 || len(s.muted) != 1 {
+		t.Errorf("left %d buckets and %d mute lists", len(s.buckets), len(s.muted))
+	}
+}
+
+func TestChatGameSkipsBotGames(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	g.CreateGame("alice", "bob", "X", "O", rules.Classic{}, models.TimeControl{}, true)
+	g.CreateBotGame("carol", models.BotPlayer{Elo: 1200}, rules.Classic{}, rules.X, false)
+
+	game, ok := g.ChatGame("bob")
+	if !ok || game.Opponent("bob") != "alice" || game.Opponent("alice") != "bob" {
+		t.Errorf("ChatGame(bob) = %+v, %v", game, ok)
+	}
+	if _, ok := g.ChatGame("carol"); ok {
+		t.Error("bot game has a chat")
+	}
+}
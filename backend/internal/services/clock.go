@@ -0,0 +1,160 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/protocol"
+	"tictactoe/internal/rules"
+)
+
+const (
+	minInitialTime = 10 * time.Second
+	maxInitialTime = time.Hour
+	maxIncrement   = time.Minute
+	minPerMoveTime = 3 * time.Second
+	maxPerMoveTime = 5 * time.Minute
+)
+
+// ErrInvalidTimeControl оборачивает все ошибки validateTimeControl
+var ErrInvalidTimeControl = errors.New("invalid time control")
+
+func validateTimeControl(tc models.TimeControl) error {
+	if tc.Initial > 0 && tc.PerMove > 0 {
+		return fmt.Errorf("%w: choose either a game clock or a per-move limit", ErrInvalidTimeControl)
+	}
+	if tc.Initial < 0 || tc.Increment < 0 || tc.PerMove < 0 {
+		return ErrInvalidTimeControl
+	}
+	if tc.Initial > 0 && (tc.Initial < minInitialTime || tc.Initial > maxInitialTime) {
+		return fmt.Errorf("%w: initial time must be between %s and %s", ErrInvalidTimeControl, minInitialTime, maxInitialTime)
+	}
+	if tc.Increment > maxIncrement || (tc.Increment > 0 && tc.Initial == 0) {
+		return fmt.Errorf("%w: increment must be at most %s and requires an initial time", ErrInvalidTimeControl, maxIncrement)
+	}
+	if tc.PerMove > 0 && (tc.PerMove < minPerMoveTime || tc.PerMove > maxPerMoveTime) {
+		return fmt.Errorf("%w: per-move time must be between %s and %s", ErrInvalidTimeControl, minPerMoveTime, maxPerMoveTime)
+	}
+	return nil
+}
+
+// timeControlKey используется в ключе очереди, чтобы подбирать игроков
+// с одинаковым контролем времени.
+func timeControlKey(tc models.TimeControl) string {
+	switch {
+	case tc.PerMove > 0:
+		return fmt.Sprintf("%d/move", int(tc.PerMove.Seconds()))
+	case tc.Initial > 0:
+		return fmt.Sprintf("%d+%d", int(tc.Initial.Seconds()), int(tc.Increment.Seconds()))
+	}
+	return ""
+}
+
+func timeControlFields(tc models.TimeControl) *protocol.TimeControl {
+	return &protocol.TimeControl{
+		Initial:   int(tc.Initial.Seconds()),
+		Increment: int(tc.Increment.Seconds()),
+		PerMove:   int(tc.PerMove.Seconds()),
+	}
+}
+
+func clockOf(game *models.Game, seat string) *time.Duration {
+	if seat == rules.X {
+		return &game.ClockX
+	}
+	return &game.ClockO
+}
+
+// remainingTime учитывает время, прошедшее с начала текущего хода.
+func remainingTime(game *models.Game, seat string, now time.Time) time.Duration {
+	remaining := *clockOf(game, seat)
+	if seat == game.State.Turn && !game.IsFinished {
+		remaining -= now.Sub(game.TurnStarted)
+	}
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// clockFields возвращает остаток времени игроков в миллисекундах.
+func clockFields(game *models.Game, now time.Time) protocol.Clocks {
+	return protocol.Clocks{
+		rules.X: remainingTime(game, rules.X, now).Milliseconds(),
+		rules.O: remainingTime(game, rules.O, now).Milliseconds(),
+	}
+}
+
+// startClock выставляет часы в начале партии. Вызывается под g.mu.
+func (g *GameManager) startClock(game *models.Game) {
+	tc := game.TimeControl
+	if !tc.Enabled() {
+		return
+	}
+	initial := tc.Initial
+	if tc.PerMove > 0 {
+		initial = tc.PerMove
+	}
+	game.ClockX = initial
+	game.ClockO = initial
+	game.TurnStarted = time.Now()
+	g.scheduleFlag(game)
+}
+
+// chargeClock списывает время хода с часов игрока и добавляет инкремент.
+func (g *GameManager) chargeClock(game *models.Game, seat string, now time.Time) {
+	clock := clockOf(game, seat)
+	*clock -= now.Sub(game.TurnStarted)
+	if game.TimeControl.PerMove > 0 {
+		*clock = game.TimeControl.PerMove
+	} else {
+		*clock += game.TimeControl.Increment
+	}
+	game.TurnStarted = now
+}
+
+func stopClock(game *models.Game) {
+	if game.ClockTimer != nil {
+		game.ClockTimer.Stop()
+		game.ClockTimer = nil
+	}
+}
+
+// scheduleFlag заводит таймер на падение флажка игрока, который сейчас ходит.
+func (g *GameManager) scheduleFlag(game *models.Game) {
+	stopClock(game)
+	state := game.State
+	ply := state.MoveCount
+	seat := state.Turn
+
+	game.ClockTimer = time.AfterFunc(*clockOf(game, seat), func() {
+		g.mu.Lock()
+		if game.IsFinished || game.State != state || state.MoveCount != ply {
+			g.mu.Unlock()
+			return
+		}
+		game.ClockTimer = nil
+		game.IsFinished = true
+		game.Winner = rules.Opposite(seat)
+		game.EndReason = models.ReasonTimeout
+		game.LastActivity = time.Now()
+		*clockOf(game, seat) = 0
+
+		nickname := humans(game)[0]
+		result := &protocol.GameOver{
+			Result: game.Winner,
+			Reason: models.ReasonTimeout,
+			Clocks: clockFields(game, time.Now()),
+		}
+		recordEvent(game, result)
+		g.saveSnapshot(game)
+		onTimeout := g.onTimeout
+		g.mu.Unlock()
+
+		if onTimeout != nil {
+			onTimeout(nickname, result)
+		}
+	})
+}
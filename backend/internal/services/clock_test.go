@@ -0,0 +1,68 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/protocol"
+	"tictactoe/internal/rules"
+)
+
+func TestClockTimeout(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	results := make(chan *protocol.GameOver, 1)
+	g.OnTimeout(func(nickname string, result *protocol.GameOver) {
+		results <- result
+	})
+
+	tc := models.TimeControl{Initial: 200 * time.Millisecond, Increment: 50 * time.Millisecond}
+	g.CreateGame("alice", "bob", "X", "O", rules.Classic{}, tc, true)
+
+	moveMsg, _, err := g.HandleMove("alice", rules.Move{Cell: 4}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clocks := moveMsg.Clocks
+	if clocks["X"] <= 200 || clocks["X"] > 250 {
+		t.Fatalf("expected the increment to be added to X's clock, got %d", clocks["X"])
+	}
+
+	select {
+	case result := <-results:
+		if result.Result != "X" || result.Reason != "timeout" {
+			t.Fatalf("unexpected result %v", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout was not reported")
+	}
+
+	if _, _, err := g.HandleMove("bob", rules.Move{Cell: 0}, ""); err == nil {
+		t.Fatal("expected a move after the flag fell to be rejected")
+	}
+}
+
+func TestValidateTimeControl(t *testing.T) {
+	valid := []models.TimeControl{
+		{},
+		{Initial: 30 * time.Second, Increment: 2 * time.Second},
+		{PerMove: 10 * time.Second},
+	}
+	for _, tc := range valid {
+		if err := validateTimeControl(tc); err != nil {
+			t.Errorf("%+v: unexpected error %v", tc, err)
+		}
+	}
+
+	invalid := []models.TimeControl{
+		{Initial: 30 * time.Second, PerMove: 10 * time.Second},
+		{Increment: 2 * time.Second},
+		{Initial: time.Second},
+		{PerMove: time.Hour},
+	}
+	for _, tc := range invalid {
+		if err := validateTimeControl(tc); err == nil {
+			t.Errorf("%+v: expected an error", tc)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"tictactoe/internal/logger"
+	"tictactoe/internal/models"
+	"tictactoe/internal/protocol"
+)
+
+// defaultReconnectGrace - сколько отключившийся игрок может отсутствовать,
+// прежде чем ему засчитают поражение
+const defaultReconnectGrace = 30 * time.Second
+
+// OnAbandon задает обработчик, который получает game_over, когда
+// отключившийся игрок не вернулся вовремя. nickname - ушедший игрок.
+func (g *GameManager) OnAbandon(fn func(nickname string, result *protocol.GameOver)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.onAbandon = fn
+}
+
+// Disconnect отмечает, что игрок потерял соединение во время партии, и
+// заводит таймер на поражение. Возвращает срок, до которого игрок может
+// вернуться. Для завершенной партии возвращает false.
+func (g *GameManager) Disconnect(nickname string) (time.Time, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	game, ok := g.games[nickname]
+	if !ok || game.IsFinished {
+		return time.Time{}, false
+	}
+	if absence, ok := game.Disconnected[nickname]; ok {
+		return absence.Deadline, true
+	}
+	if game.Disconnected == nil {
+		game.Disconnected = make(map[string]*models.Absence)
+	}
+	absence := &models.Absence{Deadline: time.Now().Add(g.reconnectGrace)}
+	g.armAbsence(game, nickname, absence)
+	game.Disconnected[nickname] = absence
+	g.saveSnapshot(game)
+
+	logger.Info(fmt.Sprintf("Player %s disconnected from game %s", nickname, game.ID))
+	return absence.Deadline, true
+}
+
+// Reconnect снимает отметку об отключении. Возвращает true, если игрок
+// числился отключенным.
+func (g *GameManager) Reconnect(nickname string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	game, ok := g.games[nickname]
+	if !ok {
+		return false
+	}
+	absence, ok := game.Disconnected[nickname]
+	if !ok {
+		return false
+	}
+	absence.Timer.Stop()
+	delete(game.Disconnected, nickname)
+	g.saveSnapshot(game)
+
+	logger.Info(fmt.Sprintf("Player %s reconnected to game %s", nickname, game.ID))
+	return true
+}
+
+// armAbsence заводит таймер поражения до absence.Deadline. Вызывается под g.mu.
+func (g *GameManager) armAbsence(game *models.Game, nickname string, absence *models.Absence) {
+	absence.Timer = time.AfterFunc(time.Until(absence.Deadline), func() {
+		g.abandon(game, nickname, absence)
+	})
+}
+
+// resumeAbsences заводит таймеры отключений восстановленной партии. Срок,
+// истекший во время простоя, засчитывает поражение сразу. Вызывается под g.mu.
+func (g *GameManager) resumeAbsences(game *models.Game) {
+	for nickname, absence := range game.Disconnected {
+		g.armAbsence(game, nickname, absence)
+	}
+}
+
+// HumanOpponent возвращает соперника nickname по партии. Для партии с
+// ботом возвращает false: боту сообщать нечего.
+func (g *GameManager) HumanOpponent(nickname string) (string, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	game, ok := g.games[nickname]
+	if !ok || game.IsBotGame {
+		return "", false
+	}
+	if nickname == game.PlayerO {
+		return game.PlayerX, true
+	}
+	return game.PlayerO, true
+}
+
+// abandon засчитывает поражение игроку, который не вернулся за отведенное время.
+func (g *GameManager) abandon(game *models.Game, nickname string, absence *models.Absence) {
+	g.mu.Lock()
+	if g.games[nickname] != game || game.Disconnected[nickname] != absence {
+		g.mu.Unlock()
+		return
+	}
+	delete(game.Disconnected, nickname)
+	result, ok := g.forfeitLocked(nickname, models.ReasonAbandoned)
+	onAbandon := g.onAbandon
+	g.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	logger.Info(fmt.Sprintf("Player %s abandoned game %s", nickname, game.ID))
+	if onAbandon != nil {
+		onAbandon(nickname, result)
+	}
+}
+
+func stopAbsences(game *models.Game) {
+	for nickname, absence := range game.Disconnected {
+		absence.Timer.Stop()
+		delete(game.Disconnected, nickname)
+	}
+}
+
+// secondsUntil округляет оставшееся время вверх до целых секунд.
+func secondsUntil(deadline time.Time, now time.Time) int {
+	left := deadline.Sub(now)
+	if left <= 0 {
+		return 0
+	}
+	return int(math.Ceil(left.Seconds()))
+}
+
+// disconnectedFields возвращает отключившихся игроков и сколько секунд
+// у каждого осталось на возвращение.
+func disconnectedFields(game *models.Game, now time.Time) map[string]int {
+	fields := make(map[string]int, len(game.Disconnected))
+	for nickname, absence := range game.Disconnected {
+		fields[nickname] = secondsUntil(absence.Deadline, now)
+	}
+	return fields
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/protocol"
+	"tictactoe/internal/rules"
+)
+
+func TestDisconnectAbandonsAfterGrace(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	g.reconnectGrace = 50 * time.Millisecond
+	results := make(chan *protocol.GameOver, 1)
+	g.OnAbandon(func(nickname string, result *protocol.GameOver) {
+		if nickname != "bob" {
+			t.Errorf("abandon reported for %s, want bob", nickname)
+		}
+		results <- result
+	})
+	g.CreateGame("alice", "bob", "X", "O", rules.Classic{}, models.TimeControl{}, true)
+
+	if _, ok := g.Disconnect("bob"); !ok {
+		t.Fatal("expected bob to be marked disconnected")
+	}
+	state, _ := g.GameState("alice")
+	if _, ok := state.Disconnected["bob"]; !ok {
+		t.Fatalf("game_state does not report the disconnect: %v", state)
+	}
+
+	select {
+	case result := <-results:
+		if result.Result != "X" || result.Reason != models.ReasonAbandoned {
+			t.Fatalf("unexpected result %v", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("abandonment was not reported")
+	}
+	game, _ := g.GetGame("alice")
+	if !game.IsFinished || len(game.Disconnected) != 0 {
+		t.Fatalf("game not finished by abandonment: %+v", game)
+	}
+}
+
+func TestReconnectCancelsAbandonment(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	g.reconnectGrace = 50 * time.Millisecond
+	abandoned := make(chan struct{}, 1)
+	g.OnAbandon(func(string, *protocol.GameOver) { abandoned <- struct{}{} })
+	g.CreateGame("alice", "bob", "X", "O", rules.Classic{}, models.TimeControl{}, true)
+
+	g.Disconnect("bob")
+	if !g.Reconnect("bob") {
+		t.Fatal("expected bob to be reconnected")
+	}
+	if g.Reconnect("bob") {
+		t.Fatal("a second reconnect should be a no-op")
+	}
+
+	select {
+	case <-abandoned:
+		t.Fatal("game was abandoned after the player came back")
+	case <-time.After(150 * time.Millisecond):
+	}
+	if game, _ := g.GetGame("bob"); game.IsFinished {
+		t.Fatal("game finished after reconnect")
+	}
+}
+
+func TestDisconnectIgnoresFinishedGame(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	g.CreateGame("alice", "bob", "X", "O", rules.Classic{}, models.TimeControl{}, true)
+	g.Forfeit("alice", models.ReasonForfeit)
+
+	if _, ok := g.Disconnect("bob"); ok {
+		t.Fatal("finished games have no grace period")
+	}
+}
+
+func TestHandleDisconnectNotifiesOpponent(t *testing.T) {
+	notifier := newTestNotifier("alice", "carol")
+	g := NewGameManager(nil, nil)
+	m := NewMatchmakerService(nil, notifier, g)
+	g.CreateGame("alice", "bob", "X", "O", rules.Classic{}, models.TimeControl{}, true)
+	game, _ := g.GetGame("alice")
+	defer stopAbsences(game)
+	if _, err := g.Spectate("carol", "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	m.HandleDisconnect("bob")
+
+	for _, nickname := range []string{"alice", "carol"} {
+		sent := notifier.sent[nickname]
+		if len(sent) == 0 {
+			t.Fatalf("%s was not notified", nickname)
+		}
+		msg, ok := sent[len(sent)-1].(*protocol.OpponentDisconnected)
+		if !ok || msg.Nickname != "bob" || msg.Seconds != 30 {
+			t.Fatalf("unexpected message to %s: %v", nickname, msg)
+		}
+	}
+	if len(notifier.sent["bob"]) != 0 {
+		t.Fatal("the disconnected player should not be notified")
+	}
+}
+
+func TestHumanOpponent(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	g.CreateGame("alice", "bob", "X", "O", rules.Classic{}, models.TimeControl{}, true)
+	g.CreateBotGame("carol", models.BotPlayer{Elo: 1200}, rules.Classic{}, rules.X, false)
+
+	if opponent, ok := g.HumanOpponent("bob"); !ok || opponent != "alice" {
+		t.Errorf("HumanOpponent(bob) = %q, %v", opponent, ok)
+	}
+	if opponent, ok := g.HumanOpponent("alice"); !ok || opponent != "bob" {
+		t.Errorf("HumanOpponent(alice) = %q, %v", opponent, ok)
+	}
+	if opponent, ok := g.HumanOpponent("carol"); ok {
+		t.Errorf("bot game reported opponent %q", opponent)
+	}
+}
+
+func TestRestoredGameKeepsAbandonmentTimer(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	g.CreateGame("alice", "bob", "X", "O", rules.Classic{}, models.TimeControl{}, true)
+	g.Disconnect("bob")
+	game, _ := g.GetGame("alice")
+	snap := snapshotOf(game)
+	stopAbsences(game)
+
+	// The snapshot is restored on another instance after part of the grace period
+	snap.Disconnected["bob"] = time.Now().Add(50 * time.Millisecond)
+	other := NewGameManager(nil, nil)
+	results := make(chan *protocol.GameOver, 1)
+	other.OnAbandon(func(nickname string, result *protocol.GameOver) { results <- result })
+	restored, err := snap.restore(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.mu.Lock()
+	for _, nickname := range humans(restored) {
+		other.games[nickname] = restored
+	}
+	other.resumeAbsences(restored)
+	other.mu.Unlock()
+
+	if state, _ := other.GameState("alice"); state.Disconnected["bob"] == 0 {
+		t.Fatalf("restored game_state lost the disconnect: %v", state.Disconnected)
+	}
+	select {
+	case result := <-results:
+		if result.Result != "X" || result.Reason != models.ReasonAbandoned {
+			t.Fatalf("unexpected result %v", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("restored game was not abandoned")
+	}
+}
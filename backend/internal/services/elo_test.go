@@ -1,7 +1,6 @@
 package services
 
 import (
-	"math"
 	"testing"
 )
 
@@ -22,11 +21,7 @@ func TestCalculateElo(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			kFactor := 32
-			expectedScoreA := 1.0 / (1.0 + math.Pow(10, float64(tt.ratingB-tt.ratingA)/400.0))
-			changeA := int(float64(kFactor) * (tt.scoreA - expectedScoreA))
-
-			// Allow slight deviation due to floating point math, but for int cast it should be exact-ish
+			changeA := eloChange(tt.ratingA, tt.ratingB, tt.scoreA)
 			if changeA != tt.expectedA {
 				t.Errorf("expected change %d, got %d", tt.expectedA, changeA)
 			}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"tictactoe/internal/rules"
+)
+
+// ServeEngine играет роль внешнего движка (см. протокол в
+// external_engine.go): читает команды из in и отвечает в out ходами
+// встроенного движка - точного перебора на маленьких досках и MCTS с
+// бюджетом movetime на остальных. Возвращается после quit или конца in.
+func ServeEngine(name string, in io.Reader, out io.Writer) error {
+	var gameRules rules.GameRules
+	var state *rules.State
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var err error
+		switch fields[0] {
+		case "hello":
+			_, err = fmt.Fprintf(out, "id name %s\nready\n", name)
+		case "position":
+			var perr error
+			gameRules, state, perr = parsePosition(fields[1:])
+			if perr != nil {
+				_, err = fmt.Fprintf(out, "info error %v\n", perr)
+			}
+		case "go":
+			move := rules.Move{Cell: -1}
+			if gameRules != nil {
+				move = engineMove(gameRules, state, goMoveTime(fields[1:]))
+			}
+			token := "none"
+			if move.Cell != -1 {
+				token = formatMoveToken(move)
+			}
+			_, err = fmt.Fprintf(out, "bestmove %s\n", token)
+		case "quit":
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// goMoveTime достает movetime из аргументов go; без него - бюджет MCTS по
+// умолчанию.
+func goMoveTime(args []string) time.Duration {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "movetime" {
+			if ms, err := strconv.Atoi(args[i+1]); err == nil && ms > 0 {
+				return time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	return defaultMCTSBudget
+}
+
+// engineMove - лучший ход встроенного движка за время movetime.
+func engineMove(gameRules rules.GameRules, state *rules.State, movetime time.Duration) rules.Move {
+	if gameRules.Outcome(state).Finished {
+		return rules.Move{Cell: -1}
+	}
+	seed := time.Now().UnixNano()
+	b := &BotService{
+		rand: rand.New(rand.NewSource(seed)),
+		mcts: NewMCTSEngine(MCTSConfig{
+			Playouts: defaultMCTSPlayouts,
+			// Запас на разбор команды и ответ
+			Budget: movetime * 9 / 10,
+			Seed:   seed,
+		}),
+	}
+	return b.getHardMove(gameRules, state)
+}
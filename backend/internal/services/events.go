@@ -0,0 +1,90 @@
+package services
+
+import (
+	"errors"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/protocol"
+)
+
+// maxGameEvents - сколько последних событий партии хранится для resume
+const maxGameEvents = 256
+
+// ErrDuplicateMove - ход с этим id уже сделан
+var ErrDuplicateMove = errors.New("move already played")
+
+// recordEvent присваивает событию следующий номер партии и добавляет его
+// в журнал. Вызывается под g.mu.
+func recordEvent(game *models.Game, event protocol.Event) {
+	game.Seq++
+	event.SetSequence(game.Seq)
+	game.Events = append(game.Events, event)
+	if len(game.Events) > maxGameEvents {
+		game.Events = append(game.Events[:0:0], game.Events[len(game.Events)-maxGameEvents:]...)
+	}
+}
+
+// RecordEvent нумерует событие партии nickname и сохраняет его в журнале.
+// Возвращает false, если партии нет.
+func (g *GameManager) RecordEvent(nickname string, event protocol.Event) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	game, ok := g.games[nickname]
+	if !ok {
+		return false
+	}
+	recordEvent(game, event)
+	return true
+}
+
+// eventAt возвращает событие с номером seq, если оно еще в журнале.
+func eventAt(game *models.Game, seq int64) (protocol.Event, bool) {
+	if len(game.Events) == 0 {
+		return nil, false
+	}
+	i := int(seq - game.Events[0].Sequence())
+	if i < 0 || i >= len(game.Events) {
+		return nil, false
+	}
+	return game.Events[i].(protocol.Event), true
+}
+
+// moveKey - ключ id хода в MoveIDs: id выбирают клиенты, поэтому у
+// соперников они могут совпадать.
+func moveKey(seat, id string) string {
+	return seat + ":" + id
+}
+
+// Resume возвращает события партии gameID после lastSeq для игрока
+// nickname. Если журнал уже не доходит до lastSeq, вместо событий
+// возвращается снимок партии.
+func (g *GameManager) Resume(nickname, gameID string, lastSeq int64) ([]protocol.Event, *protocol.GameState, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	game, ok := g.games[nickname]
+	if !ok {
+		return nil, nil, ErrNoActiveGame
+	}
+	if game.ID != gameID {
+		return nil, nil, ErrGameNotFound
+	}
+	if lastSeq == game.Seq {
+		return nil, nil, nil
+	}
+	// Номер из будущего бывает, если клиент перепутал партию
+	if lastSeq < 0 || lastSeq > game.Seq {
+		return nil, gameStateMessage(game), nil
+	}
+	first, ok := eventAt(game, lastSeq+1)
+	if !ok {
+		return nil, gameStateMessage(game), nil
+	}
+	i := int(first.Sequence() - game.Events[0].Sequence())
+	events := make([]protocol.Event, 0, len(game.Events)-i)
+	for _, event := range game.Events[i:] {
+		events = append(events, event.(protocol.Event))
+	}
+	return events, nil, nil
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/protocol"
+	"tictactoe/internal/rules"
+)
+
+func TestEventsAreSequenced(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	g.CreateGame("alice", "bob", "X", "O", rules.Classic{}, models.TimeControl{}, true)
+
+	first, _, err := g.HandleMove("alice", rules.Move{Cell: 0}, "a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	disconnected := &protocol.OpponentDisconnected{Nickname: "alice"}
+	if !g.RecordEvent("bob", disconnected) {
+		t.Fatal("event was not recorded")
+	}
+	second, _, err := g.HandleMove("bob", rules.Move{Cell: 3}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Seq != 1 || disconnected.Seq != 2 || second.Seq != 3 {
+		t.Fatalf("sequence numbers %d, %d, %d", first.Seq, disconnected.Seq, second.Seq)
+	}
+	if first.MoveID != "a1" || second.MoveID != "" {
+		t.Fatalf("move ids %q, %q", first.MoveID, second.MoveID)
+	}
+
+	state, _ := g.GameState("alice")
+	if state.Seq != 3 {
+		t.Fatalf("game_state seq = %d", state.Seq)
+	}
+	if g.RecordEvent("carol", &protocol.Spectators{}) {
+		t.Fatal("event recorded for a player without a game")
+	}
+}
+
+func TestDuplicateMove(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	g.CreateGame("alice", "bob", "X", "O", rules.Classic{}, models.TimeControl{}, true)
+
+	original, _, err := g.HandleMove("alice", rules.Move{Cell: 4}, "m1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repeated, _, err := g.HandleMove("alice", rules.Move{Cell: 4}, "m1")
+	if !errors.Is(err, ErrDuplicateMove) || repeated != original {
+		t.Fatalf("retried move: %v, %v", repeated, err)
+	}
+	// Ids are per player, so the opponent may reuse one.
+	if _, _, err := g.HandleMove("bob", rules.Move{Cell: 0}, "m1"); err != nil {
+		t.Fatal(err)
+	}
+	game, _ := g.GetGame("alice")
+	if len(game.Moves) != 2 || game.Seq != 2 {
+		t.Fatalf("expected two moves and events, got %d and %d", len(game.Moves), game.Seq)
+	}
+}
+
+func TestDuplicateMoveAfterGameOver(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	g.CreateGame("alice", "bob", "X", "O", rules.Classic{}, models.TimeControl{}, true)
+
+	moves := []struct {
+		player string
+		cell   int
+	}{{"alice", 0}, {"bob", 3}, {"alice", 1}, {"bob", 4}, {"alice", 2}}
+	var last *protocol.MoveMade
+	for i, m := range moves {
+		moveMsg, result, err := g.HandleMove(m.player, rules.Move{Cell: m.cell}, string(rune('a'+i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		last = moveMsg
+		if i == len(moves)-1 && result == nil {
+			t.Fatal("expected the game to be over")
+		}
+	}
+	repeated, _, err := g.HandleMove("alice", rules.Move{Cell: 2}, "e")
+	if !errors.Is(err, ErrDuplicateMove) || repeated != last {
+		t.Fatalf("retried winning move: %v, %v", repeated, err)
+	}
+}
+
+func TestResume(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	g.CreateGame("alice", "bob", "X", "O", rules.Classic{}, models.TimeControl{}, true)
+	game, _ := g.GetGame("alice")
+
+	for i, cell := range []int{0, 3, 1} {
+		player := "alice"
+		if i%2 == 1 {
+			player = "bob"
+		}
+		if _, _, err := g.HandleMove(player, rules.Move{Cell: cell}, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, state, err := g.Resume("bob", game.ID, 1)
+	if err != nil || state != nil {
+		t.Fatalf("Resume: %v, %v", state, err)
+	}
+	if len(events) != 2 || events[0].Sequence() != 2 || events[1].Sequence() != 3 {
+		t.Fatalf("expected events 2 and 3, got %v", events)
+	}
+
+	if events, state, _ := g.Resume("bob", game.ID, 3); len(events) != 0 || state != nil {
+		t.Fatal("nothing should be replayed to an up-to-date client")
+	}
+	if _, state, _ := g.Resume("bob", game.ID, 7); state == nil {
+		t.Fatal("a sequence from the future should get a snapshot")
+	}
+	if _, _, err := g.Resume("bob", "other", 0); !errors.Is(err, ErrGameNotFound) {
+		t.Fatalf("wrong game id: %v", err)
+	}
+	if _, _, err := g.Resume("carol", game.ID, 0); !errors.Is(err, ErrNoActiveGame) {
+		t.Fatalf("not a player: %v", err)
+	}
+}
+
+func TestResumeAfterLogTrimmed(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	g.CreateGame("alice", "bob", "X", "O", rules.Classic{}, models.TimeControl{}, true)
+	game, _ := g.GetGame("alice")
+
+	for i := 0; i < maxGameEvents+10; i++ {
+		g.RecordEvent("alice", &protocol.Spectators{Count: i})
+	}
+	if len(game.Events) != maxGameEvents || game.Events[0].Sequence() != 11 {
+		t.Fatalf("log holds %d events from %d", len(game.Events), game.Events[0].Sequence())
+	}
+
+	events, state, err := g.Resume("alice", game.ID, 5)
+	if err != nil || len(events) != 0 || state == nil || state.Seq != game.Seq {
+		t.Fatalf("expected a snapshot, got %v, %v, %v", events, state, err)
+	}
+	events, state, _ = g.Resume("alice", game.ID, 10)
+	if state != nil || len(events) != maxGameEvents {
+		t.Fatalf("expected the whole log, got %d events", len(events))
+	}
+}
@@ -0,0 +1,402 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tictactoe/internal/logger"
+	"tictactoe/internal/rules"
+)
+
+// Протокол внешнего движка - строки через stdin/stdout, как UCI в шахматах.
+// Сервер пишет команды, движок отвечает:
+//
+//	hello 1                         -> id name <имя>, затем ready
+//	position <вариант> <доска> <ход> <последний ход>
+//	go movetime <мс>                -> bestmove <ход> или bestmove none
+//	quit
+//
+// Вариант записывается как rules.Options.Key() (classic, gomoku:15x5),
+// доска - клетки подряд, . для пустой (X...O....), ход - X или O,
+// последний ход - номер клетки или -1. Ход в bestmove - номер клетки, в
+// wild с меткой: 4X. Строки info движок может писать в любой момент, они
+// пропускаются, как и незнакомые команды.
+const engineProtocolVersion = 1
+
+// Значения ExternalEngineConfig по умолчанию
+const (
+	defaultEngineMoveTime  = defaultMCTSBudget
+	defaultEngineGrace     = 2 * time.Second
+	defaultEngineProcesses = 2
+)
+
+// Ошибки внешнего движка. Любая из них означает, что бот сдает партию.
+var (
+	ErrEngineFailed      = errors.New("external engine failed")
+	ErrEngineTimeout     = fmt.Errorf("%w: no answer in time", ErrEngineFailed)
+	ErrEngineIllegalMove = fmt.Errorf("%w: illegal move", ErrEngineFailed)
+)
+
+// ExternalEngineConfig - как запускать внешний движок.
+type ExternalEngineConfig struct {
+	Path string
+	Args []string
+	// MoveTime - время на ход, которое получает движок в go movetime
+	MoveTime time.Duration
+	// Grace - сколько ждать сверх MoveTime, прежде чем считать, что
+	// движок завис; столько же ждем ready при запуске
+	Grace time.Duration
+	// Processes - сколько процессов движка держать для одновременных партий
+	Processes int
+}
+
+// ExternalEngine запускает движок отдельными процессами и спрашивает у них
+// ходы. Каждый процесс думает над одним ходом за раз; ход, которому не
+// хватило свободного процесса, ждет его, но весь Move вместе с очередью
+// укладывается в MoveTime+Grace. Зависший движок убивается, упавший
+// перезапускается при следующем ходе.
+type ExternalEngine struct {
+	config ExternalEngineConfig
+	// idle - свободные места пула; занятое место возвращается после хода
+	idle chan *engineSlot
+}
+
+// engineSlot - место в пуле. proc равен nil, пока движок не запущен или
+// после сбоя.
+type engineSlot struct {
+	config *ExternalEngineConfig
+	proc   *engineProcess
+}
+
+// engineProcess - запущенный движок. lines закрывается, когда движок
+// закрывает stdout.
+type engineProcess struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string
+	name  string
+}
+
+var (
+	externalMu sync.RWMutex
+	// externalEngine - движок, которым ходят все боты, или nil
+	externalEngine *ExternalEngine
+)
+
+// UseExternalEngine заставляет ботов, созданных после вызова, ходить
+// движком engine; nil возвращает встроенные движки.
+func UseExternalEngine(engine *ExternalEngine) {
+	externalMu.Lock()
+	defer externalMu.Unlock()
+	externalEngine = engine
+}
+
+func currentExternalEngine() *ExternalEngine {
+	externalMu.RLock()
+	defer externalMu.RUnlock()
+	return externalEngine
+}
+
+func NewExternalEngine(config ExternalEngineConfig) *ExternalEngine {
+	if config.MoveTime <= 0 {
+		config.MoveTime = defaultEngineMoveTime
+	}
+	if config.Grace <= 0 {
+		config.Grace = defaultEngineGrace
+	}
+	if config.Processes <= 0 {
+		config.Processes = defaultEngineProcesses
+	}
+	e := &ExternalEngine{config: config, idle: make(chan *engineSlot, config.Processes)}
+	for i := 0; i < config.Processes; i++ {
+		e.idle <- &engineSlot{config: &e.config}
+	}
+	return e
+}
+
+// Start запускает один процесс движка, если он еще не запущен, и
+// возвращает имя движка. Остальные процессы запускаются по мере нужды.
+func (e *ExternalEngine) Start() (string, error) {
+	deadline := time.Now().Add(e.config.Grace)
+	slot, err := e.acquire(deadline)
+	if err != nil {
+		return "", err
+	}
+	defer e.release(slot)
+	if err := slot.ensureStarted(deadline); err != nil {
+		return "", err
+	}
+	return slot.proc.name, nil
+}
+
+// Move спрашивает у движка ход. Ход проверяется по правилам: движок,
+// который не ответил вовремя, упал или сходил не по правилам, получает
+// ошибку ErrEngineFailed. Если все процессы заняты дольше, чем остается
+// на ход, возвращается ErrEngineTimeout.
+func (e *ExternalEngine) Move(gameRules rules.GameRules, state *rules.State) (rules.Move, error) {
+	deadline := time.Now().Add(e.config.MoveTime + e.config.Grace)
+	// Место нужно получить, пока на ответ остается хотя бы Grace
+	slot, err := e.acquire(deadline.Add(-e.config.Grace))
+	if err != nil {
+		return rules.Move{Cell: -1}, err
+	}
+	defer e.release(slot)
+	if err := slot.ensureStarted(deadline); err != nil {
+		return rules.Move{Cell: -1}, err
+	}
+
+	// Время, потраченное в очереди и на запуск, вычитается из movetime
+	moveTime := e.config.MoveTime
+	if left := time.Until(deadline) - e.config.Grace; left < moveTime {
+		moveTime = left
+	}
+	if moveTime < time.Millisecond {
+		moveTime = time.Millisecond
+	}
+	slot.send(formatPosition(gameRules, state))
+	slot.send(fmt.Sprintf("go movetime %d", moveTime.Milliseconds()))
+	line, err := slot.waitFor("bestmove", deadline)
+	if err != nil {
+		return rules.Move{Cell: -1}, err
+	}
+
+	token := strings.TrimSpace(strings.TrimPrefix(line, "bestmove"))
+	if token == "none" && len(gameRules.LegalMoves(state)) == 0 {
+		return rules.Move{Cell: -1}, nil
+	}
+	move, err := parseMoveToken(token)
+	if err == nil {
+		err = gameRules.Apply(state.Clone(), move)
+	}
+	if err != nil {
+		return rules.Move{Cell: -1}, fmt.Errorf("%w: %q", ErrEngineIllegalMove, token)
+	}
+	return move, nil
+}
+
+// BestMove - Move без ошибки: при сбое движка возвращается Cell == -1.
+func (e *ExternalEngine) BestMove(gameRules rules.GameRules, state *rules.State) rules.Move {
+	move, err := e.Move(gameRules, state)
+	if err != nil {
+		logger.Warn("external engine:", err)
+	}
+	return move
+}
+
+// Close просит процессы движка завершиться и убивает те, что не успели.
+// Ходы, которые уже считаются, Close дожидается.
+func (e *ExternalEngine) Close() {
+	for i := 0; i < e.config.Processes; i++ {
+		slot := <-e.idle
+		slot.close()
+		defer e.release(slot)
+	}
+}
+
+// acquire ждет свободное место в пуле до deadline.
+func (e *ExternalEngine) acquire(deadline time.Time) (*engineSlot, error) {
+	select {
+	case slot := <-e.idle:
+		return slot, nil
+	default:
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case slot := <-e.idle:
+		return slot, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("%w: all %d engine processes are busy", ErrEngineTimeout, e.config.Processes)
+	}
+}
+
+func (e *ExternalEngine) release(slot *engineSlot) {
+	e.idle <- slot
+}
+
+// close просит движок завершиться и убивает его, если он не успел.
+func (s *engineSlot) close() {
+	if s.proc == nil {
+		return
+	}
+	s.send("quit")
+	s.proc.stdin.Close()
+	select {
+	case <-drain(s.proc.lines):
+	case <-time.After(s.config.Grace):
+	}
+	s.stop()
+}
+
+// ensureStarted запускает движок и ждет ready, но не дольше Grace и не
+// позже deadline.
+func (s *engineSlot) ensureStarted(deadline time.Time) error {
+	if s.proc != nil {
+		return nil
+	}
+	cmd := exec.Command(s.config.Path, s.config.Args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrEngineFailed, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrEngineFailed, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%w: %v", ErrEngineFailed, err)
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	s.proc = &engineProcess{cmd: cmd, stdin: stdin, lines: lines, name: s.config.Path}
+
+	s.send(fmt.Sprintf("hello %d", engineProtocolVersion))
+	wait := s.config.Grace
+	if left := time.Until(deadline); left < wait {
+		wait = left
+	}
+	timeout := time.After(wait)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				s.stop()
+				return fmt.Errorf("%w: exited during handshake", ErrEngineFailed)
+			}
+			if name, ok := strings.CutPrefix(line, "id name "); ok {
+				s.proc.name = strings.TrimSpace(name)
+			}
+			if strings.TrimSpace(line) == "ready" {
+				logger.Info("External engine started:", s.proc.name)
+				return nil
+			}
+		case <-timeout:
+			s.stop()
+			return fmt.Errorf("%w: no ready in %v", ErrEngineTimeout, wait)
+		}
+	}
+}
+
+// waitFor ждет строку, которая начинается с prefix. Если движок молчит
+// до deadline или завершился, он останавливается и будет запущен заново
+// при следующем ходе.
+func (s *engineSlot) waitFor(prefix string, deadline time.Time) (string, error) {
+	timeout := time.After(time.Until(deadline))
+	for {
+		select {
+		case line, ok := <-s.proc.lines:
+			if !ok {
+				s.stop()
+				return "", fmt.Errorf("%w: engine exited", ErrEngineFailed)
+			}
+			if line == prefix || strings.HasPrefix(line, prefix+" ") {
+				return line, nil
+			}
+		case <-timeout:
+			s.stop()
+			return "", ErrEngineTimeout
+		}
+	}
+}
+
+// send пишет команду движку. Ошибку записи (движок упал) увидит waitFor,
+// когда закроется stdout.
+func (s *engineSlot) send(command string) {
+	if _, err := io.WriteString(s.proc.stdin, command+"\n"); err != nil {
+		logger.Warn("external engine write failed:", err)
+	}
+}
+
+// stop убивает процесс движка.
+func (s *engineSlot) stop() {
+	proc := s.proc
+	s.proc = nil
+	proc.stdin.Close()
+	if proc.cmd.Process != nil {
+		proc.cmd.Process.Kill()
+	}
+	go func() {
+		// Дочитываем stdout, чтобы горутина чтения завершилась
+		<-drain(proc.lines)
+		proc.cmd.Wait()
+	}()
+}
+
+// drain читает lines до закрытия и закрывает возвращаемый канал.
+func drain(lines <-chan string) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		for range lines {
+		}
+		close(done)
+	}()
+	return done
+}
+
+// formatPosition - команда position для позиции state.
+func formatPosition(gameRules rules.GameRules, state *rules.State) string {
+	var sb strings.Builder
+	for _, mark := range state.Board {
+		if mark == "" {
+			mark = "."
+		}
+		sb.WriteString(mark)
+	}
+	return fmt.Sprintf("position %s %s %s %d", gameRules.Options().Key(), sb.String(), state.Turn, state.LastMove)
+}
+
+// parsePosition разбирает аргументы команды position.
+func parsePosition(args []string) (rules.GameRules, *rules.State, error) {
+	if len(args) != 4 {
+		return nil, nil, errors.New("position needs variant, board, turn and last move")
+	}
+	opts, err := parseOptionsKey(args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	gameRules, err := rules.New(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	state := gameRules.NewState()
+	if len(args[1]) != len(state.Board) {
+		return nil, nil, fmt.Errorf("board has %d cells, want %d", len(args[1]), len(state.Board))
+	}
+	for i, c := range args[1] {
+		switch c {
+		case '.':
+		case 'X', 'O':
+			state.Board[i] = string(c)
+			state.MoveCount++
+		default:
+			return nil, nil, fmt.Errorf("bad cell %q", c)
+		}
+	}
+	if args[2] != rules.X && args[2] != rules.O {
+		return nil, nil, fmt.Errorf("bad turn %q", args[2])
+	}
+	state.Turn = args[2]
+	if state.LastMove, err = strconv.Atoi(args[3]); err != nil {
+		return nil, nil, err
+	}
+	return gameRules, state, nil
+}
+
+// formatMoveToken - ход в виде 4 или, если метка выбирается, 4X.
+func formatMoveToken(move rules.Move) string {
+	return strconv.Itoa(move.Cell) + move.Symbol
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/rules"
+)
+
+// TestMain lets the test binary act as an external engine: with
+// TEST_ENGINE_MODE set it speaks the engine protocol instead of running
+// the tests.
+func TestMain(m *testing.M) {
+	if mode := os.Getenv("TEST_ENGINE_MODE"); mode != "" {
+		runTestEngine(mode)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runTestEngine serves the protocol with the reference engine or with one
+// of several broken engines.
+func runTestEngine(mode string) {
+	if mode == "crash-once" {
+		marker := os.Getenv("TEST_ENGINE_MARKER")
+		if _, err := os.Stat(marker); err == nil {
+			mode = "reference"
+		} else {
+			os.WriteFile(marker, nil, 0o644)
+			mode = "crash"
+		}
+	}
+	if mode == "reference" {
+		ServeEngine("reference", os.Stdin, os.Stdout)
+		return
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		switch strings.Fields(scanner.Text() + " _")[0] {
+		case "hello":
+			if mode != "mute" {
+				fmt.Println("ready")
+			}
+		case "go":
+			switch mode {
+			case "crash":
+				os.Exit(3)
+			case "illegal":
+				fmt.Println("info playing off the board")
+				fmt.Println("bestmove 99")
+			}
+		case "quit":
+			return
+		}
+	}
+}
+
+func testEngine(t *testing.T, mode string, processes int) *ExternalEngine {
+	t.Helper()
+	t.Setenv("TEST_ENGINE_MODE", mode)
+	t.Setenv("TEST_ENGINE_MARKER", filepath.Join(t.TempDir(), "crashed"))
+	engine := NewExternalEngine(ExternalEngineConfig{
+		Path:      os.Args[0],
+		MoveTime:  100 * time.Millisecond,
+		Grace:     time.Second,
+		Processes: processes,
+	})
+	t.Cleanup(engine.Close)
+	return engine
+}
+
+func TestExternalEnginePlaysReferenceMoves(t *testing.T) {
+	engine := testEngine(t, "reference", 1)
+	name, err := engine.Start()
+	if err != nil || name != "reference" {
+		t.Fatalf("start: %q %v", name, err)
+	}
+
+	b := NewBotService()
+	b.external = engine
+	// X: 0, 1; O: 3, 4. X wins at 2.
+	move, err := b.GetBotMove(rules.Classic{}, play(t, rules.Classic{}, 0, 3, 1, 4), models.MaxBotElo, "")
+	if err != nil || move.Cell != 2 {
+		t.Errorf("got %+v, %v, want the win at 2", move, err)
+	}
+
+	move, err = engine.Move(rules.Wild{}, rules.Wild{}.NewState())
+	if err != nil || move.Symbol == "" {
+		t.Errorf("wild move %+v, %v has no mark", move, err)
+	}
+
+	ultimate := rules.Ultimate{}
+	state := ultimate.NewState()
+	for _, cell := range []int{40, 36, 4} {
+		if err := ultimate.Apply(state, rules.Move{Cell: cell}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The last move sends O to board 4
+	if move, err = engine.Move(ultimate, state); err != nil || move.Cell/9 != 4 {
+		t.Errorf("ultimate move %+v, %v ignores the forced board", move, err)
+	}
+}
+
+func TestExternalEngineTimesOut(t *testing.T) {
+	engine := testEngine(t, "hang", 1)
+	start := time.Now()
+	_, err := engine.Move(rules.Classic{}, rules.Classic{}.NewState())
+	if !errors.Is(err, ErrEngineTimeout) || !errors.Is(err, ErrEngineFailed) {
+		t.Fatalf("got %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("timeout took %v", elapsed)
+	}
+}
+
+// TestExternalEngineBoundsQueueing checks that a move waiting for a busy
+// engine gives up in time instead of queueing behind a hung one.
+func TestExternalEngineBoundsQueueing(t *testing.T) {
+	engine := testEngine(t, "hang", 1)
+	limit := engine.config.MoveTime + engine.config.Grace + 500*time.Millisecond
+	errs := make(chan error, 3)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := engine.Move(rules.Classic{}, rules.Classic{}.NewState())
+			errs <- err
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-errs; !errors.Is(err, ErrEngineTimeout) {
+			t.Errorf("got %v, want a timeout", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > limit {
+		t.Errorf("three moves on a hung engine took %v", elapsed)
+	}
+}
+
+func TestExternalEnginePlaysGamesInParallel(t *testing.T) {
+	engine := testEngine(t, "reference", 2)
+	gomoku := rules.Gomoku{Size: 9, WinLength: 5}
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := engine.Move(gomoku, gomoku.NewState())
+			errs <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("parallel move failed: %v", err)
+		}
+	}
+}
+
+func TestExternalEngineRejectsIllegalMove(t *testing.T) {
+	engine := testEngine(t, "illegal", 1)
+	if _, err := engine.Move(rules.Classic{}, rules.Classic{}.NewState()); !errors.Is(err, ErrEngineIllegalMove) {
+		t.Fatalf("got %v, want an illegal move", err)
+	}
+}
+
+func TestExternalEngineRestartsAfterCrash(t *testing.T) {
+	engine := testEngine(t, "crash-once", 1)
+	if _, err := engine.Move(rules.Classic{}, rules.Classic{}.NewState()); !errors.Is(err, ErrEngineFailed) {
+		t.Fatalf("got %v, want a failure from the crashed engine", err)
+	}
+	move, err := engine.Move(rules.Classic{}, rules.Classic{}.NewState())
+	if err != nil || move.Cell < 0 {
+		t.Errorf("restarted engine played %+v, %v", move, err)
+	}
+}
+
+func TestExternalEngineNeedsHandshake(t *testing.T) {
+	engine := testEngine(t, "mute", 1)
+	if _, err := engine.Start(); !errors.Is(err, ErrEngineTimeout) {
+		t.Errorf("got %v, want a handshake timeout", err)
+	}
+}
+
+func TestPositionRoundTrip(t *testing.T) {
+	ultimate := rules.Ultimate{}
+	state := ultimate.NewState()
+	for _, cell := range []int{40, 36, 4} {
+		if err := ultimate.Apply(state, rules.Move{Cell: cell}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gameRules, parsed, err := parsePosition(strings.Fields(formatPosition(ultimate, state))[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gameRules.Options() != ultimate.Options() || strings.Join(parsed.Board, ",") != strings.Join(state.Board, ",") ||
+		parsed.Turn != state.Turn || parsed.LastMove != state.LastMove || parsed.MoveCount != state.MoveCount {
+		t.Errorf("parsed %+v, want %+v", parsed, state)
+	}
+
+	for _, args := range []string{
+		"classic X........ X",
+		"classic X... X 0",
+		"classic X.......Z O 0",
+		"classic X........ Y 0",
+		"chess X........ O 0",
+	} {
+		if _, _, err := parsePosition(strings.Fields(args)); err == nil {
+			t.Errorf("%q parsed without error", args)
+		}
+	}
+}
@@ -2,33 +2,84 @@ package services
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"math"
 	"math/rand"
+	"strconv"
 	"sync"
 	"time"
 
 	"tictactoe/internal/logger"
 	"tictactoe/internal/models"
+	"tictactoe/internal/protocol"
+	"tictactoe/internal/rules"
 	"tictactoe/internal/store"
+	"tictactoe/internal/utils"
 
 	"github.com/redis/go-redis/v9"
 )
 
+var (
+	ErrNoActiveGame = errors.New("no active game")
+	ErrNotAPlayer   = errors.New("not a player")
+	ErrNotYourTurn  = errors.New("not your turn")
+	ErrTimeUp       = errors.New("time is up")
+)
+
 type GameManager struct {
-	mu        sync.RWMutex
-	games     map[string]*models.Game
-	userStore *store.UserStore
+	mu         sync.RWMutex
+	games      map[string]*models.Game
+	spectating map[string]*models.Game
+	userStore  *store.UserStore
+	gameStore  *store.GameStore
+	onTimeout  func(nickname string, result *protocol.GameOver)
+	owners     *Ownership
+	snapshots  *SnapshotStore
+	onRestore  func(game *models.Game)
+	onAbandon  func(nickname string, result *protocol.GameOver)
+	onRemove   func(playerX, playerO string)
+	// reconnectGrace - сколько ждем отключившегося игрока
+	reconnectGrace time.Duration
+	// rematchWindow - сколько ждем ответа на реванш
+	rematchWindow time.Duration
 }
 
-func NewGameManager(userStore *store.UserStore) *GameManager {
+func NewGameManager(userStore *store.UserStore, gameStore *store.GameStore) *GameManager {
 	return &GameManager{
-		games:     make(map[string]*models.Game),
-		userStore: userStore,
+		games:          make(map[string]*models.Game),
+		spectating:     make(map[string]*models.Game),
+		userStore:      userStore,
+		gameStore:      gameStore,
+		reconnectGrace: defaultReconnectGrace,
+		rematchWindow:  defaultRematchWindow,
 	}
 }
 
-func (g *GameManager) CreateGame(p1, p2, sym1, sym2 string) {
+// OnTimeout задает обработчик, который получает game_over, когда у игрока
+// истекло время. nickname - любой из игроков партии.
+func (g *GameManager) OnTimeout(fn func(nickname string, result *protocol.GameOver)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.onTimeout = fn
+}
+
+// UseOwnership включает учет владельцев партий для работы в нескольких
+// экземплярах.
+func (g *GameManager) UseOwnership(owners *Ownership) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.owners = owners
+}
+
+// Owners возвращает учет владельцев или nil в режиме одного экземпляра.
+func (g *GameManager) Owners() *Ownership {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.owners
+}
+
+// CreateGame начинает партию и возвращает ее id.
+func (g *GameManager) CreateGame(p1, p2, sym1, sym2 string, gameRules rules.GameRules, tc models.TimeControl, rated bool) string {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
@@ -41,25 +92,36 @@ func (g *GameManager) CreateGame(p1, p2, sym1, sym2 string) {
 	}
 
 	game := &models.Game{
+		ID:           utils.GenerateGameID(),
 		PlayerX:      playerX,
 		PlayerO:      playerO,
-		Turn:         "X",
-		Board:        [9]string{},
+		Rules:        gameRules,
+		State:        gameRules.NewState(),
 		IsFinished:   false,
 		LastActivity: time.Now(),
+		StartedAt:    time.Now(),
+		TimeControl:  tc,
+		Unrated:      !rated,
 	}
+	g.startClock(game)
 
 	g.games[playerX] = game
 	g.games[playerO] = game
+	g.owners.Claim(OwnedGame, game.ID, playerX, playerO)
+	g.saveSnapshot(game)
+	return game.ID
 }
 
-func (g *GameManager) HandleMove(nickname string, cell int) (map[string]interface{}, map[string]interface{}, error) {
+// HandleMove делает ход игрока. moveID - id хода от клиента: повтор хода
+// с тем же id возвращает ErrDuplicateMove и, если событие еще в журнале,
+// исходный move_made.
+func (g *GameManager) HandleMove(nickname string, move rules.Move, moveID string) (*protocol.MoveMade, *protocol.GameOver, error) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
 	game, ok := g.games[nickname]
-	if !ok || game.IsFinished {
-		return nil, nil, fmt.Errorf("no active game")
+	if !ok {
+		return nil, nil, ErrNoActiveGame
 	}
 
 	symbol := ""
@@ -68,42 +130,160 @@ func (g *GameManager) HandleMove(nickname string, cell int) (map[string]interfac
 	} else if nickname == game.PlayerO {
 		symbol = "O"
 	} else {
-		return nil, nil, fmt.Errorf("not a player")
+		return nil, nil, ErrNotAPlayer
 	}
+	return g.applyMove(game, symbol, move, moveID)
+}
 
-	if game.Turn != symbol {
-		return nil, nil, fmt.Errorf("not your turn")
+// HandleBotMove делает ход бота в партии gameID игрока nickname. Бот
+// ищется по партии, а не по имени: одно имя бота бывает в нескольких партиях.
+func (g *GameManager) HandleBotMove(nickname, gameID string, move rules.Move) (*protocol.MoveMade, *protocol.GameOver, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	game, ok := g.games[nickname]
+	if !ok || game.ID != gameID || !game.IsBotGame {
+		return nil, nil, ErrNoActiveGame
 	}
-	if cell < 0 || cell > 8 || game.Board[cell] != "" {
-		return nil, nil, fmt.Errorf("invalid move")
+	return g.applyMove(game, game.BotSymbol, move, "")
+}
+
+// applyMove делает ход за сторону symbol. Вызывается под g.mu.
+func (g *GameManager) applyMove(game *models.Game, symbol string, move rules.Move, moveID string) (*protocol.MoveMade, *protocol.GameOver, error) {
+	if seq, ok := game.MoveIDs[moveKey(symbol, moveID)]; ok && moveID != "" {
+		event, _ := eventAt(game, seq)
+		moveMsg, _ := event.(*protocol.MoveMade)
+		return moveMsg, nil, ErrDuplicateMove
+	}
+	if game.IsFinished {
+		return nil, nil, ErrNoActiveGame
 	}
 
-	game.LastActivity = time.Now()
-	game.Board[cell] = symbol
-	game.Turn = opposite(symbol)
+	if game.State.Turn != symbol {
+		return nil, nil, ErrNotYourTurn
+	}
+
+	now := time.Now()
+	clocked := game.TimeControl.Enabled()
+	if clocked && remainingTime(game, symbol, now) <= 0 {
+		return nil, nil, ErrTimeUp
+	}
 
-	move := map[string]interface{}{
-		"type": "move_made",
-		"cell": cell,
-		"by":   symbol,
+	if err := game.Rules.Apply(game.State, move); err != nil {
+		return nil, nil, err
 	}
 
-	winner, winningPattern := checkWin(game.Board)
-	if winner != "" {
+	game.LastActivity = now
+	game.Moves = append(game.Moves, models.GameMove{
+		Ply:      len(game.Moves) + 1,
+		Seat:     symbol,
+		Cell:     move.Cell,
+		Symbol:   game.State.Board[move.Cell],
+		PlayedAt: now,
+	})
+	if clocked {
+		g.chargeClock(game, symbol, now)
+	}
+
+	moveMsg := &protocol.MoveMade{
+		Cell:      move.Cell,
+		By:        symbol,
+		Symbol:    game.State.Board[move.Cell],
+		SubBoards: subBoards(game),
+	}
+	if clocked {
+		moveMsg.Clocks = clockFields(game, now)
+	}
+	recordEvent(game, moveMsg)
+	if moveID != "" {
+		if game.MoveIDs == nil {
+			game.MoveIDs = make(map[string]int64)
+		}
+		moveMsg.MoveID = moveID
+		game.MoveIDs[moveKey(symbol, moveID)] = moveMsg.Seq
+	}
+
+	outcome := game.Rules.Outcome(game.State)
+	if !outcome.Finished && clocked {
+		g.scheduleFlag(game)
+	}
+	defer g.saveSnapshot(game)
+	if outcome.Finished {
+		stopClock(game)
 		game.IsFinished = true
-		game.Winner = winner
+		game.Winner = outcome.Winner
+		game.EndReason = models.ReasonLine
+		if outcome.Winner == rules.Draw {
+			game.EndReason = models.ReasonDraw
+		}
 		game.LastActivity = time.Now() // Update for rematch window
 
-		result := map[string]interface{}{
-			"type":           "game_over",
-			"result":         winner,
-			"winningPattern": winningPattern,
+		result := &protocol.GameOver{
+			Result:         outcome.Winner,
+			Reason:         game.EndReason,
+			WinningPattern: outcome.Line,
 		}
+		recordEvent(game, result)
+
+		return moveMsg, result, nil
+	}
+
+	return moveMsg, nil, nil
+}
+
+// GameState возвращает снимок партии в виде сообщения game_state.
+func (g *GameManager) GameState(nickname string) (*protocol.GameState, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 
-		return move, result, nil
+	game, ok := g.games[nickname]
+	if !ok {
+		return nil, false
 	}
+	return gameStateMessage(game), true
+}
+
+func gameStateMessage(game *models.Game) *protocol.GameState {
+	msg := &protocol.GameState{
+		GameID:     game.ID,
+		Seq:        game.Seq,
+		PlayerX:    game.PlayerX,
+		PlayerO:    game.PlayerO,
+		Board:      boardOf(game.Rules.Options()),
+		Cells:      game.State.Board,
+		Turn:       game.State.Turn,
+		IsFinished: game.IsFinished,
+		Winner:     game.Winner,
+		Spectators: len(game.Spectators),
+		SubBoards:  subBoards(game),
+	}
+	if game.TimeControl.Enabled() {
+		msg.TimeControl = timeControlFields(game.TimeControl)
+		msg.Clocks = clockFields(game, time.Now())
+	}
+	if len(game.Disconnected) > 0 {
+		msg.Disconnected = disconnectedFields(game, time.Now())
+	}
+	return msg
+}
+
+// subBoards возвращает состояние малых досок для вариантов, которые из них
+// состоят.
+func subBoards(game *models.Game) protocol.SubBoards {
+	sb, ok := game.Rules.(rules.SubBoards)
+	if !ok {
+		return protocol.SubBoards{}
+	}
+	forced := sb.ForcedBoard(game.State)
+	return protocol.SubBoards{
+		ForcedBoard: &forced,
+		SubWinners:  sb.SubWinners(game.State),
+	}
+}
 
-	return move, nil, nil
+// boardOf описывает вариант игры для клиента.
+func boardOf(opts rules.Options) protocol.Board {
+	return protocol.Board{Variant: opts.Variant, Size: opts.Size, WinLength: opts.WinLength}
 }
 
 func (g *GameManager) GetGame(nickname string) (*models.Game, bool) {
@@ -113,22 +293,54 @@ func (g *GameManager) GetGame(nickname string) (*models.Game, bool) {
 	return game, ok
 }
 
-func (g *GameManager) FinishGame(rdb *redis.Client, nickname string) {
+// Playing сообщает, идет ли у игрока партия на этом или другом экземпляре.
+func (g *GameManager) Playing(nickname string) bool {
+	g.mu.RLock()
+	game, ok := g.games[nickname]
+	owners := g.owners
+	g.mu.RUnlock()
+
+	if ok {
+		return !game.IsFinished
+	}
+	_, remote := owners.Remote(OwnedGame, nickname)
+	return remote
+}
+
+// OnRemove задает обработчик, который вызывается, когда партия удаляется
+// из памяти, например, чтобы забыть состояние чата ее игроков.
+func (g *GameManager) OnRemove(fn func(playerX, playerO string)) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
+	g.onRemove = fn
+}
 
+func (g *GameManager) FinishGame(rdb *redis.Client, nickname string) {
+	g.mu.Lock()
 	game, ok := g.games[nickname]
 	if !ok {
+		g.mu.Unlock()
 		return
 	}
 
 	ctx := context.Background()
 
 	// Stats update moved to RecordGameResult
+	stopClock(game)
+	stopAbsences(game)
 
+	for _, player := range humans(game) {
+		delete(g.games, player)
+	}
+	g.dropSpectators(game)
+	g.owners.Release(OwnedGame, ownedKeys(game)...)
+	g.snapshots.Delete(game.ID)
+	playerX, playerO, onRemove := game.PlayerX, game.PlayerO, g.onRemove
+	g.mu.Unlock()
 
-	delete(g.games, game.PlayerX)
-	delete(g.games, game.PlayerO)
+	if onRemove != nil {
+		onRemove(playerX, playerO)
+	}
 
 	count, err := rdb.Decr(ctx, "active_games").Result()
 	if err != nil {
@@ -138,6 +350,55 @@ func (g *GameManager) FinishGame(rdb *redis.Client, nickname string) {
 	}
 }
 
+// Forfeit завершает партию поражением игрока nickname и возвращает
+// сообщение game_over. reason - ReasonForfeit или ReasonAbandoned.
+func (g *GameManager) Forfeit(nickname, reason string) (*protocol.GameOver, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.forfeitLocked(nickname, reason)
+}
+
+func (g *GameManager) forfeitLocked(nickname, reason string) (*protocol.GameOver, bool) {
+	game, ok := g.games[nickname]
+	if !ok || game.IsFinished {
+		return nil, false
+	}
+
+	loser := "O"
+	if nickname == game.PlayerX {
+		loser = "X"
+	}
+	return g.forfeitSeat(game, loser, reason), true
+}
+
+// ForfeitBot завершает поражением бота партию gameID игрока nickname.
+func (g *GameManager) ForfeitBot(nickname, gameID, reason string) (*protocol.GameOver, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	game, ok := g.games[nickname]
+	if !ok || game.ID != gameID || !game.IsBotGame || game.IsFinished {
+		return nil, false
+	}
+	return g.forfeitSeat(game, game.BotSymbol, reason), true
+}
+
+// forfeitSeat завершает партию поражением стороны loser. Вызывается под g.mu.
+func (g *GameManager) forfeitSeat(game *models.Game, loser, reason string) *protocol.GameOver {
+	winner := rules.Opposite(loser)
+
+	stopClock(game)
+	stopAbsences(game)
+	game.IsFinished = true
+	game.Winner = winner
+	game.EndReason = reason
+	game.LastActivity = time.Now()
+	result := &protocol.GameOver{Result: winner, Reason: reason}
+	recordEvent(game, result)
+	g.saveSnapshot(game)
+	return result
+}
+
 func (g *GameManager) RecordGameResult(rdb *redis.Client, nickname string) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
@@ -148,40 +409,137 @@ func (g *GameManager) RecordGameResult(rdb *redis.Client, nickname string) {
 	}
 
 	ctx := context.Background()
+	record := newGameRecord(game)
 
 	switch game.Winner {
 	case "X":
 		rdb.Incr(ctx, "wins:"+game.PlayerX)
 		rdb.Incr(ctx, "losses:"+game.PlayerO)
-		g.updateElo(game.PlayerX, game.PlayerO, 1.0)
+		g.rateGame(game, record, 1.0)
 	case "O":
 		rdb.Incr(ctx, "wins:"+game.PlayerO)
 		rdb.Incr(ctx, "losses:"+game.PlayerX)
-		g.updateElo(game.PlayerO, game.PlayerX, 1.0)
+		g.rateGame(game, record, 0.0)
 	case "draw":
 		rdb.Incr(ctx, "draws:"+game.PlayerX)
 		rdb.Incr(ctx, "draws:"+game.PlayerO)
-		g.updateElo(game.PlayerX, game.PlayerO, 0.5)
+		g.rateGame(game, record, 0.5)
+	}
+
+	if g.gameStore != nil {
+		if err := g.gameStore.SaveGame(record); err != nil {
+			logger.Error("Failed to save game:", err)
+		} else {
+			game.RecordID = record.ID
+		}
 	}
 
 	game.StatsRecorded = true
 	game.LastActivity = time.Now() // Update for rematch window
+	g.saveSnapshot(game)
+}
+
+// rateGame обновляет Elo игроков и записывает рейтинги до и после партии.
+// Партии с ботом меняют только отдельный рейтинг игрока в партиях с ботами,
+// нерейтинговые партии на рейтинг не влияют.
+func (g *GameManager) rateGame(game *models.Game, record *models.GameRecord, scoreX float64) {
+	if game.Unrated {
+		return
+	}
+	if game.IsBotGame {
+		g.rateBotGame(game, scoreX)
+		return
+	}
+	ratingX, ratingO, changeX, ok := g.updateElo(game.PlayerX, game.PlayerO, scoreX)
+	if !ok {
+		return
+	}
+	afterX, afterO := ratingX+changeX, ratingO-changeX
+	record.EloXBefore, record.EloXAfter = &ratingX, &afterX
+	record.EloOBefore, record.EloOAfter = &ratingO, &afterO
 }
 
-func (g *GameManager) updateElo(playerA, playerB string, scoreA float64) {
+// rateBotGame меняет рейтинг игрока в партиях с ботами. Рейтинг бота
+// постоянный: это рейтинг, на который он играет.
+func (g *GameManager) rateBotGame(game *models.Game, scoreX float64) {
+	player, score := game.PlayerX, scoreX
+	if game.BotSymbol == rules.X {
+		player, score = game.PlayerO, 1-scoreX
+	}
+	user, err := g.userStore.GetUserProfile(player)
+	if err != nil {
+		logger.Error("Failed to get user for bot rating update:", err)
+		return
+	}
+	change := eloChange(user.BotEloRating, game.BotElo, score)
+	if err := g.userStore.UpdateBotRating(player, change); err != nil {
+		logger.Error("Failed to update bot rating for", player, ":", err)
+	}
+}
+
+// FinishedRecord возвращает только что завершенную партию игрока.
+func (g *GameManager) FinishedRecord(nickname string) (*models.GameRecord, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	game, ok := g.games[nickname]
+	if !ok || !game.IsFinished {
+		return nil, false
+	}
+	record := newGameRecord(game)
+	record.ID = game.RecordID
+	return record, true
+}
+
+func newGameRecord(game *models.Game) *models.GameRecord {
+	opts := game.Rules.Options()
+	record := &models.GameRecord{
+		PlayerX:     game.PlayerX,
+		PlayerO:     game.PlayerO,
+		Variant:     opts.Variant,
+		Size:        opts.Size,
+		WinLength:   opts.WinLength,
+		Mode:        models.GameModePvP,
+		TimeControl: timeControlKey(game.TimeControl),
+		Result:      game.Winner,
+		Reason:      game.EndReason,
+		StartedAt:   game.StartedAt,
+		FinishedAt:  time.Now(),
+		Moves:       append([]models.GameMove(nil), game.Moves...),
+	}
+	if game.IsBotGame {
+		record.Mode = models.GameModeBot
+		record.BotDifficulty = string(game.BotDifficulty)
+		if record.BotDifficulty == "" {
+			// Бот, выбранный по рейтингу, записывается своим рейтингом
+			record.BotDifficulty = strconv.Itoa(game.BotElo)
+		}
+	}
+	return record
+}
+
+// Rating возвращает текущий рейтинг игрока.
+func (g *GameManager) Rating(nickname string) (int, error) {
+	user, err := g.userStore.GetUserProfile(nickname)
+	if err != nil {
+		return 0, err
+	}
+	return user.EloRating, nil
+}
+
+// updateElo возвращает рейтинги игроков до партии и изменение рейтинга playerA.
+func (g *GameManager) updateElo(playerA, playerB string, scoreA float64) (int, int, int, bool) {
 	// Получаем текущие рейтинги
-	userA, _, errA := g.userStore.GetUserByNickname(playerA)
-	userB, _, errB := g.userStore.GetUserByNickname(playerB)
+	userA, errA := g.userStore.GetUserProfile(playerA)
+	userB, errB := g.userStore.GetUserProfile(playerB)
 
 	if errA != nil || errB != nil {
 		logger.Error("Failed to get users for ELO update:", errA, errB)
-		return
+		return 0, 0, 0, false
 	}
 
 	// Расчет изменения рейтинга
-	kFactor := 32
-	expectedA := 1.0 / (1.0 + math.Pow(10, float64(userB.EloRating-userA.EloRating)/400.0))
-	changeA := int(float64(kFactor) * (scoreA - expectedA))
+	changeA := eloChange(userA.EloRating, userB.EloRating, scoreA)
 
 	// Обновляем статистику в БД
 	resultA := "draw"
@@ -201,15 +559,68 @@ func (g *GameManager) updateElo(playerA, playerB string, scoreA float64) {
 	if err := g.userStore.UpdateUserStats(playerB, -changeA, resultB); err != nil {
 		logger.Error("Failed to update stats for", playerB, ":", err)
 	}
+
+	return userA.EloRating, userB.EloRating, changeA, true
+}
+
+// eloChange - изменение рейтинга ratingA после партии с ratingB, в которой
+// A набрал scoreA очков.
+func eloChange(ratingA, ratingB int, scoreA float64) int {
+	const kFactor = 32
+	expectedA := 1.0 / (1.0 + math.Pow(10, float64(ratingB-ratingA)/400.0))
+	return int(float64(kFactor) * (scoreA - expectedA))
+}
+
+// defaultRematchWindow - сколько ждем ответа на предложение реванша
+const defaultRematchWindow = 15 * time.Second
+
+// RequestRematch отмечает, что nickname хочет реванш после завершенной
+// партии, и возвращает соперника. Если реванш не начнется за
+// g.rematchWindow, вызывается expired с игроками партии.
+func (g *GameManager) RequestRematch(nickname string, expired func(players []string)) (string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	game, ok := g.games[nickname]
+	if !ok || !game.IsFinished {
+		return "", false
+	}
+
+	opponent := game.PlayerO
+	if nickname == game.PlayerX {
+		game.PlayAgainX = true
+	} else {
+		game.PlayAgainO = true
+		opponent = game.PlayerX
+	}
+	if game.RematchTimer == nil {
+		var timer *time.Timer
+		timer = time.AfterFunc(g.rematchWindow, func() {
+			// Остановленный или замененный таймер значит, что реванш начался
+			g.mu.Lock()
+			if game.RematchTimer != timer {
+				g.mu.Unlock()
+				return
+			}
+			game.RematchTimer = nil
+			players := []string{game.PlayerX, game.PlayerO}
+			g.mu.Unlock()
+
+			expired(players)
+		})
+		game.RematchTimer = timer
+	}
+	g.saveSnapshot(game)
+	return opponent, true
 }
 
-func (g *GameManager) HandlePlayAgain(nickname string) (map[string]interface{}, map[string]interface{}, error) {
+func (g *GameManager) HandlePlayAgain(nickname string) (*protocol.Rematch, *protocol.Rematch, error) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
 	game, ok := g.games[nickname]
 	if !ok {
-		return nil, nil, fmt.Errorf("no active game")
+		return nil, nil, ErrNoActiveGame
 	}
 
 	if nickname == game.PlayerX {
@@ -217,8 +628,9 @@ func (g *GameManager) HandlePlayAgain(nickname string) (map[string]interface{},
 	} else if nickname == game.PlayerO {
 		game.PlayAgainO = true
 	} else {
-		return nil, nil, fmt.Errorf("not a player")
+		return nil, nil, ErrNotAPlayer
 	}
+	defer g.saveSnapshot(game)
 
 	if !(game.PlayAgainX && game.PlayAgainO) {
 		return nil, nil, nil
@@ -235,9 +647,16 @@ func (g *GameManager) HandlePlayAgain(nickname string) (map[string]interface{},
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 	r.Shuffle(2, func(i, j int) { symbols[i], symbols[j] = symbols[j], symbols[i] })
 
-	game.Board = [9]string{}
+	game.State = game.Rules.NewState()
 	game.IsFinished = false
-	game.Turn = "X"
+	game.StartedAt = time.Now()
+	game.Moves = nil
+	// Номера событий продолжаются, но журнал прошлой партии уже не нужен
+	game.Events = nil
+	game.MoveIDs = nil
+	game.EndReason = ""
+	game.RecordID = 0
+	g.startClock(game)
 	game.PlayAgainX = false
 	game.PlayAgainO = false
 	game.Winner = ""
@@ -251,60 +670,27 @@ func (g *GameManager) HandlePlayAgain(nickname string) (map[string]interface{},
 		game.PlayerO = players[0]
 	}
 
-	msg1 := map[string]interface{}{
-		"type":     "rematch",
-		"symbol":   "X",
-		"opponent": game.PlayerO,
-	}
-	msg2 := map[string]interface{}{
-		"type":     "rematch",
-		"symbol":   "O",
-		"opponent": game.PlayerX,
-	}
+	msg1 := &protocol.Rematch{Symbol: "X", Opponent: game.PlayerO}
+	msg2 := &protocol.Rematch{Symbol: "O", Opponent: game.PlayerX}
 
 	return msg1, msg2, nil
 }
 
-func opposite(s string) string {
-	if s == "X" {
-		return "O"
-	}
-	return "X"
-}
-
-func checkWin(board [9]string) (string, []int) {
-	winPatterns := [][]int{
-		{0, 1, 2},
-		{3, 4, 5},
-		{6, 7, 8},
-		{0, 3, 6},
-		{1, 4, 7},
-		{2, 5, 8},
-		{0, 4, 8},
-		{2, 4, 6},
-	}
-
-	for _, pattern := range winPatterns {
-		a, b, c := pattern[0], pattern[1], pattern[2]
-		if board[a] != "" && board[a] == board[b] && board[b] == board[c] {
-			return board[a], pattern
-		}
-	}
-
-	return "", nil
-}
-
-func (g *GameManager) CreateBotGame(player string, difficulty models.BotDifficulty) string {
+// CreateBotGame создает партию с ботом. playerSymbol - сторона игрока,
+// пустая строка означает случайный выбор. Рейтинговая партия меняет
+// рейтинг игрока в партиях с ботами. Возвращает сторону игрока.
+func (g *GameManager) CreateBotGame(player string, bot models.BotPlayer, gameRules rules.GameRules, playerSymbol string, rated bool) string {
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	playerSymbol := "X"
-	botSymbol := "O"
-	if r.Intn(2) == 1 {
-		playerSymbol = "O"
-		botSymbol = "X"
+	if playerSymbol == "" {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		playerSymbol = "X"
+		if r.Intn(2) == 1 {
+			playerSymbol = "O"
+		}
 	}
-	botName := fmt.Sprintf("Bot_%s", difficulty)
+	botSymbol := rules.Opposite(playerSymbol)
+	botName := bot.Name()
 
 	var playerX, playerO string
 	if playerSymbol == "X" {
@@ -316,23 +702,59 @@ func (g *GameManager) CreateBotGame(player string, difficulty models.BotDifficul
 	}
 
 	game := &models.Game{
+		ID:            utils.GenerateGameID(),
 		PlayerX:       playerX,
 		PlayerO:       playerO,
-		Turn:          "X",
-		Board:         [9]string{},
+		Rules:         gameRules,
+		State:         gameRules.NewState(),
 		IsFinished:    false,
 		IsBotGame:     true,
-		BotDifficulty: difficulty,
+		BotDifficulty: bot.Difficulty,
+		BotElo:        bot.Elo,
 		BotSymbol:     botSymbol,
 		LastActivity:  time.Now(),
+		StartedAt:     time.Now(),
+		Unrated:       !rated,
+	}
+	if bot.Personality != nil {
+		game.BotPersonality = bot.Personality.ID
 	}
 
 	g.games[player] = game
-	g.games[botName] = game
+	g.owners.Claim(OwnedGame, game.ID, player)
+	g.saveSnapshot(game)
 
 	return playerSymbol
 }
 
+// BotPosition - все, что нужно боту для хода, скопированное под mu.
+type BotPosition struct {
+	GameID      string
+	Rules       rules.GameRules
+	State       *rules.State
+	Elo         int
+	Personality string
+}
+
+// BotPosition возвращает позицию в партии игрока nickname, если сейчас
+// ходит бот.
+func (g *GameManager) BotPosition(nickname string) (BotPosition, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	game, ok := g.games[nickname]
+	if !ok || !game.IsBotGame || game.IsFinished || game.State.Turn != game.BotSymbol {
+		return BotPosition{}, false
+	}
+	return BotPosition{
+		GameID:      game.ID,
+		Rules:       game.Rules,
+		State:       game.State.Clone(),
+		Elo:         game.BotElo,
+		Personality: game.BotPersonality,
+	}, true
+}
+
 func (g *GameManager) IsBotTurn(nickname string) bool {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
@@ -342,7 +764,7 @@ func (g *GameManager) IsBotTurn(nickname string) bool {
 		return false
 	}
 
-	if game.Turn == game.BotSymbol {
+	if game.State.Turn == game.BotSymbol {
 		return true
 	}
 
@@ -353,14 +775,16 @@ func (g *GameManager) StartCleaner(rdb *redis.Client) {
 	go func() {
 		ticker := time.NewTicker(10 * time.Second)
 		for range ticker.C {
+			g.Owners().Heartbeat()
 			g.cleanupAndSync(rdb)
+			// Забираем партии экземпляров, которые перестали отвечать
+			g.RestoreGames()
 		}
 	}()
 }
 
 func (g *GameManager) cleanupAndSync(rdb *redis.Client) {
 	g.mu.Lock()
-	defer g.mu.Unlock()
 
 	ctx := context.Background()
 	uniqueGames := make(map[*models.Game]bool)
@@ -376,6 +800,11 @@ func (g *GameManager) cleanupAndSync(rdb *redis.Client) {
 			uniqueGames[game] = true
 			continue
 		}
+		// Партии с часами завершаются по флажку, а не по неактивности
+		if !game.IsFinished && game.ClockTimer != nil {
+			uniqueGames[game] = true
+			continue
+		}
 		if game.IsFinished || time.Since(game.LastActivity) > 2*time.Minute {
 			keysToDelete = append(keysToDelete, nickname)
 		} else {
@@ -383,11 +812,29 @@ func (g *GameManager) cleanupAndSync(rdb *redis.Client) {
 		}
 	}
 
+	removed := make(map[*models.Game][2]string)
 	for _, key := range keysToDelete {
+		if game, ok := g.games[key]; ok {
+			if _, seen := removed[game]; !seen {
+				g.dropSpectators(game)
+				stopAbsences(game)
+				g.owners.Release(OwnedGame, ownedKeys(game)...)
+				g.snapshots.Delete(game.ID)
+				removed[game] = [2]string{game.PlayerX, game.PlayerO}
+			}
+		}
 		delete(g.games, key)
 	}
+	onRemove := g.onRemove
+	g.mu.Unlock()
+
+	if onRemove != nil {
+		for _, players := range removed {
+			onRemove(players[0], players[1])
+		}
+	}
 
-	realCount := len(uniqueGames)
+	realCount := g.owners.ShareCount(ctx, "active_games", len(uniqueGames))
 
 	err := rdb.Set(ctx, "active_games", realCount, 0).Err()
 	if err != nil {
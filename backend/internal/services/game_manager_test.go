@@ -0,0 +1,55 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/rules"
+)
+
+func TestRematchExpires(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	g.rematchWindow = 50 * time.Millisecond
+	g.CreateGame("alice", "bob", "X", "O", rules.Classic{}, models.TimeControl{}, true)
+	g.Forfeit("alice", models.ReasonForfeit)
+
+	expired := make(chan []string, 1)
+	opponent, ok := g.RequestRematch("alice", func(players []string) { expired <- players })
+	if !ok || opponent != "bob" {
+		t.Fatalf("RequestRematch = %q, %v", opponent, ok)
+	}
+	select {
+	case players := <-expired:
+		if len(players) != 2 || players[0] != "alice" || players[1] != "bob" {
+			t.Errorf("expired for %v", players)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("rematch did not expire")
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.games["alice"].RematchTimer != nil {
+		t.Error("expired timer left in the game")
+	}
+}
+
+func TestAcceptedRematchDoesNotExpire(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	g.rematchWindow = 50 * time.Millisecond
+	g.CreateGame("alice", "bob", "X", "O", rules.Classic{}, models.TimeControl{}, true)
+	g.Forfeit("alice", models.ReasonForfeit)
+
+	expired := make(chan []string, 1)
+	g.RequestRematch("alice", func(players []string) { expired <- players })
+	msg1, msg2, err := g.HandlePlayAgain("bob")
+	if err != nil || msg1 == nil || msg2 == nil {
+		t.Fatalf("HandlePlayAgain = %v, %v, %v", msg1, msg2, err)
+	}
+
+	select {
+	case <-expired:
+		t.Fatal("accepted rematch expired")
+	case <-time.After(150 * time.Millisecond):
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/notation"
+	"tictactoe/internal/rules"
+	"tictactoe/internal/store"
+)
+
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 50
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type HistoryService struct {
+	Store *store.GameStore
+}
+
+func NewHistoryService(store *store.GameStore) *HistoryService {
+	return &HistoryService{Store: store}
+}
+
+// GetHistory возвращает страницу партий игрока, начиная с самых новых.
+// cursor - значение next_cursor из предыдущей страницы.
+func (s *HistoryService) GetHistory(player, cursor string, limit int) (*models.GameHistoryPage, error) {
+	beforeID := 0
+	if cursor != "" {
+		id, err := strconv.Atoi(cursor)
+		if err != nil || id <= 0 {
+			return nil, ErrInvalidCursor
+		}
+		beforeID = id
+	}
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	records, err := s.Store.GetGamesByPlayer(player, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.GameHistoryPage{Games: make([]models.GameHistoryEntry, 0, len(records))}
+	for _, record := range records {
+		page.Games = append(page.Games, historyEntry(player, record))
+	}
+	if len(records) == limit {
+		page.NextCursor = strconv.Itoa(records[len(records)-1].ID)
+	}
+	return page, nil
+}
+
+// RecentGames возвращает последние n партий игрока.
+func (s *HistoryService) RecentGames(player string, n int) ([]models.GameHistoryEntry, error) {
+	page, err := s.GetHistory(player, "", n)
+	if err != nil {
+		return nil, err
+	}
+	return page.Games, nil
+}
+
+// GetGame возвращает партию вместе со списком ходов для повтора.
+func (s *HistoryService) GetGame(id int) (*models.GameRecord, error) {
+	return s.Store.GetGame(id)
+}
+
+// ExportGame возвращает сохраненную партию в текстовой нотации.
+func (s *HistoryService) ExportGame(id int) (string, error) {
+	record, err := s.Store.GetGame(id)
+	if err != nil {
+		return "", err
+	}
+	return notation.Encode(notation.FromRecord(record))
+}
+
+// ImportGame разбирает партию в текстовой нотации и проверяет ее,
+// переигрывая ходы по правилам сервера.
+func (s *HistoryService) ImportGame(text string) (*notation.Game, *rules.State, rules.Outcome, error) {
+	game, err := notation.Parse(text)
+	if err != nil {
+		return nil, nil, rules.Outcome{}, err
+	}
+	state, outcome, err := notation.Replay(game)
+	if err != nil {
+		return nil, nil, rules.Outcome{}, err
+	}
+	return game, state, outcome, nil
+}
+
+func historyEntry(player string, record models.GameRecord) models.GameHistoryEntry {
+	symbol, opponent := "X", record.PlayerO
+	before, after := record.EloXBefore, record.EloXAfter
+	if player == record.PlayerO {
+		symbol, opponent = "O", record.PlayerX
+		before, after = record.EloOBefore, record.EloOAfter
+	}
+
+	result := "draw"
+	if record.Result == symbol {
+		result = "win"
+	} else if record.Result != "draw" {
+		result = "loss"
+	}
+
+	entry := models.GameHistoryEntry{
+		ID:              record.ID,
+		Opponent:        opponent,
+		Symbol:          symbol,
+		Result:          result,
+		Reason:          record.Reason,
+		DurationSeconds: int(record.FinishedAt.Sub(record.StartedAt).Seconds()),
+		Variant:         record.Variant,
+		Mode:            record.Mode,
+		FinishedAt:      record.FinishedAt,
+	}
+	if before != nil && after != nil {
+		delta := *after - *before
+		entry.EloDelta = &delta
+	}
+	return entry
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"tictactoe/internal/models"
+)
+
+func TestHistoryEntryPerspective(t *testing.T) {
+	before, afterX, afterO := 1000, 1016, 984
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	record := models.GameRecord{
+		ID:         7,
+		PlayerX:    "alice",
+		PlayerO:    "bob",
+		Result:     "X",
+		Reason:     models.ReasonLine,
+		EloXBefore: &before,
+		EloXAfter:  &afterX,
+		EloOBefore: &before,
+		EloOAfter:  &afterO,
+		StartedAt:  start,
+		FinishedAt: start.Add(42 * time.Second),
+	}
+
+	alice := historyEntry("alice", record)
+	if alice.Opponent != "bob" || alice.Result != "win" || *alice.EloDelta != 16 || alice.DurationSeconds != 42 {
+		t.Fatalf("unexpected entry for alice: %+v", alice)
+	}
+
+	bob := historyEntry("bob", record)
+	if bob.Opponent != "alice" || bob.Symbol != "O" || bob.Result != "loss" || *bob.EloDelta != -16 {
+		t.Fatalf("unexpected entry for bob: %+v", bob)
+	}
+
+	record.EloXBefore, record.EloXAfter = nil, nil
+	if entry := historyEntry("alice", record); entry.EloDelta != nil {
+		t.Fatalf("expected no Elo delta for an unrated game, got %d", *entry.EloDelta)
+	}
+}
@@ -2,128 +2,603 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"math/rand"
+	"strconv"
 	"sync"
 	"time"
 
 	"tictactoe/internal/logger"
+	"tictactoe/internal/models"
+	"tictactoe/internal/protocol"
+	"tictactoe/internal/rules"
+	"tictactoe/internal/utils"
 
-	"github.com/gorilla/websocket"
 	"github.com/redis/go-redis/v9"
 )
 
 type MatchmakingService struct {
 	RDB         *redis.Client
-	Clients     *sync.Map
+	Notifier    Notifier
 	GameManager *GameManager
+
+	waitMu sync.Mutex
+	// avgWait - сглаженное время ожидания по очередям для оценки в "searching"
+	avgWait map[string]time.Duration
 }
 
-func NewMatchmakerService(rdb *redis.Client, clientsMap *sync.Map, gm *GameManager) *MatchmakingService {
+var (
+	ErrAlreadyQueued = errors.New("already in queue")
+	ErrNotQueued     = errors.New("not in queue")
+)
+
+func NewMatchmakerService(rdb *redis.Client, notifier Notifier, gm *GameManager) *MatchmakingService {
 	return &MatchmakingService{
 		RDB:         rdb,
-		Clients:     clientsMap,
+		Notifier:    notifier,
 		GameManager: gm,
+		avgWait:     make(map[string]time.Duration),
+	}
+}
+
+const (
+	// matchTicketsKey хранит, в какой очереди ждет каждый игрок
+	matchTicketsKey = "match_tickets"
+	// matchQueuesKey хранит настройки партии для каждой очереди
+	matchQueuesKey = "match_queues"
+	// matchJoinedKey хранит время постановки в очередь (unix ms)
+	matchJoinedKey = "match_joined"
+
+	matchInterval = time.Second
+	// matchLockTTL - сколько живет блокировка очереди, если проход подбора
+	// не снял ее сам
+	matchLockTTL = 5 * time.Second
+
+	// Окно поиска начинается с ±initialWindow и растет на windowStep
+	// каждые windowGrowEvery, пока не достигнет maxWindow
+	initialWindow   = 50
+	windowStep      = 25
+	windowGrowEvery = 5 * time.Second
+	maxWindow       = 400
+)
+
+func matchQueueKey(opts rules.Options, tc models.TimeControl) string {
+	key := "match_queue:" + opts.Key()
+	if tcKey := timeControlKey(tc); tcKey != "" {
+		key += ":" + tcKey
+	}
+	return key
+}
+
+// searchWindow возвращает допустимую разницу в рейтинге после ожидания waited.
+func searchWindow(waited time.Duration) int {
+	if waited < 0 {
+		waited = 0
+	}
+	w := initialWindow + windowStep*int(waited/windowGrowEvery)
+	if w > maxWindow {
+		w = maxWindow
 	}
+	return w
 }
 
-func (m *MatchmakingService) HandleFindMatch(nickname string) error {
+// timeToWindow возвращает, сколько еще ждать, пока окно не вырастет до diff.
+// ok == false, если окно никогда не станет таким широким.
+func timeToWindow(diff int, waited time.Duration) (time.Duration, bool) {
+	if diff <= searchWindow(waited) {
+		return 0, true
+	}
+	if diff > maxWindow {
+		return 0, false
+	}
+	steps := (diff - initialWindow + windowStep - 1) / windowStep
+	return time.Duration(steps)*windowGrowEvery - waited, true
+}
+
+// Заявки меняются только скриптами: подбор на одном экземпляре и отмена
+// поиска на другом не должны видеть заявку наполовину снятой.
+var (
+	// joinQueueScript ставит игрока в очередь, если он еще нигде не ждет
+	joinQueueScript = redis.NewScript(`
+if redis.call('HSETNX', KEYS[1], ARGV[1], KEYS[3]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+redis.call('ZADD', KEYS[3], ARGV[3], ARGV[1])
+return 1
+`)
+
+	// leaveQueueScript снимает заявку игрока из той очереди, где он ждет
+	leaveQueueScript = redis.NewScript(`
+local queue = redis.call('HGET', KEYS[1], ARGV[1])
+if not queue then
+	return 0
+end
+redis.call('ZREM', queue, ARGV[1])
+redis.call('HDEL', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+return 1
+`)
+
+	// takePairScript снимает пару с очереди, только если оба игрока все
+	// еще ждут в ней
+	takePairScript = redis.NewScript(`
+for i = 1, #ARGV do
+	if redis.call('HGET', KEYS[2], ARGV[i]) ~= KEYS[1] then
+		return 0
+	end
+end
+redis.call('ZREM', KEYS[1], unpack(ARGV))
+redis.call('HDEL', KEYS[2], unpack(ARGV))
+redis.call('HDEL', KEYS[3], unpack(ARGV))
+return 1
+`)
+
+	// unlockScript снимает блокировку, только если ее держит этот проход
+	unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+)
+
+type matchTicket struct {
+	Nickname string
+	Rating   int
+	Joined   time.Time
+}
+
+// pairTickets жадно составляет пары из заявок, отсортированных по рейтингу.
+// Пара допустима, если разница рейтингов попадает в окна обоих игроков.
+func pairTickets(tickets []matchTicket, now time.Time) [][2]matchTicket {
+	var pairs [][2]matchTicket
+	used := make([]bool, len(tickets))
+	for i := range tickets {
+		if used[i] {
+			continue
+		}
+		windowI := searchWindow(now.Sub(tickets[i].Joined))
+		for j := i + 1; j < len(tickets); j++ {
+			if used[j] {
+				continue
+			}
+			diff := tickets[j].Rating - tickets[i].Rating
+			if diff > windowI {
+				break
+			}
+			if diff <= searchWindow(now.Sub(tickets[j].Joined)) {
+				used[i], used[j] = true, true
+				pairs = append(pairs, [2]matchTicket{tickets[i], tickets[j]})
+				break
+			}
+		}
+	}
+	return pairs
+}
+
+func (m *MatchmakingService) HandleFindMatch(nickname string, opts rules.Options, tc models.TimeControl) error {
 	ctx := context.Background()
 
-	added, err := m.RDB.SAdd(ctx, "match_queue", nickname).Result()
+	gameRules, err := rules.New(opts)
 	if err != nil {
 		return err
 	}
-	if added == 0 {
-		return errors.New("already in queue")
+	if err := validateTimeControl(tc); err != nil {
+		return err
 	}
+	opts = gameRules.Options()
+	queueKey := matchQueueKey(opts, tc)
 
-	logger.Info("Added to match queue:", nickname)
+	if m.GameManager.Playing(nickname) {
+		return ErrInGame
+	}
+	if hostingRoom(m.GameManager.Owners(), nickname) {
+		return ErrAlreadyHost
+	}
+
+	rating, err := m.GameManager.Rating(nickname)
+	if err != nil {
+		return err
+	}
+
+	settings, err := json.Marshal(queueSettings{Options: opts, TimeControl: tc})
+	if err != nil {
+		return err
+	}
+	if err := m.RDB.HSet(ctx, matchQueuesKey, queueKey, settings).Err(); err != nil {
+		return err
+	}
 
-	if c, ok := m.Clients.Load(nickname); ok {
-		conn := c.(*websocket.Conn)
-		_ = conn.WriteJSON(map[string]interface{}{"type": "searching"})
+	joined := time.Now()
+	added, err := joinQueueScript.Run(ctx, m.RDB, []string{matchTicketsKey, matchJoinedKey, queueKey},
+		nickname, joined.UnixMilli(), rating).Int()
+	if err != nil {
+		return err
 	}
+	if added == 0 {
+		return ErrAlreadyQueued
+	}
+
+	logger.Info(fmt.Sprintf("Added to match queue: %s (%s, elo %d)", nickname, opts.Key(), rating))
 
-	players, err := m.RDB.SMembers(ctx, "match_queue").Result()
+	tickets, err := m.queueTickets(ctx, queueKey)
 	if err != nil {
 		return err
 	}
-	if len(players) < 2 {
+	m.sendSearching(queueKey, matchTicket{Nickname: nickname, Rating: rating, Joined: joined}, tickets, joined)
+
+	m.matchQueue(ctx, queueKey)
+	return nil
+}
+
+// StartMatcher запускает фоновый подбор пар: окна поиска расширяются со
+// временем, поэтому заявки нужно периодически пересматривать.
+func (m *MatchmakingService) StartMatcher() {
+	go func() {
+		ticker := time.NewTicker(matchInterval)
+		for range ticker.C {
+			m.matchAll()
+		}
+	}()
+}
+
+func (m *MatchmakingService) matchAll() {
+	ctx := context.Background()
+	queues, err := m.RDB.HVals(ctx, matchTicketsKey).Result()
+	if err != nil {
+		logger.Warn("failed to list match queues:", err)
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, queueKey := range queues {
+		if seen[queueKey] {
+			continue
+		}
+		seen[queueKey] = true
+		m.matchQueue(ctx, queueKey)
+	}
+}
+
+// queueTickets читает заявки очереди в порядке возрастания рейтинга.
+func (m *MatchmakingService) queueTickets(ctx context.Context, queueKey string) ([]matchTicket, error) {
+	entries, err := m.RDB.ZRangeWithScores(ctx, queueKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	nicknames := make([]string, len(entries))
+	for i, e := range entries {
+		nicknames[i] = e.Member.(string)
+	}
+	joined, err := m.RDB.HMGet(ctx, matchJoinedKey, nicknames...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	tickets := make([]matchTicket, 0, len(entries))
+	for i, e := range entries {
+		t := matchTicket{Nickname: nicknames[i], Rating: int(e.Score), Joined: time.Now()}
+		if v, ok := joined[i].(string); ok {
+			if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+				t.Joined = time.UnixMilli(ms)
+			}
+		}
+		tickets = append(tickets, t)
+	}
+	return tickets, nil
+}
+
+// lockQueue берет блокировку очереди, общую для всех экземпляров, чтобы
+// два прохода подбора не делили одни и те же заявки. Возвращает функцию,
+// снимающую блокировку, или nil, если очередь уже разбирают.
+func (m *MatchmakingService) lockQueue(ctx context.Context, queueKey string) func() {
+	lockKey := "match_lock:" + queueKey
+	token := utils.GenerateSessionID()
+	locked, err := m.RDB.SetNX(ctx, lockKey, token, matchLockTTL).Result()
+	if err != nil {
+		logger.Warn("failed to lock match queue:", err)
 		return nil
 	}
+	if !locked {
+		return nil
+	}
+	return func() {
+		if err := unlockScript.Run(ctx, m.RDB, []string{lockKey}, token).Err(); err != nil {
+			logger.Warn("failed to unlock match queue:", err)
+		}
+	}
+}
 
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	r.Shuffle(len(players), func(i, j int) {
-		players[i], players[j] = players[j], players[i]
-	})
-	p1, p2 := players[0], players[1]
-	_, _ = m.RDB.SRem(ctx, "match_queue", p1, p2).Result()
+func (m *MatchmakingService) matchQueue(ctx context.Context, queueKey string) {
+	// Занятую очередь подберет следующий проход StartMatcher
+	unlock := m.lockQueue(ctx, queueKey)
+	if unlock == nil {
+		return
+	}
+	defer unlock()
 
+	tickets, err := m.queueTickets(ctx, queueKey)
+	if err != nil {
+		logger.Warn("failed to read match queue:", err)
+		return
+	}
+	now := time.Now()
+
+	matched := make(map[string]bool)
+	for _, pair := range pairTickets(tickets, now) {
+		if m.startMatch(ctx, queueKey, pair[0], pair[1], now) {
+			matched[pair[0].Nickname] = true
+			matched[pair[1].Nickname] = true
+		}
+	}
+
+	// Оставшимся сообщаем о расширении окна
+	var waiting []matchTicket
+	for _, t := range tickets {
+		if !matched[t.Nickname] {
+			waiting = append(waiting, t)
+		}
+	}
+	for _, t := range waiting {
+		waited := now.Sub(t.Joined)
+		if searchWindow(waited) != searchWindow(waited-matchInterval) {
+			m.sendSearching(queueKey, t, waiting, now)
+		}
+	}
+}
+
+// startMatch снимает пару с очереди и создает партию. Возвращает false,
+// если кто-то из игроков успел покинуть очередь или начать другую партию.
+func (m *MatchmakingService) startMatch(ctx context.Context, queueKey string, a, b matchTicket, now time.Time) bool {
+	taken, err := takePairScript.Run(ctx, m.RDB, []string{queueKey, matchTicketsKey, matchJoinedKey}, a.Nickname, b.Nickname).Int()
+	if err != nil {
+		logger.Warn("failed to remove matched players:", err)
+		return false
+	}
+	if taken == 0 {
+		// Один из игроков отменил поиск, второй остался в очереди
+		return false
+	}
+
+	// Игрок мог начать партию, пока ждал: ее нельзя заменить новой.
+	// Свободный игрок возвращается в очередь со своим местом.
+	busyA, busyB := m.GameManager.Playing(a.Nickname), m.GameManager.Playing(b.Nickname)
+	if busyA || busyB {
+		if !busyA {
+			m.requeue(ctx, queueKey, a)
+		}
+		if !busyB {
+			m.requeue(ctx, queueKey, b)
+		}
+		return false
+	}
+
+	m.recordWait(queueKey, now.Sub(a.Joined))
+	m.recordWait(queueKey, now.Sub(b.Joined))
+
+	opts, tc, gameRules, err := m.loadQueueSettings(ctx, queueKey)
+	if err != nil {
+		logger.Error(fmt.Sprintf("invalid match queue %s: %v", queueKey, err))
+		return false
+	}
+
+	p1, p2 := a.Nickname, b.Nickname
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 	symbols := []string{"X", "O"}
 	r.Shuffle(2, func(i, j int) { symbols[i], symbols[j] = symbols[j], symbols[i] })
 
-	m.sendMatchFound(p1, p2, symbols[0])
-	m.sendMatchFound(p2, p1, symbols[1])
-
-	m.GameManager.CreateGame(p1, p2, symbols[0], symbols[1])
+	gameID := m.GameManager.CreateGame(p1, p2, symbols[0], symbols[1], gameRules, tc, true)
+	m.sendMatchFound(gameID, p1, p2, symbols[0], opts, tc)
+	m.sendMatchFound(gameID, p2, p1, symbols[1], opts, tc)
 
 	if err := m.RDB.Incr(ctx, "active_games").Err(); err != nil {
 		logger.Warn("failed to increment active_games:", err)
 	}
+	logger.Info(fmt.Sprintf("Match found: %s (%d) vs %s (%d)", p1, a.Rating, p2, b.Rating))
+	return true
+}
+
+// requeue возвращает снятую заявку в очередь с прежним временем ожидания.
+func (m *MatchmakingService) requeue(ctx context.Context, queueKey string, t matchTicket) {
+	err := joinQueueScript.Run(ctx, m.RDB, []string{matchTicketsKey, matchJoinedKey, queueKey},
+		t.Nickname, t.Joined.UnixMilli(), t.Rating).Err()
+	if err != nil {
+		logger.Warn("failed to return player to match queue:", err)
+	}
+}
+
+// recordWait обновляет сглаженное время ожидания очереди.
+func (m *MatchmakingService) recordWait(queueKey string, waited time.Duration) {
+	m.waitMu.Lock()
+	defer m.waitMu.Unlock()
 
+	if avg, ok := m.avgWait[queueKey]; ok {
+		m.avgWait[queueKey] = (avg*4 + waited) / 5
+	} else {
+		m.avgWait[queueKey] = waited
+	}
+}
+
+// estimateWait оценивает оставшееся ожидание: по ближайшему по рейтингу
+// сопернику в очереди, а если такого нет - по недавним подборам.
+func (m *MatchmakingService) estimateWait(queueKey string, t matchTicket, queue []matchTicket, now time.Time) (time.Duration, bool) {
+	waited := now.Sub(t.Joined)
+	best, found := time.Duration(0), false
+	for _, other := range queue {
+		if other.Nickname == t.Nickname {
+			continue
+		}
+		diff := other.Rating - t.Rating
+		if diff < 0 {
+			diff = -diff
+		}
+		mine, ok := timeToWindow(diff, waited)
+		if !ok {
+			continue
+		}
+		theirs, _ := timeToWindow(diff, now.Sub(other.Joined))
+		if theirs > mine {
+			mine = theirs
+		}
+		if !found || mine < best {
+			best, found = mine, true
+		}
+	}
+	if found {
+		return best, true
+	}
+
+	m.waitMu.Lock()
+	avg, ok := m.avgWait[queueKey]
+	m.waitMu.Unlock()
+	if !ok {
+		return 0, false
+	}
+	if avg -= waited; avg < 0 {
+		avg = 0
+	}
+	return avg, true
+}
+
+func (m *MatchmakingService) sendSearching(queueKey string, t matchTicket, queue []matchTicket, now time.Time) {
+	window := searchWindow(now.Sub(t.Joined))
+	msg := &protocol.Searching{
+		Rating: t.Rating,
+		Window: protocol.RatingWindow{
+			Min: t.Rating - window,
+			Max: t.Rating + window,
+		},
+	}
+	if wait, ok := m.estimateWait(queueKey, t, queue, now); ok {
+		seconds := int(wait.Round(time.Second) / time.Second)
+		msg.EstimatedWait = &seconds
+	}
+	m.Notifier.Send(t.Nickname, msg)
+}
+
+// queueSettings - настройки партии, для которой собирается очередь
+type queueSettings struct {
+	Options     rules.Options      `json:"options"`
+	TimeControl models.TimeControl `json:"time_control"`
+}
+
+// loadQueueSettings восстанавливает настройки партии по ключу очереди.
+func (m *MatchmakingService) loadQueueSettings(ctx context.Context, queueKey string) (rules.Options, models.TimeControl, rules.GameRules, error) {
+	raw, err := m.RDB.HGet(ctx, matchQueuesKey, queueKey).Result()
+	if err != nil {
+		return rules.Options{}, models.TimeControl{}, nil, err
+	}
+	var settings queueSettings
+	if err := json.Unmarshal([]byte(raw), &settings); err != nil {
+		return rules.Options{}, models.TimeControl{}, nil, err
+	}
+	gameRules, err := rules.New(settings.Options)
+	if err != nil {
+		return rules.Options{}, models.TimeControl{}, nil, err
+	}
+	return gameRules.Options(), settings.TimeControl, gameRules, nil
+}
+
+// leaveQueue убирает игрока из очереди, в которой он ждет.
+func (m *MatchmakingService) leaveQueue(ctx context.Context, nickname string) (bool, error) {
+	removed, err := leaveQueueScript.Run(ctx, m.RDB, []string{matchTicketsKey, matchJoinedKey}, nickname).Int()
+	if err != nil {
+		return false, err
+	}
+	return removed == 1, nil
+}
+
+// checkLobby возвращает ErrAlreadyQueued или ErrAlreadyHost, если nickname
+// уже ждет соперника в очереди подбора или в открытой комнате. Без Redis
+// очереди подбора нет.
+func checkLobby(ctx context.Context, rdb *redis.Client, owners *Ownership, nickname string) error {
+	if rdb != nil {
+		queued, err := rdb.HExists(ctx, matchTicketsKey, nickname).Result()
+		if err != nil {
+			return err
+		}
+		if queued {
+			return ErrAlreadyQueued
+		}
+	}
+	if hostingRoom(owners, nickname) {
+		return ErrAlreadyHost
+	}
 	return nil
 }
 
+// LeaveQueue снимает заявку игрока, например при отключении.
+func (m *MatchmakingService) LeaveQueue(nickname string) {
+	if _, err := m.leaveQueue(context.Background(), nickname); err != nil {
+		logger.Warn("failed to remove from match queue:", err)
+	}
+}
+
 func (m *MatchmakingService) HandleCancelMatch(nickname string) error {
 	ctx := context.Background()
-	removed, err := m.RDB.SRem(ctx, "match_queue", nickname).Result()
+	removed, err := m.leaveQueue(ctx, nickname)
 	if err != nil {
 		return err
 	}
-	if removed == 0 {
-		return errors.New("not in queue")
+	if !removed {
+		return ErrNotQueued
 	}
 
-	if c, ok := m.Clients.Load(nickname); ok {
-		conn := c.(*websocket.Conn)
-		_ = conn.WriteJSON(map[string]interface{}{"type": "match_cancelled"})
-	}
+	m.Notifier.Send(nickname, &protocol.MatchCancelled{})
 	logger.Info("Removed from match queue:", nickname)
 	return nil
 }
 
+// HandleDisconnect вызывается, когда у игрока закрылось последнее
+// соединение. В идущей партии игроку дается время вернуться через
+// rejoin_match: сопернику и зрителям уходит opponent_disconnected с
+// обратным отсчетом, а по его истечении партия засчитывается сопернику.
+// Завершенная партия, где ждали реванша, закрывается сразу.
 func (m *MatchmakingService) HandleDisconnect(nickname string) {
-	ctx := context.Background()
-	if _, err := m.RDB.SRem(ctx, "match_queue", nickname).Result(); err != nil {
-		logger.Warn("failed to remove from match_queue:", err)
+	if _, ok := m.GameManager.GetGame(nickname); !ok {
+		return
 	}
 
-	game, ok := m.GameManager.GetGame(nickname)
-	if !ok {
+	if deadline, ok := m.GameManager.Disconnect(nickname); ok {
+		msg := &protocol.OpponentDisconnected{
+			Nickname: nickname,
+			Seconds:  secondsUntil(deadline, time.Now()),
+		}
+		m.GameManager.RecordEvent(nickname, msg)
+		for _, p := range m.GameManager.Recipients(nickname) {
+			if p != nickname {
+				m.Notifier.Send(p, msg)
+			}
+		}
 		return
 	}
-	opponent := game.PlayerO
-	if nickname == game.PlayerO {
-		opponent = game.PlayerX
-	}
 
-	if c, ok := m.Clients.Load(opponent); ok {
-		conn := c.(*websocket.Conn)
-		_ = conn.WriteJSON(map[string]interface{}{"type": "opponent_left"})
+	if opponent, ok := m.GameManager.HumanOpponent(nickname); ok {
+		m.Notifier.Send(opponent, &protocol.OpponentLeft{})
 	}
-
 	m.GameManager.FinishGame(m.RDB, nickname)
 }
 
-func (m *MatchmakingService) sendMatchFound(player, opponent, symbol string) {
-	if c, ok := m.Clients.Load(player); ok {
-		conn := c.(*websocket.Conn)
-		msg := map[string]interface{}{
-			"type":     "match_found",
-			"symbol":   symbol,
-			"opponent": opponent,
-		}
-		_ = conn.WriteJSON(msg)
+func (m *MatchmakingService) sendMatchFound(gameID, player, opponent, symbol string, opts rules.Options, tc models.TimeControl) {
+	m.Notifier.Send(player, matchFoundMessage(gameID, opponent, symbol, opts, tc))
+}
+
+func matchFoundMessage(gameID, opponent, symbol string, opts rules.Options, tc models.TimeControl) *protocol.MatchFound {
+	msg := &protocol.MatchFound{
+		GameID:   gameID,
+		Symbol:   symbol,
+		Opponent: opponent,
+		Board:    boardOf(opts),
+	}
+	if tc.Enabled() {
+		msg.TimeControl = timeControlFields(tc)
 	}
+	return msg
 }
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSearchWindowWidens(t *testing.T) {
+	cases := []struct {
+		waited time.Duration
+		want   int
+	}{
+		{0, initialWindow},
+		{windowGrowEvery - time.Millisecond, initialWindow},
+		{windowGrowEvery, initialWindow + windowStep},
+		{3 * windowGrowEvery, initialWindow + 3*windowStep},
+		{time.Hour, maxWindow},
+	}
+	for _, c := range cases {
+		if got := searchWindow(c.waited); got != c.want {
+			t.Errorf("searchWindow(%v) = %d, want %d", c.waited, got, c.want)
+		}
+	}
+}
+
+func TestTimeToWindow(t *testing.T) {
+	if d, ok := timeToWindow(initialWindow, 0); !ok || d != 0 {
+		t.Errorf("timeToWindow(initial) = %v, %v", d, ok)
+	}
+	if d, ok := timeToWindow(initialWindow+1, 2*time.Second); !ok || d != windowGrowEvery-2*time.Second {
+		t.Errorf("timeToWindow(initial+1) = %v, %v", d, ok)
+	}
+	if _, ok := timeToWindow(maxWindow+1, 0); ok {
+		t.Error("window can never exceed maxWindow")
+	}
+}
+
+func TestPairTickets(t *testing.T) {
+	now := time.Now()
+	fresh := now
+	old := now.Add(-4 * windowGrowEvery)
+
+	tickets := []matchTicket{
+		{Nickname: "a", Rating: 1000, Joined: fresh},
+		{Nickname: "b", Rating: 1040, Joined: fresh},
+		{Nickname: "c", Rating: 1200, Joined: old},
+		{Nickname: "d", Rating: 1290, Joined: fresh},
+		{Nickname: "e", Rating: 1500, Joined: old},
+	}
+
+	pairs := pairTickets(tickets, now)
+	if len(pairs) != 1 {
+		t.Fatalf("got %d pairs, want 1: %v", len(pairs), pairs)
+	}
+	if pairs[0][0].Nickname != "a" || pairs[0][1].Nickname != "b" {
+		t.Errorf("paired %s with %s, want a with b", pairs[0][0].Nickname, pairs[0][1].Nickname)
+	}
+
+	// Once d has waited long enough, its window covers c as well
+	tickets[3].Joined = old
+	pairs = pairTickets(tickets, now)
+	if len(pairs) != 2 || pairs[1][0].Nickname != "c" || pairs[1][1].Nickname != "d" {
+		t.Errorf("pairs = %v, want a-b and c-d", pairs)
+	}
+}
@@ -0,0 +1,10 @@
+package services
+
+import "tictactoe/internal/protocol"
+
+// Notifier доставляет сообщения игрокам независимо от того, к какому
+// экземпляру сервера они подключены.
+type Notifier interface {
+	Send(nickname string, msg protocol.Message)
+	Online(nickname string) bool
+}
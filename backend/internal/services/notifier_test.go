@@ -0,0 +1,34 @@
+package services
+
+import (
+	"sync"
+
+	"tictactoe/internal/protocol"
+)
+
+// testNotifier records messages instead of delivering them.
+type testNotifier struct {
+	mu     sync.Mutex
+	online map[string]bool
+	sent   map[string][]protocol.Message
+}
+
+func newTestNotifier(online ...string) *testNotifier {
+	n := &testNotifier{online: make(map[string]bool), sent: make(map[string][]protocol.Message)}
+	for _, nickname := range online {
+		n.online[nickname] = true
+	}
+	return n
+}
+
+func (n *testNotifier) Send(nickname string, msg protocol.Message) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent[nickname] = append(n.sent[nickname], msg)
+}
+
+func (n *testNotifier) Online(nickname string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.online[nickname]
+}
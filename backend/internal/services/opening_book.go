@@ -0,0 +1,229 @@
+package services
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"tictactoe/internal/rules"
+)
+
+// OpeningBook - дебютная книга бота. Формат файла:
+//
+//	# комментарий до конца строки
+//	[classic]
+//	> 0 2 6 8
+//	4 > 0 2 6 8
+//	0 4 > 8
+//
+// Заголовок в скобках - вариант в виде rules.Options.Key() (classic,
+// misere, wild, ultimate, gomoku:15x5). Строка "ходы > ответы": слева ходы
+// партии по порядку (пусто для начальной позиции), справа клетки, из
+// которых бот выбирает ответ случайно. В wild за клеткой пишется метка:
+// 4X, 0O. Позиции сравниваются по доске, а не по порядку ходов, поэтому
+// строка срабатывает при любой перестановке ходов (кроме ultimate, где
+// важен последний ход).
+type OpeningBook struct {
+	// positions: вариант -> позиция -> ответы
+	positions map[string]map[string][]rules.Move
+}
+
+// ParseOpeningBook читает книгу и проверяет, что все ходы в ней допустимы.
+func ParseOpeningBook(r io.Reader) (*OpeningBook, error) {
+	book := &OpeningBook{positions: make(map[string]map[string][]rules.Move)}
+	var gameRules rules.GameRules
+	var section string
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			opts, err := parseOptionsKey(strings.TrimSpace(line[1 : len(line)-1]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if gameRules, err = rules.New(opts); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			section = gameRules.Options().Key()
+			continue
+		}
+		if gameRules == nil {
+			return nil, fmt.Errorf("line %d: moves before a [variant] header", lineNo)
+		}
+
+		played, replies, ok := strings.Cut(line, ">")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"moves > replies\"", lineNo)
+		}
+		state := gameRules.NewState()
+		for _, token := range strings.Fields(played) {
+			move, err := parseMoveToken(token)
+			if err == nil {
+				err = gameRules.Apply(state, move)
+			}
+			if err != nil || gameRules.Outcome(state).Finished {
+				return nil, fmt.Errorf("line %d: bad move %q", lineNo, token)
+			}
+		}
+
+		key := bookKey(gameRules, state)
+		for _, token := range strings.Fields(replies) {
+			move, err := parseMoveToken(token)
+			if err == nil {
+				err = gameRules.Apply(state.Clone(), move)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("line %d: bad reply %q", lineNo, token)
+			}
+			if book.positions[section] == nil {
+				book.positions[section] = make(map[string][]rules.Move)
+			}
+			book.positions[section][key] = append(book.positions[section][key], move)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// Moves - ответы книги на позицию или nil, если позиции в книге нет.
+func (b *OpeningBook) Moves(gameRules rules.GameRules, state *rules.State) []rules.Move {
+	if b == nil {
+		return nil
+	}
+	return b.positions[gameRules.Options().Key()][bookKey(gameRules, state)]
+}
+
+// Size - число позиций в книге по всем вариантам.
+func (b *OpeningBook) Size() int {
+	n := 0
+	for _, positions := range b.positions {
+		n += len(positions)
+	}
+	return n
+}
+
+// bookKey - позиция для поиска в книге. В вариантах из нескольких досок
+// в ключ входит последний ход: от него зависит, где можно ходить.
+func bookKey(gameRules rules.GameRules, state *rules.State) string {
+	var sb strings.Builder
+	for _, mark := range state.Board {
+		if mark == "" {
+			mark = "."
+		}
+		sb.WriteString(mark)
+	}
+	sb.WriteString("|" + state.Turn)
+	if _, ok := gameRules.(rules.SubBoards); ok {
+		fmt.Fprintf(&sb, "|%d", state.LastMove)
+	}
+	return sb.String()
+}
+
+// parseMoveToken разбирает ход вида 4 или 4X.
+func parseMoveToken(token string) (rules.Move, error) {
+	var move rules.Move
+	if n := len(token); n > 1 && (token[n-1] == 'X' || token[n-1] == 'O') {
+		move.Symbol = token[n-1:]
+		token = token[:n-1]
+	}
+	cell, err := strconv.Atoi(token)
+	if err != nil {
+		return move, err
+	}
+	move.Cell = cell
+	return move, nil
+}
+
+// parseOptionsKey - обратное к rules.Options.Key().
+func parseOptionsKey(key string) (rules.Options, error) {
+	variant, params, ok := strings.Cut(key, ":")
+	opts := rules.Options{Variant: variant}
+	if ok {
+		if _, err := fmt.Sscanf(params, "%dx%d", &opts.Size, &opts.WinLength); err != nil {
+			return opts, fmt.Errorf("bad variant %q", key)
+		}
+	}
+	return opts, nil
+}
+
+//go:embed books/*.book
+var defaultBooks embed.FS
+
+var (
+	booksMu sync.RWMutex
+	// openingBooks - книги характеров по id
+	openingBooks = mustLoadDefaultBooks()
+)
+
+// mustLoadDefaultBooks читает книги, вшитые в бинарник. Ошибка в них -
+// ошибка сборки, ее ловят тесты.
+func mustLoadDefaultBooks() map[string]*OpeningBook {
+	books := make(map[string]*OpeningBook)
+	files, _ := defaultBooks.ReadDir("books")
+	for _, file := range files {
+		f, err := defaultBooks.Open("books/" + file.Name())
+		if err != nil {
+			panic(err)
+		}
+		book, err := ParseOpeningBook(f)
+		f.Close()
+		if err != nil {
+			panic(fmt.Sprintf("books/%s: %v", file.Name(), err))
+		}
+		books[strings.TrimSuffix(file.Name(), ".book")] = book
+	}
+	return books
+}
+
+// LoadOpeningBooks заменяет книги характеров файлами <id>.book из dir.
+// Характеры, для которых файла нет, оставляют вшитые книги.
+func LoadOpeningBooks(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.book"))
+	if err != nil {
+		return err
+	}
+	loaded := make(map[string]*OpeningBook)
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		book, err := ParseOpeningBook(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		loaded[strings.TrimSuffix(filepath.Base(path), ".book")] = book
+	}
+
+	booksMu.Lock()
+	defer booksMu.Unlock()
+	for id, book := range loaded {
+		openingBooks[id] = book
+	}
+	return nil
+}
+
+// openingBook - книга характера или nil.
+func openingBook(personality string) *OpeningBook {
+	booksMu.RLock()
+	defer booksMu.RUnlock()
+	return openingBooks[personality]
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"tictactoe/internal/logger"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Виды объектов, которыми владеет экземпляр сервера
+const (
+	OwnedPlayer    = "player"    // соединение игрока по нику
+	OwnedGame      = "game"      // партия по id и никам игроков
+	OwnedSpectator = "spectator" // зритель по нику
+	OwnedRoom      = "room"      // комната по коду
+	OwnedHost      = "host"      // открытая комната по нику хоста
+	OwnedChallenge = "challenge" // вызов по id
+)
+
+// releaseScript удаляет ключи, только если ими владеет этот экземпляр.
+var releaseScript = redis.NewScript(`
+local n = 0
+for i = 2, #ARGV do
+	if redis.call('HGET', KEYS[1], ARGV[i]) == ARGV[1] then
+		n = n + redis.call('HDEL', KEYS[1], ARGV[i])
+	end
+end
+return n
+`)
+
+// Ownership хранит в Redis, какой экземпляр ведет партию, комнату или вызов.
+// Команды для чужих объектов пересылаются владельцу. nil означает работу
+// в одном экземпляре: все объекты локальные.
+type Ownership struct {
+	rdb        *redis.Client
+	instanceID string
+}
+
+func NewOwnership(rdb *redis.Client, instanceID string) *Ownership {
+	return &Ownership{rdb: rdb, instanceID: instanceID}
+}
+
+func (o *Ownership) InstanceID() string {
+	if o == nil {
+		return ""
+	}
+	return o.instanceID
+}
+
+func ownersKey(kind string) string {
+	return "owners:" + kind
+}
+
+// Claim закрепляет ключи за этим экземпляром.
+func (o *Ownership) Claim(kind string, keys ...string) {
+	if o == nil || len(keys) == 0 {
+		return
+	}
+	values := make([]interface{}, 0, len(keys)*2)
+	for _, key := range keys {
+		values = append(values, key, o.instanceID)
+	}
+	if err := o.rdb.HSet(context.Background(), ownersKey(kind), values...).Err(); err != nil {
+		logger.Warn("failed to claim ownership:", err)
+	}
+}
+
+// Release снимает закрепление, если ключи все еще принадлежат этому экземпляру.
+func (o *Ownership) Release(kind string, keys ...string) {
+	if o == nil || len(keys) == 0 {
+		return
+	}
+	args := make([]interface{}, 0, len(keys)+1)
+	args = append(args, o.instanceID)
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	if err := releaseScript.Run(context.Background(), o.rdb, []string{ownersKey(kind)}, args...).Err(); err != nil {
+		logger.Warn("failed to release ownership:", err)
+	}
+}
+
+// Remote возвращает экземпляр-владелец, если это не текущий экземпляр.
+func (o *Ownership) Remote(kind, key string) (string, bool) {
+	if o == nil || key == "" {
+		return "", false
+	}
+	owner, err := o.rdb.HGet(context.Background(), ownersKey(kind), key).Result()
+	if err != nil {
+		if err != redis.Nil {
+			logger.Warn("failed to look up owner:", err)
+		}
+		return "", false
+	}
+	return owner, owner != o.instanceID
+}
+
+// instanceCountTTL - сколько живет значение счетчика экземпляра без обновления
+const instanceCountTTL = 30 * time.Second
+
+// ShareCount сохраняет значение счетчика name для этого экземпляра и
+// возвращает сумму по всем работающим экземплярам.
+func (o *Ownership) ShareCount(ctx context.Context, name string, local int) int {
+	if o == nil {
+		return local
+	}
+	prefix := name + ":instance:"
+	if err := o.rdb.Set(ctx, prefix+o.instanceID, local, instanceCountTTL).Err(); err != nil {
+		logger.Warn("failed to share counter:", err)
+		return local
+	}
+
+	total := 0
+	iter := o.rdb.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		n, err := o.rdb.Get(ctx, iter.Val()).Int()
+		if err == nil {
+			total += n
+		}
+	}
+	if err := iter.Err(); err != nil {
+		logger.Warn("failed to sum counter:", err)
+		return local
+	}
+	return total
+}
+
+// instanceTTL - экземпляр считается живым, пока обновляет свой ключ
+const instanceTTL = 30 * time.Second
+
+func instanceKey(id string) string {
+	return "instance:" + id
+}
+
+// Heartbeat отмечает экземпляр как работающий.
+func (o *Ownership) Heartbeat() {
+	if o == nil {
+		return
+	}
+	if err := o.rdb.Set(context.Background(), instanceKey(o.instanceID), time.Now().Unix(), instanceTTL).Err(); err != nil {
+		logger.Warn("failed to send heartbeat:", err)
+	}
+}
+
+// adoptScript закрепляет ключи, у которых нет владельца или владелец
+// перестал отвечать, и возвращает их.
+var adoptScript = redis.NewScript(`
+local adopted = {}
+for i = 3, #ARGV do
+	local owner = redis.call('HGET', KEYS[1], ARGV[i])
+	if (not owner) or owner == ARGV[1] or redis.call('EXISTS', ARGV[2] .. owner) == 0 then
+		redis.call('HSET', KEYS[1], ARGV[i], ARGV[1])
+		table.insert(adopted, ARGV[i])
+	end
+end
+return adopted
+`)
+
+// Adopt забирает себе ключи без живого владельца. Без учета владельцев
+// (один экземпляр) возвращает все ключи.
+func (o *Ownership) Adopt(kind string, keys []string) []string {
+	if o == nil || len(keys) == 0 {
+		return keys
+	}
+	args := make([]interface{}, 0, len(keys)+2)
+	args = append(args, o.instanceID, instanceKey(""))
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	adopted, err := adoptScript.Run(context.Background(), o.rdb, []string{ownersKey(kind)}, args...).StringSlice()
+	if err != nil {
+		logger.Warn("failed to adopt orphaned keys:", err)
+		return nil
+	}
+	return adopted
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"tictactoe/internal/logger"
+	"tictactoe/internal/models"
+	"tictactoe/internal/protocol"
+	"tictactoe/internal/rules"
+	"tictactoe/internal/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// roomLobbyTimeout - сколько комната ждет второго игрока
+const roomLobbyTimeout = 10 * time.Minute
+
+var (
+	ErrRoomNotFound   = errors.New("room not found")
+	ErrAlreadyHost    = errors.New("you already have an open room")
+	ErrInGame         = errors.New("finish your game first")
+	ErrBotTimeControl = errors.New("games against a bot have no time control")
+)
+
+// RoomSettings - настройки, с которыми создается комната.
+type RoomSettings struct {
+	Options     rules.Options
+	TimeControl models.TimeControl
+	Rated       bool
+	// HostSymbol - "X", "O" или пустая строка для случайного выбора
+	HostSymbol    string
+	BotDifficulty models.BotDifficulty
+}
+
+type RoomService struct {
+	RDB         *redis.Client
+	Notifier    Notifier
+	GameManager *GameManager
+
+	mu     sync.Mutex
+	rooms  map[string]*models.Room
+	byHost map[string]*models.Room
+}
+
+func NewRoomService(rdb *redis.Client, notifier Notifier, gm *GameManager) *RoomService {
+	return &RoomService{
+		RDB:         rdb,
+		Notifier:    notifier,
+		GameManager: gm,
+		rooms:       make(map[string]*models.Room),
+		byHost:      make(map[string]*models.Room),
+	}
+}
+
+// CreateRoom открывает лобби и отправляет хосту room_update. Комната с
+// BotDifficulty не ждет второго игрока: второе место сразу занимает бот,
+// а партию с ним начинает вызывающий.
+func (s *RoomService) CreateRoom(host string, settings RoomSettings) (*models.Room, error) {
+	gameRules, err := rules.New(settings.Options)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTimeControl(settings.TimeControl); err != nil {
+		return nil, err
+	}
+	switch settings.HostSymbol {
+	case "", rules.X, rules.O:
+	default:
+		return nil, errors.New("symbol must be X, O or random")
+	}
+	if settings.BotDifficulty != "" && settings.TimeControl.Enabled() {
+		// Бот играет без часов
+		return nil, ErrBotTimeControl
+	}
+	if s.GameManager.Playing(host) {
+		return nil, ErrInGame
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byHost[host]; ok {
+		return nil, ErrAlreadyHost
+	}
+	// Иначе подбор может начать вторую партию хоста поверх этой
+	if err := checkLobby(context.Background(), s.RDB, s.GameManager.Owners(), host); err != nil {
+		return nil, err
+	}
+
+	code := utils.GenerateRoomCode()
+	for s.rooms[code] != nil {
+		code = utils.GenerateRoomCode()
+	}
+	room := &models.Room{
+		Code:          code,
+		Host:          host,
+		Rules:         gameRules,
+		TimeControl:   settings.TimeControl,
+		Rated:         settings.Rated,
+		HostSymbol:    settings.HostSymbol,
+		BotDifficulty: settings.BotDifficulty,
+		CreatedAt:     time.Now(),
+	}
+	if room.BotDifficulty != "" {
+		room.Guest = room.BotDifficulty.Bot().Name()
+		s.send(host, roomUpdate(room, "started"))
+		return room, nil
+	}
+
+	room.ExpireTimer = time.AfterFunc(roomLobbyTimeout, func() {
+		s.expire(room)
+	})
+	s.rooms[code] = room
+	s.byHost[host] = room
+	s.GameManager.Owners().Claim(OwnedRoom, code)
+	s.GameManager.Owners().Claim(OwnedHost, host)
+
+	logger.Info(fmt.Sprintf("Room created: %s by %s", code, host))
+	s.send(host, roomUpdate(room, "waiting"))
+	return room, nil
+}
+
+// JoinRoom занимает второе место в комнате и начинает партию.
+func (s *RoomService) JoinRoom(code, nickname string) (*models.Room, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	if s.GameManager.Playing(nickname) {
+		return nil, ErrInGame
+	}
+
+	s.mu.Lock()
+	room, ok := s.rooms[code]
+	if !ok {
+		s.mu.Unlock()
+		return nil, ErrRoomNotFound
+	}
+	if room.Host == nickname {
+		s.mu.Unlock()
+		return nil, errors.New("you cannot join your own room")
+	}
+	if s.GameManager.Playing(room.Host) {
+		s.mu.Unlock()
+		return nil, errors.New("host is in another game")
+	}
+	if err := checkLobby(context.Background(), s.RDB, s.GameManager.Owners(), nickname); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	room.Guest = nickname
+	s.closeLocked(room)
+	s.mu.Unlock()
+
+	hostSymbol := room.HostSymbol
+	if hostSymbol == "" {
+		hostSymbol = rules.X
+		if rand.Intn(2) == 1 {
+			hostSymbol = rules.O
+		}
+	}
+	guestSymbol := rules.Opposite(hostSymbol)
+
+	update := roomUpdate(room, "started")
+	s.send(room.Host, update)
+	s.send(room.Guest, update)
+
+	opts := room.Rules.Options()
+	gameID := s.GameManager.CreateGame(room.Host, room.Guest, hostSymbol, guestSymbol, room.Rules, room.TimeControl, room.Rated)
+	s.sendMatchFound(room, gameID, room.Host, room.Guest, hostSymbol, opts)
+	s.sendMatchFound(room, gameID, room.Guest, room.Host, guestSymbol, opts)
+
+	if err := s.RDB.Incr(context.Background(), "active_games").Err(); err != nil {
+		logger.Warn("failed to increment active_games:", err)
+	}
+	logger.Info(fmt.Sprintf("Room started: %s, %s vs %s", room.Code, room.Host, room.Guest))
+	return room, nil
+}
+
+// LeaveRoom закрывает комнату, которую держит nickname.
+func (s *RoomService) LeaveRoom(nickname string) bool {
+	s.mu.Lock()
+	room, ok := s.byHost[nickname]
+	if ok {
+		s.closeLocked(room)
+	}
+	s.mu.Unlock()
+
+	if ok {
+		s.send(nickname, roomUpdate(room, "closed"))
+	}
+	return ok
+}
+
+func (s *RoomService) expire(room *models.Room) {
+	s.mu.Lock()
+	if s.rooms[room.Code] != room {
+		s.mu.Unlock()
+		return
+	}
+	s.closeLocked(room)
+	s.mu.Unlock()
+
+	logger.Info("Room expired:", room.Code)
+	s.send(room.Host, roomUpdate(room, "expired"))
+}
+
+func (s *RoomService) closeLocked(room *models.Room) {
+	if room.ExpireTimer != nil {
+		room.ExpireTimer.Stop()
+		room.ExpireTimer = nil
+	}
+	delete(s.rooms, room.Code)
+	delete(s.byHost, room.Host)
+	s.GameManager.Owners().Release(OwnedRoom, room.Code)
+	s.GameManager.Owners().Release(OwnedHost, room.Host)
+}
+
+// hostingRoom сообщает, ждет ли nickname соперника в открытой комнате на
+// каком-нибудь экземпляре.
+func hostingRoom(owners *Ownership, nickname string) bool {
+	owner, _ := owners.Remote(OwnedHost, nickname)
+	return owner != ""
+}
+
+func (s *RoomService) sendMatchFound(room *models.Room, gameID, player, opponent, symbol string, opts rules.Options) {
+	msg := matchFoundMessage(gameID, opponent, symbol, opts, room.TimeControl)
+	msg.Room = room.Code
+	msg.Rated = &room.Rated
+	s.send(player, msg)
+}
+
+func (s *RoomService) send(nickname string, msg protocol.Message) {
+	s.Notifier.Send(nickname, msg)
+}
+
+// roomUpdate описывает комнату для клиента.
+func roomUpdate(room *models.Room, status string) *protocol.RoomUpdate {
+	symbol := room.HostSymbol
+	if symbol == "" {
+		symbol = "random"
+	}
+	msg := &protocol.RoomUpdate{
+		Code:       room.Code,
+		Status:     status,
+		Host:       room.Host,
+		Guest:      room.Guest,
+		Rated:      room.Rated,
+		HostSymbol: symbol,
+		Board:      boardOf(room.Rules.Options()),
+	}
+	if room.TimeControl.Enabled() {
+		msg.TimeControl = timeControlFields(room.TimeControl)
+	}
+	if status == "waiting" {
+		msg.ExpiresAt = room.CreatedAt.Add(roomLobbyTimeout).Unix()
+	}
+	return msg
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/rules"
+)
+
+func TestCreateAndLeaveRoom(t *testing.T) {
+	s := NewRoomService(nil, newTestNotifier(), NewGameManager(nil, nil))
+
+	room, err := s.CreateRoom("alice", RoomSettings{HostSymbol: rules.O})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(room.Code) != 6 {
+		t.Fatalf("unexpected room code %q", room.Code)
+	}
+	if _, err := s.CreateRoom("alice", RoomSettings{}); err != ErrAlreadyHost {
+		t.Fatalf("expected ErrAlreadyHost, got %v", err)
+	}
+	if _, err := s.JoinRoom(room.Code, "alice"); err == nil {
+		t.Fatal("expected the host to be refused")
+	}
+	if _, err := s.JoinRoom("nope", "bob"); err != ErrRoomNotFound {
+		t.Fatalf("expected ErrRoomNotFound, got %v", err)
+	}
+
+	if !s.LeaveRoom("alice") {
+		t.Fatal("expected alice's room to close")
+	}
+	if _, err := s.JoinRoom(room.Code, "bob"); err != ErrRoomNotFound {
+		t.Fatalf("expected closed room to be gone, got %v", err)
+	}
+}
+
+func TestCreateRoomValidation(t *testing.T) {
+	s := NewRoomService(nil, newTestNotifier(), NewGameManager(nil, nil))
+
+	if _, err := s.CreateRoom("alice", RoomSettings{HostSymbol: "Z"}); err == nil {
+		t.Error("expected invalid symbol to be rejected")
+	}
+	gomoku := rules.Options{Variant: rules.VariantGomoku, Size: 15, WinLength: 5}
+	if _, err := s.CreateRoom("carol", RoomSettings{Options: gomoku, BotDifficulty: models.DifficultyEasy}); err != nil {
+		t.Errorf("expected bot room for gomoku, got %v", err)
+	}
+
+	room, err := s.CreateRoom("alice", RoomSettings{BotDifficulty: models.DifficultyHard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room.Guest != "Bot_hard" {
+		t.Errorf("expected the bot to take the second seat, got %q", room.Guest)
+	}
+	if s.LeaveRoom("alice") {
+		t.Error("bot rooms should not wait in the lobby")
+	}
+
+	clocked := RoomSettings{BotDifficulty: models.DifficultyEasy, TimeControl: models.TimeControl{PerMove: 10 * time.Second}}
+	if _, err := s.CreateRoom("dave", clocked); err != ErrBotTimeControl {
+		t.Errorf("expected ErrBotTimeControl for a clocked bot room, got %v", err)
+	}
+}
+
+func TestUnratedGameSkipsElo(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	g.CreateGame("alice", "bob", "X", "O", rules.Classic{}, models.TimeControl{}, false)
+
+	game, _ := g.GetGame("alice")
+	if !game.Unrated {
+		t.Fatal("expected the game to be unrated")
+	}
+	record := newGameRecord(game)
+	// updateElo would panic on the nil userStore
+	g.rateGame(game, record, 1.0)
+	if record.EloXBefore != nil || record.EloOAfter != nil {
+		t.Fatal("unrated game must not record rating changes")
+	}
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/rules"
+)
+
+// DefaultCalibrationTemperatures - температуры ботов, которые сравнивает
+// калибровка. Ноль - лучшая игра, +Inf - случайные ходы.
+var DefaultCalibrationTemperatures = []float64{
+	0, 0.02, 0.05, 0.1, 0.15, 0.2, 0.3, 0.45, 0.7, 1, 1.5, 2.5, math.Inf(1),
+}
+
+// SelfPlay играет партии между ботами с разными температурами. Среди них
+// должны быть бот с нулевой температурой и случайный бот (+Inf): рейтинги
+// приводятся к диапазону ботов так, что случайный получает models.MinBotElo,
+// а лучший - models.MaxBotElo.
+type SelfPlay struct {
+	Rules        rules.GameRules
+	Temperatures []float64
+	// GamesPerPair - сколько партий играет каждая пара; стороны чередуются
+	GamesPerPair int
+	Seed         int64
+}
+
+// Calibrate проводит круговой турнир и возвращает рейтинги температур,
+// отсортированные по возрастанию.
+func (p SelfPlay) Calibrate() []CalibrationPoint {
+	bot := &BotService{rand: rand.New(rand.NewSource(p.Seed))}
+	bot.mcts = NewMCTSEngine(MCTSConfig{Playouts: 2000, Seed: p.Seed})
+
+	n := len(p.Temperatures)
+	scores := make([][]float64, n)
+	games := make([][]int, n)
+	for i := range scores {
+		scores[i] = make([]float64, n)
+		games[i] = make([]int, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			for k := 0; k < p.GamesPerPair; k++ {
+				x, o := i, j
+				if k%2 == 1 {
+					x, o = j, i
+				}
+				scoreX := bot.playGame(p.Rules, p.Temperatures[x], p.Temperatures[o])
+				scores[x][o] += scoreX
+				scores[o][x] += 1 - scoreX
+				games[i][j]++
+				games[j][i]++
+			}
+		}
+	}
+
+	ratings := FitRatings(scores, games)
+	best, random := 0, 0
+	for i, t := range p.Temperatures {
+		switch {
+		case t == 0:
+			best = i
+		case math.IsInf(t, 1):
+			random = i
+		}
+	}
+	scale := float64(models.MaxBotElo-models.MinBotElo) / (ratings[best] - ratings[random])
+
+	// Чем выше температура, тем слабее бот. Точки, которые из-за шума
+	// нарушают этот порядок, отбрасываются.
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return p.Temperatures[order[a]] < p.Temperatures[order[b]] })
+	var points []CalibrationPoint
+	for _, i := range order {
+		elo := models.MinBotElo + int(math.Round((ratings[i]-ratings[random])*scale))
+		if len(points) > 0 && elo >= points[len(points)-1].Elo {
+			continue
+		}
+		points = append(points, CalibrationPoint{Temperature: p.Temperatures[i], Elo: elo})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Elo < points[j].Elo })
+	return points
+}
+
+// playGame играет одну партию и возвращает очки X: 1, 0.5 или 0.
+func (b *BotService) playGame(gameRules rules.GameRules, temperatureX, temperatureO float64) float64 {
+	state := gameRules.NewState()
+	for {
+		outcome := gameRules.Outcome(state)
+		if outcome.Finished {
+			switch outcome.Winner {
+			case rules.X:
+				return 1
+			case rules.O:
+				return 0
+			}
+			return 0.5
+		}
+		temperature := temperatureX
+		if state.Turn == rules.O {
+			temperature = temperatureO
+		}
+		move := b.policyMove(gameRules, state, temperature, "")
+		if err := gameRules.Apply(state, move); err != nil {
+			return 0.5
+		}
+	}
+}
+
+// FitRatings находит рейтинги Эло по результатам турнира методом
+// максимального правдоподобия (модель Брэдли-Терри, ничья - пол-очка).
+// scores[i][j] - очки i против j, games[i][j] - число их партий. Каждой паре
+// добавляется одна условная ничья, чтобы рейтинги были конечными даже при
+// сплошных победах. Рейтинги возвращаются со средним 0.
+func FitRatings(scores [][]float64, games [][]int) []float64 {
+	n := len(scores)
+	gamma := make([]float64, n)
+	for i := range gamma {
+		gamma[i] = 1
+	}
+	for iter := 0; iter < 10000; iter++ {
+		maxChange := 0.0
+		for i := 0; i < n; i++ {
+			won, denom := 0.0, 0.0
+			for j := 0; j < n; j++ {
+				if i == j {
+					continue
+				}
+				won += scores[i][j] + 0.5
+				denom += float64(games[i][j]+1) / (gamma[i] + gamma[j])
+			}
+			next := won / denom
+			maxChange = math.Max(maxChange, math.Abs(math.Log(next/gamma[i])))
+			gamma[i] = next
+		}
+		if maxChange < 1e-9 {
+			break
+		}
+	}
+
+	ratings := make([]float64, n)
+	mean := 0.0
+	for i, g := range gamma {
+		ratings[i] = 400 * math.Log10(g)
+		mean += ratings[i] / float64(n)
+	}
+	for i := range ratings {
+		ratings[i] -= mean
+	}
+	return ratings
+}
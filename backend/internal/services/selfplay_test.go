@@ -0,0 +1,58 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/rules"
+)
+
+func TestFitRatingsRecoversRatings(t *testing.T) {
+	truth := []float64{-300, -100, 0, 250}
+	n := len(truth)
+	scores := make([][]float64, n)
+	games := make([][]int, n)
+	for i := range scores {
+		scores[i] = make([]float64, n)
+		games[i] = make([]int, n)
+		for j := range scores[i] {
+			if i == j {
+				continue
+			}
+			// Expected scores over many games
+			games[i][j] = 100000
+			scores[i][j] = float64(games[i][j]) / (1 + math.Pow(10, (truth[j]-truth[i])/400))
+		}
+	}
+
+	ratings := FitRatings(scores, games)
+	for i := 1; i < n; i++ {
+		want := truth[i] - truth[0]
+		if got := ratings[i] - ratings[0]; math.Abs(got-want) > 2 {
+			t.Errorf("player %d: rating difference %.1f, want %.1f", i, got, want)
+		}
+	}
+}
+
+func TestSelfPlayRanksBots(t *testing.T) {
+	points := SelfPlay{
+		Rules:        rules.Classic{},
+		Temperatures: []float64{0, 0.3, math.Inf(1)},
+		GamesPerPair: 40,
+		Seed:         1,
+	}.Calibrate()
+
+	if len(points) != 3 {
+		t.Fatalf("expected 3 points, got %+v", points)
+	}
+	if !math.IsInf(points[0].Temperature, 1) || points[0].Elo != models.MinBotElo {
+		t.Errorf("random bot should be rated %d: %+v", models.MinBotElo, points[0])
+	}
+	if points[2].Temperature != 0 || points[2].Elo != models.MaxBotElo {
+		t.Errorf("best bot should be rated %d: %+v", models.MaxBotElo, points[2])
+	}
+	if points[1].Temperature != 0.3 {
+		t.Errorf("middle bot out of order: %+v", points)
+	}
+}
@@ -0,0 +1,441 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"tictactoe/internal/logger"
+	"tictactoe/internal/models"
+	"tictactoe/internal/rules"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gameSnapshotsKey - хэш id партии -> снимок в JSON
+const gameSnapshotsKey = "game_snapshots"
+
+// gameSnapshot - все, что нужно, чтобы продолжить партию после перезапуска.
+// Таймеры не сохраняются и заводятся заново при восстановлении.
+type gameSnapshot struct {
+	ID             string               `json:"id"`
+	PlayerX        string               `json:"player_x"`
+	PlayerO        string               `json:"player_o"`
+	Options        rules.Options        `json:"options"`
+	Board          []string             `json:"board"`
+	Turn           string               `json:"turn"`
+	LastMove       int                  `json:"last_move"`
+	MoveCount      int                  `json:"move_count"`
+	IsFinished     bool                 `json:"is_finished"`
+	Winner         string               `json:"winner,omitempty"`
+	EndReason      string               `json:"end_reason,omitempty"`
+	PlayAgainX     bool                 `json:"play_again_x,omitempty"`
+	PlayAgainO     bool                 `json:"play_again_o,omitempty"`
+	IsBotGame      bool                 `json:"is_bot_game,omitempty"`
+	BotDifficulty  models.BotDifficulty `json:"bot_difficulty,omitempty"`
+	BotElo         int                  `json:"bot_elo,omitempty"`
+	BotPersonality string               `json:"bot_personality,omitempty"`
+	BotSymbol      string               `json:"bot_symbol,omitempty"`
+	Unrated        bool                 `json:"unrated,omitempty"`
+	StatsRecorded  bool                 `json:"stats_recorded,omitempty"`
+	RecordID       int                  `json:"record_id,omitempty"`
+	StartedAt      time.Time            `json:"started_at"`
+	Moves          []models.GameMove    `json:"moves"`
+	TimeControl    models.TimeControl   `json:"time_control"`
+	ClockX         time.Duration        `json:"clock_x,omitempty"`
+	ClockO         time.Duration        `json:"clock_o,omitempty"`
+	Seq            int64                `json:"seq,omitempty"`
+	// Spectators - число зрителей для списка партий на других экземплярах
+	Spectators int `json:"spectators,omitempty"`
+	// Disconnected - отключившиеся игроки и срок, до которого они могут вернуться
+	Disconnected map[string]time.Time `json:"disconnected,omitempty"`
+}
+
+func snapshotOf(game *models.Game) gameSnapshot {
+	var disconnected map[string]time.Time
+	if len(game.Disconnected) > 0 {
+		disconnected = make(map[string]time.Time, len(game.Disconnected))
+		for nickname, absence := range game.Disconnected {
+			disconnected[nickname] = absence.Deadline
+		}
+	}
+	return gameSnapshot{
+		ID:             game.ID,
+		PlayerX:        game.PlayerX,
+		PlayerO:        game.PlayerO,
+		Options:        game.Rules.Options(),
+		Board:          game.State.Board,
+		Turn:           game.State.Turn,
+		LastMove:       game.State.LastMove,
+		MoveCount:      game.State.MoveCount,
+		IsFinished:     game.IsFinished,
+		Winner:         game.Winner,
+		EndReason:      game.EndReason,
+		PlayAgainX:     game.PlayAgainX,
+		PlayAgainO:     game.PlayAgainO,
+		IsBotGame:      game.IsBotGame,
+		BotDifficulty:  game.BotDifficulty,
+		BotElo:         game.BotElo,
+		BotPersonality: game.BotPersonality,
+		BotSymbol:      game.BotSymbol,
+		Unrated:        game.Unrated,
+		StatsRecorded:  game.StatsRecorded,
+		RecordID:       game.RecordID,
+		StartedAt:      game.StartedAt,
+		Moves:          game.Moves,
+		TimeControl:    game.TimeControl,
+		ClockX:         game.ClockX,
+		ClockO:         game.ClockO,
+		Seq:            game.Seq,
+		Spectators:     len(game.Spectators),
+		Disconnected:   disconnected,
+	}
+}
+
+// botElo - рейтинг бота; в снимках, сделанных до появления рейтингов
+// ботов, он определяется по уровню сложности.
+func (s gameSnapshot) botElo() int {
+	if s.BotElo == 0 {
+		return s.BotDifficulty.Elo()
+	}
+	return s.BotElo
+}
+
+// liveGame описывает партию из снимка для списка зрителей.
+func (s gameSnapshot) liveGame() models.LiveGame {
+	return models.LiveGame{
+		ID:         s.ID,
+		PlayerX:    s.PlayerX,
+		PlayerO:    s.PlayerO,
+		Variant:    s.Options.Variant,
+		MoveCount:  s.MoveCount,
+		Spectators: s.Spectators,
+		IsBot:      s.IsBotGame,
+		StartedAt:  s.StartedAt,
+	}
+}
+
+// restore собирает партию из снимка. Время простоя сервера не списывается
+// с часов: ход начинается заново с момента восстановления. Таймеры
+// отключившихся игроков заводит resumeAbsences.
+func (s gameSnapshot) restore(now time.Time) (*models.Game, error) {
+	gameRules, err := rules.New(s.Options)
+	if err != nil {
+		return nil, err
+	}
+	state := gameRules.NewState()
+	if len(s.Board) != len(state.Board) {
+		return nil, rules.ErrInvalidMove
+	}
+	copy(state.Board, s.Board)
+	state.Turn = s.Turn
+	state.LastMove = s.LastMove
+	state.MoveCount = s.MoveCount
+
+	var disconnected map[string]*models.Absence
+	if len(s.Disconnected) > 0 && !s.IsFinished {
+		disconnected = make(map[string]*models.Absence, len(s.Disconnected))
+		for nickname, deadline := range s.Disconnected {
+			disconnected[nickname] = &models.Absence{Deadline: deadline}
+		}
+	}
+
+	return &models.Game{
+		ID:             s.ID,
+		PlayerX:        s.PlayerX,
+		PlayerO:        s.PlayerO,
+		Rules:          gameRules,
+		State:          state,
+		IsFinished:     s.IsFinished,
+		Winner:         s.Winner,
+		EndReason:      s.EndReason,
+		PlayAgainX:     s.PlayAgainX,
+		PlayAgainO:     s.PlayAgainO,
+		IsBotGame:      s.IsBotGame,
+		BotDifficulty:  s.BotDifficulty,
+		BotElo:         s.botElo(),
+		BotPersonality: s.BotPersonality,
+		BotSymbol:      s.BotSymbol,
+		Unrated:        s.Unrated,
+		StatsRecorded:  s.StatsRecorded,
+		RecordID:       s.RecordID,
+		StartedAt:      s.StartedAt,
+		Moves:          s.Moves,
+		TimeControl:    s.TimeControl,
+		ClockX:         s.ClockX,
+		ClockO:         s.ClockO,
+		Seq:            s.Seq,
+		Disconnected:   disconnected,
+		TurnStarted:    now,
+		LastActivity:   now,
+	}, nil
+}
+
+// SnapshotStore сохраняет идущие партии в Redis, чтобы пережить перезапуск.
+// Save и Delete вызываются под g.mu и только ставят изменение в очередь:
+// в Redis его пишет фоновая горутина, поэтому медленный Redis не
+// задерживает ходы. Из нескольких изменений одной партии до записи
+// доходит последнее.
+type SnapshotStore struct {
+	rdb *redis.Client
+
+	mu      sync.Mutex
+	pending map[string][]byte // id партии -> снимок, nil - удалить снимок
+	wake    chan struct{}
+
+	// flushMu держит запись, пока восстановление сверяет загруженные
+	// снимки с еще не записанными удалениями
+	flushMu sync.Mutex
+}
+
+func NewSnapshotStore(rdb *redis.Client) *SnapshotStore {
+	s := &SnapshotStore{
+		rdb:     rdb,
+		pending: make(map[string][]byte),
+		wake:    make(chan struct{}, 1),
+	}
+	go s.writeLoop()
+	return s
+}
+
+func (s *SnapshotStore) Save(game *models.Game) {
+	if s == nil {
+		return
+	}
+	data, err := json.Marshal(snapshotOf(game))
+	if err != nil {
+		logger.Error("Failed to encode game snapshot:", err)
+		return
+	}
+	s.queue(game.ID, data)
+}
+
+func (s *SnapshotStore) Delete(gameID string) {
+	if s == nil {
+		return
+	}
+	s.queue(gameID, nil)
+}
+
+func (s *SnapshotStore) queue(gameID string, data []byte) {
+	s.mu.Lock()
+	s.pending[gameID] = data
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deleting сообщает, ждет ли снимок партии удаления.
+func (s *SnapshotStore) deleting(gameID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.pending[gameID]
+	return ok && data == nil
+}
+
+func (s *SnapshotStore) writeLoop() {
+	for range s.wake {
+		s.flush()
+	}
+}
+
+func (s *SnapshotStore) flush() {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[string][]byte)
+	s.mu.Unlock()
+
+	ctx := context.Background()
+	for gameID, data := range pending {
+		if data == nil {
+			if err := s.rdb.HDel(ctx, gameSnapshotsKey, gameID).Err(); err != nil {
+				logger.Warn("failed to delete game snapshot:", err)
+			}
+			continue
+		}
+		if err := s.rdb.HSet(ctx, gameSnapshotsKey, gameID, data).Err(); err != nil {
+			logger.Warn("failed to save game snapshot:", err)
+		}
+	}
+}
+
+// all возвращает все снимки по id партии. Без Redis снимков нет.
+func (s *SnapshotStore) all() (map[string]string, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return s.rdb.HGetAll(context.Background(), gameSnapshotsKey).Result()
+}
+
+func (s *SnapshotStore) ids() ([]string, error) {
+	return s.rdb.HKeys(context.Background(), gameSnapshotsKey).Result()
+}
+
+func (s *SnapshotStore) load(ids []string) ([]interface{}, error) {
+	return s.rdb.HMGet(context.Background(), gameSnapshotsKey, ids...).Result()
+}
+
+// UseSnapshots включает сохранение партий в Redis.
+func (g *GameManager) UseSnapshots(snapshots *SnapshotStore) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.snapshots = snapshots
+}
+
+// OnRestore задает обработчик партий, поднятых из снимков: например, чтобы
+// бот сделал ход, если сейчас его очередь.
+func (g *GameManager) OnRestore(fn func(game *models.Game)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.onRestore = fn
+}
+
+// saveSnapshot вызывается под g.mu после каждого изменения партии.
+func (g *GameManager) saveSnapshot(game *models.Game) {
+	g.snapshots.Save(game)
+}
+
+// RestoreGames поднимает партии из снимков: после перезапуска и
+// периодически, забирая партии упавших экземпляров. Партии, которые ведет
+// другой работающий экземпляр, пропускаются. Redis читается без g.mu,
+// блокировка берется только чтобы добавить партии.
+func (g *GameManager) RestoreGames() {
+	restored := g.restoreGames()
+
+	g.mu.RLock()
+	onRestore := g.onRestore
+	g.mu.RUnlock()
+
+	if onRestore == nil {
+		return
+	}
+	for _, game := range restored {
+		onRestore(game)
+	}
+}
+
+func (g *GameManager) restoreGames() []*models.Game {
+	g.mu.RLock()
+	snapshots, owners := g.snapshots, g.owners
+	local := make(map[string]bool)
+	for _, game := range g.games {
+		local[game.ID] = true
+	}
+	g.mu.RUnlock()
+
+	if snapshots == nil {
+		return nil
+	}
+	ids, err := snapshots.ids()
+	if err != nil {
+		logger.Error("Failed to list game snapshots:", err)
+		return nil
+	}
+	var orphaned []string
+	for _, id := range ids {
+		if !local[id] {
+			orphaned = append(orphaned, id)
+		}
+	}
+	adopted := owners.Adopt(OwnedGame, orphaned)
+	if len(adopted) == 0 {
+		return nil
+	}
+
+	// Пока снимки загружаются и добавляются, удаления партий этого
+	// экземпляра остаются в очереди и видны через deleting
+	snapshots.flushMu.Lock()
+	defer snapshots.flushMu.Unlock()
+
+	data, err := snapshots.load(adopted)
+	if err != nil {
+		logger.Error("Failed to load game snapshots:", err)
+		return nil
+	}
+
+	now := time.Now()
+	var loaded []*models.Game
+	for i, id := range adopted {
+		raw, ok := data[i].(string)
+		if !ok {
+			continue
+		}
+		var snap gameSnapshot
+		if err := json.Unmarshal([]byte(raw), &snap); err != nil {
+			logger.Warn("invalid game snapshot:", id, err)
+			snapshots.Delete(id)
+			continue
+		}
+		game, err := snap.restore(now)
+		if err != nil {
+			logger.Warn("invalid game snapshot:", id, err)
+			snapshots.Delete(id)
+			continue
+		}
+		loaded = append(loaded, game)
+	}
+
+	g.mu.Lock()
+	var restored []*models.Game
+	for _, game := range loaded {
+		// Партия могла начаться и закончиться здесь, пока читался Redis
+		if g.hasGame(game) || snapshots.deleting(game.ID) {
+			continue
+		}
+		for _, nickname := range humans(game) {
+			g.games[nickname] = game
+		}
+		if !game.IsFinished {
+			if game.TimeControl.Enabled() {
+				g.scheduleFlag(game)
+			}
+			g.resumeAbsences(game)
+		}
+		restored = append(restored, game)
+	}
+	g.mu.Unlock()
+
+	for _, game := range restored {
+		owners.Claim(OwnedGame, ownedKeys(game)...)
+	}
+	if len(restored) > 0 {
+		logger.Info(fmt.Sprintf("Restored %d games from snapshots", len(restored)))
+	}
+	return restored
+}
+
+// hasGame сообщает, ведется ли партия с id game уже здесь. Вызывается под g.mu.
+func (g *GameManager) hasGame(game *models.Game) bool {
+	for _, nickname := range humans(game) {
+		if current, ok := g.games[nickname]; ok && current.ID == game.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// ownedKeys возвращает ключи партии в owners:game: ее id и ники игроков-людей.
+func ownedKeys(game *models.Game) []string {
+	return append([]string{game.ID}, humans(game)...)
+}
+
+// humans возвращает ники игроков-людей. Партия лежит в g.games только под
+// ними: имя бота общее для всех его партий.
+func humans(game *models.Game) []string {
+	var nicknames []string
+	if !game.IsBotGame || game.BotSymbol != rules.X {
+		nicknames = append(nicknames, game.PlayerX)
+	}
+	if !game.IsBotGame || game.BotSymbol != rules.O {
+		nicknames = append(nicknames, game.PlayerO)
+	}
+	return nicknames
+}
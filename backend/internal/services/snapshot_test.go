@@ -0,0 +1,129 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/rules"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	variants := []rules.GameRules{
+		rules.Classic{},
+		rules.Ultimate{},
+		rules.Wild{},
+		rules.Gomoku{Size: 9, WinLength: 5},
+	}
+	for _, gameRules := range variants {
+		t.Run(gameRules.Options().Key(), func(t *testing.T) {
+			g := NewGameManager(nil, nil)
+			tc := models.TimeControl{Initial: time.Minute, Increment: time.Second}
+			g.CreateGame("alice", "bob", "X", "O", gameRules, tc, false)
+			game, _ := g.GetGame("alice")
+			defer stopClock(game)
+			for i, player := 0, "alice"; i < 3; i++ {
+				move := gameRules.LegalMoves(game.State)[0]
+				if _, _, err := g.HandleMove(player, move, ""); err != nil {
+					t.Fatal(err)
+				}
+				if player == "alice" {
+					player = "bob"
+				} else {
+					player = "alice"
+				}
+			}
+			game.PlayAgainO = true
+
+			data, err := json.Marshal(snapshotOf(game))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var snap gameSnapshot
+			if err := json.Unmarshal(data, &snap); err != nil {
+				t.Fatal(err)
+			}
+			restored, err := snap.restore(time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(restored.State, game.State) {
+				t.Errorf("state = %+v, want %+v", restored.State, game.State)
+			}
+			if restored.Rules.Options() != game.Rules.Options() {
+				t.Errorf("options = %+v, want %+v", restored.Rules.Options(), game.Rules.Options())
+			}
+			if len(restored.Moves) != 3 || !restored.Unrated || !restored.PlayAgainO {
+				t.Errorf("restored game lost fields: %+v", restored)
+			}
+			if restored.ClockX != game.ClockX || restored.TimeControl != tc {
+				t.Errorf("clocks not restored: %v %+v", restored.ClockX, restored.TimeControl)
+			}
+			if len(gameRules.LegalMoves(restored.State)) != len(gameRules.LegalMoves(game.State)) {
+				t.Error("restored game has different legal moves")
+			}
+		})
+	}
+}
+
+func TestSnapshotRejectsMismatchedBoard(t *testing.T) {
+	snap := gameSnapshot{Options: rules.Options{Variant: rules.VariantClassic}, Board: make([]string, 4)}
+	if _, err := snap.restore(time.Now()); err == nil {
+		t.Fatal("expected a board of the wrong size to be rejected")
+	}
+}
+
+func TestOwnedKeys(t *testing.T) {
+	game := &models.Game{ID: "g1", PlayerX: "Bot_hard", PlayerO: "alice", IsBotGame: true, BotSymbol: rules.X}
+	if got := ownedKeys(game); !reflect.DeepEqual(got, []string{"g1", "alice"}) {
+		t.Fatalf("ownedKeys = %v", got)
+	}
+}
+
+func TestSnapshotLiveGame(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	g.CreateGame("alice", "bob", "X", "O", rules.Ultimate{}, models.TimeControl{}, false)
+	if _, err := g.Spectate("carol", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	game, _ := g.GetGame("alice")
+
+	data, err := json.Marshal(snapshotOf(game))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snap gameSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatal(err)
+	}
+	got, want := snap.liveGame(), liveGameOf(game)
+	if !got.StartedAt.Equal(want.StartedAt) {
+		t.Errorf("started at %v, want %v", got.StartedAt, want.StartedAt)
+	}
+	got.StartedAt = want.StartedAt
+	if got != want || got.Spectators != 1 {
+		t.Errorf("liveGame = %+v, want %+v", got, want)
+	}
+}
+
+func TestSnapshotStoreKeepsLastChange(t *testing.T) {
+	// Without writeLoop the changes stay queued
+	s := &SnapshotStore{pending: make(map[string][]byte), wake: make(chan struct{}, 1)}
+	game := &models.Game{ID: "g1", Rules: rules.Classic{}, State: rules.Classic{}.NewState()}
+
+	s.Save(game)
+	if s.deleting("g1") {
+		t.Fatal("saved snapshot reported as deleted")
+	}
+	s.Delete("g1")
+	if !s.deleting("g1") || len(s.pending) != 1 {
+		t.Fatalf("pending = %v, want a single delete", s.pending)
+	}
+	s.Save(game)
+	if s.deleting("g1") {
+		t.Fatal("a later save did not replace the delete")
+	}
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"tictactoe/internal/logger"
+	"tictactoe/internal/models"
+	"tictactoe/internal/protocol"
+)
+
+var ErrGameNotFound = errors.New("game not found")
+
+// Spectate подписывает nickname на события партии. target - id партии или
+// ник одного из игроков. Возвращает снимок game_state.
+func (g *GameManager) Spectate(nickname, target string) (*protocol.GameState, error) {
+	// Своя партия зрителя может идти на другом экземпляре
+	if g.Playing(nickname) {
+		return nil, ErrInGame
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	game, ok := g.games[target]
+	if !ok {
+		game = g.findGameByID(target)
+	}
+	if game == nil || game.IsFinished {
+		return nil, ErrGameNotFound
+	}
+
+	g.stopSpectatingLocked(nickname)
+	if game.Spectators == nil {
+		game.Spectators = make(map[string]struct{})
+	}
+	game.Spectators[nickname] = struct{}{}
+	g.spectating[nickname] = game
+	g.owners.Claim(OwnedSpectator, nickname)
+	g.saveSnapshot(game)
+
+	msg := gameStateMessage(game)
+	msg.Spectating = true
+	return msg, nil
+}
+
+// StopSpectating отписывает зрителя и возвращает ник игрока партии, чтобы
+// разослать новое число зрителей.
+func (g *GameManager) StopSpectating(nickname string) (string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.stopSpectatingLocked(nickname)
+}
+
+func (g *GameManager) stopSpectatingLocked(nickname string) (string, bool) {
+	game, ok := g.spectating[nickname]
+	if !ok {
+		return "", false
+	}
+	delete(g.spectating, nickname)
+	delete(game.Spectators, nickname)
+	g.owners.Release(OwnedSpectator, nickname)
+	if !game.IsFinished {
+		// Снимок законченной партии уже удален
+		g.saveSnapshot(game)
+	}
+	return humans(game)[0], true
+}
+
+// dropSpectators отписывает всех зрителей удаляемой партии. Вызывается под g.mu.
+func (g *GameManager) dropSpectators(game *models.Game) {
+	for nickname := range game.Spectators {
+		delete(g.spectating, nickname)
+		g.owners.Release(OwnedSpectator, nickname)
+	}
+	game.Spectators = nil
+}
+
+// Recipients возвращает игроков и зрителей партии nickname.
+func (g *GameManager) Recipients(nickname string) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	game, ok := g.games[nickname]
+	if !ok {
+		return nil
+	}
+	recipients := make([]string, 0, 2+len(game.Spectators))
+	recipients = append(recipients, humans(game)...)
+	for spectator := range game.Spectators {
+		recipients = append(recipients, spectator)
+	}
+	return recipients
+}
+
+// SpectatorCount возвращает число зрителей партии nickname.
+func (g *GameManager) SpectatorCount(nickname string) int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if game, ok := g.games[nickname]; ok {
+		return len(game.Spectators)
+	}
+	return 0
+}
+
+// LiveGames возвращает идущие партии, которые можно смотреть: свои и,
+// по снимкам в Redis, партии других экземпляров.
+func (g *GameManager) LiveGames() []models.LiveGame {
+	g.mu.RLock()
+	seen := make(map[string]bool)
+	live := []models.LiveGame{}
+	for _, game := range g.games {
+		if seen[game.ID] || game.IsFinished {
+			continue
+		}
+		seen[game.ID] = true
+		live = append(live, liveGameOf(game))
+	}
+	snapshots := g.snapshots
+	g.mu.RUnlock()
+
+	shared, err := snapshots.all()
+	if err != nil {
+		logger.Warn("failed to list game snapshots:", err)
+	}
+	for id, raw := range shared {
+		if seen[id] {
+			continue
+		}
+		var snap gameSnapshot
+		if err := json.Unmarshal([]byte(raw), &snap); err != nil || snap.IsFinished {
+			continue
+		}
+		live = append(live, snap.liveGame())
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].StartedAt.After(live[j].StartedAt) })
+	return live
+}
+
+func liveGameOf(game *models.Game) models.LiveGame {
+	return models.LiveGame{
+		ID:         game.ID,
+		PlayerX:    game.PlayerX,
+		PlayerO:    game.PlayerO,
+		Variant:    game.Rules.Options().Variant,
+		MoveCount:  game.State.MoveCount,
+		Spectators: len(game.Spectators),
+		IsBot:      game.IsBotGame,
+		StartedAt:  game.StartedAt,
+	}
+}
+
+func (g *GameManager) findGameByID(id string) *models.Game {
+	for _, game := range g.games {
+		if game.ID == id {
+			return game
+		}
+	}
+	return nil
+}
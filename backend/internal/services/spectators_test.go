@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+
+	"tictactoe/internal/models"
+	"tictactoe/internal/rules"
+)
+
+func TestSpectate(t *testing.T) {
+	g := NewGameManager(nil, nil)
+	g.CreateGame("alice", "bob", "X", "O", rules.Classic{}, models.TimeControl{}, true)
+
+	if _, err := g.Spectate("alice", "bob"); err == nil {
+		t.Fatal("expected a player to be refused as a spectator")
+	}
+
+	state, err := g.Spectate("carol", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gameID := state.GameID
+	if _, err := g.Spectate("dave", gameID); err != nil {
+		t.Fatal(err)
+	}
+	if got := g.SpectatorCount("bob"); got != 2 {
+		t.Fatalf("expected 2 spectators, got %d", got)
+	}
+	if got := len(g.Recipients("alice")); got != 4 {
+		t.Fatalf("expected players and spectators to receive events, got %d recipients", got)
+	}
+
+	if _, _, err := g.HandleMove("carol", rules.Move{Cell: 0}, ""); err == nil {
+		t.Fatal("expected a spectator move to be rejected")
+	}
+
+	if _, ok := g.StopSpectating("carol"); !ok {
+		t.Fatal("expected carol to stop spectating")
+	}
+	if got := g.SpectatorCount("alice"); got != 1 {
+		t.Fatalf("expected 1 spectator, got %d", got)
+	}
+
+	live := g.LiveGames()
+	if len(live) != 1 || live[0].ID != gameID || live[0].Spectators != 1 {
+		t.Fatalf("unexpected live games %+v", live)
+	}
+}
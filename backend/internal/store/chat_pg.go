@@ -0,0 +1,53 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"tictactoe/internal/models"
+)
+
+type ChatStore struct {
+	DB *sql.DB
+}
+
+func NewChatStore(db *sql.DB) *ChatStore {
+	return &ChatStore{DB: db}
+}
+
+func (s *ChatStore) SaveMessage(msg *models.ChatMessage) error {
+	err := s.DB.QueryRow(`
+		INSERT INTO chat_messages (player_x, player_o, game_started_at, sender, kind, body, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, msg.PlayerX, msg.PlayerO, msg.GameStartedAt, msg.Sender, msg.Kind, msg.Body, msg.CreatedAt).Scan(&msg.ID)
+	if err != nil {
+		return fmt.Errorf("insert chat message: %w", err)
+	}
+	return nil
+}
+
+// GetMessages returns the chat of one game in the order it was sent.
+func (s *ChatStore) GetMessages(playerX, playerO string, gameStartedAt time.Time) ([]models.ChatMessage, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, player_x, player_o, game_started_at, sender, kind, body, created_at
+		FROM chat_messages
+		WHERE player_x = $1 AND player_o = $2 AND game_started_at = $3
+		ORDER BY id
+	`, playerX, playerO, gameStartedAt)
+	if err != nil {
+		return nil, fmt.Errorf("query chat messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []models.ChatMessage
+	for rows.Next() {
+		var m models.ChatMessage
+		if err := rows.Scan(&m.ID, &m.PlayerX, &m.PlayerO, &m.GameStartedAt, &m.Sender, &m.Kind, &m.Body, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
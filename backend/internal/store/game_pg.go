@@ -0,0 +1,144 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"tictactoe/internal/models"
+)
+
+type GameStore struct {
+	DB *sql.DB
+}
+
+func NewGameStore(db *sql.DB) *GameStore {
+	return &GameStore{DB: db}
+}
+
+// SaveGame stores a finished game and its moves in one transaction and sets record.ID.
+func (s *GameStore) SaveGame(record *models.GameRecord) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO games (player_x, player_o, variant, board_size, win_length, mode, bot_difficulty,
+			time_control, result, reason, elo_x_before, elo_x_after, elo_o_before, elo_o_after,
+			started_at, finished_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		RETURNING id
+	`, record.PlayerX, record.PlayerO, record.Variant, record.Size, record.WinLength, record.Mode,
+		record.BotDifficulty, record.TimeControl, record.Result, record.Reason,
+		record.EloXBefore, record.EloXAfter, record.EloOBefore, record.EloOAfter,
+		record.StartedAt, record.FinishedAt).Scan(&record.ID)
+	if err != nil {
+		return fmt.Errorf("insert game: %w", err)
+	}
+
+	for _, move := range record.Moves {
+		_, err = tx.Exec(`
+			INSERT INTO game_moves (game_id, ply, seat, cell, symbol, played_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, record.ID, move.Ply, move.Seat, move.Cell, move.Symbol, move.PlayedAt)
+		if err != nil {
+			return fmt.Errorf("insert move: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+const gameColumns = `id, player_x, player_o, variant, board_size, win_length, mode, bot_difficulty,
+	time_control, result, reason, elo_x_before, elo_x_after, elo_o_before, elo_o_after,
+	started_at, finished_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanGame(row rowScanner) (*models.GameRecord, error) {
+	record := &models.GameRecord{}
+	var eloXBefore, eloXAfter, eloOBefore, eloOAfter sql.NullInt64
+	err := row.Scan(&record.ID, &record.PlayerX, &record.PlayerO, &record.Variant, &record.Size,
+		&record.WinLength, &record.Mode, &record.BotDifficulty, &record.TimeControl, &record.Result,
+		&record.Reason, &eloXBefore, &eloXAfter, &eloOBefore, &eloOAfter,
+		&record.StartedAt, &record.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	record.EloXBefore = nullableInt(eloXBefore)
+	record.EloXAfter = nullableInt(eloXAfter)
+	record.EloOBefore = nullableInt(eloOBefore)
+	record.EloOAfter = nullableInt(eloOAfter)
+	return record, nil
+}
+
+func nullableInt(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
+
+// GetGame returns a finished game with its full move list.
+func (s *GameStore) GetGame(id int) (*models.GameRecord, error) {
+	record, err := scanGame(s.DB.QueryRow(`SELECT `+gameColumns+` FROM games WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("game not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get game: %w", err)
+	}
+
+	rows, err := s.DB.Query(`
+		SELECT ply, seat, cell, symbol, played_at
+		FROM game_moves WHERE game_id = $1
+		ORDER BY ply
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("query moves: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var move models.GameMove
+		if err := rows.Scan(&move.Ply, &move.Seat, &move.Cell, &move.Symbol, &move.PlayedAt); err != nil {
+			return nil, err
+		}
+		record.Moves = append(record.Moves, move)
+	}
+	return record, rows.Err()
+}
+
+// GetGamesByPlayer returns the player's games newest first, without moves.
+// Only games with an id below beforeID are returned unless beforeID is 0.
+func (s *GameStore) GetGamesByPlayer(nickname string, beforeID, limit int) ([]models.GameRecord, error) {
+	rows, err := s.DB.Query(`
+		SELECT `+gameColumns+`
+		FROM games
+		WHERE (player_x = $1 OR player_o = $1) AND ($2 = 0 OR id < $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`, nickname, beforeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query games: %w", err)
+	}
+	defer rows.Close()
+
+	var games []models.GameRecord
+	for rows.Next() {
+		record, err := scanGame(rows)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, *record)
+	}
+	return games, rows.Err()
+}
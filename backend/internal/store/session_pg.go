@@ -49,9 +49,9 @@ func (s *UserStore) GetUserByNickname(nickname string) (*models.User, string, er
 func (s *UserStore) GetUserProfile(nickname string) (*models.User, error) {
 	user := &models.User{}
 	err := s.DB.QueryRow(`
-		SELECT id, nickname, wins, losses, draws, elo_rating 
+		SELECT id, nickname, wins, losses, draws, elo_rating, bot_elo_rating
 		FROM users WHERE nickname = $1
-	`, nickname).Scan(&user.ID, &user.Nickname, &user.Wins, &user.Losses, &user.Draws, &user.EloRating)
+	`, nickname).Scan(&user.ID, &user.Nickname, &user.Wins, &user.Losses, &user.Draws, &user.EloRating, &user.BotEloRating)
 
 	if err != nil {
 		return nil, fmt.Errorf("get user profile: %w", err)
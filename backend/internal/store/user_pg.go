@@ -44,3 +44,15 @@ func (s *UserStore) UpdateUserStats(nickname string, eloChange int, result strin
 	}
 	return nil
 }
+
+// UpdateBotRating меняет рейтинг игрока в партиях с ботами. Общая
+// статистика побед и поражений при этом не меняется.
+func (s *UserStore) UpdateBotRating(nickname string, eloChange int) error {
+	_, err := s.DB.Exec(`
+		UPDATE users SET bot_elo_rating = bot_elo_rating + $1 WHERE nickname = $2
+	`, eloChange, nickname)
+	if err != nil {
+		return fmt.Errorf("update bot rating: %w", err)
+	}
+	return nil
+}
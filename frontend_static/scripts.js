@@ -19,6 +19,7 @@ const MOVE_TIMEOUT = 15;
 const REMATCH_DURATION = 15;
 
 let statsInterval = null;
+let reconnectCountdownId = null;
 let opponentNickname = '';
 let myNickname = '';
 let activeSkin = 'default';
@@ -466,7 +467,7 @@ function handleCellClick(idx) {
     board[idx] = mySymbol;
     renderBoard();
     localStorage.setItem('savedGame', JSON.stringify({ gameMode, mySymbol, opponentSymbol, board }));
-    ws.send(JSON.stringify({ type: 'move', cell: idx }));
+    ws.send(JSON.stringify({ type: 'move', cell: idx, id: `${Date.now()}-${idx}` }));
   }
 }
 
@@ -661,7 +662,13 @@ function restoreOnlineGame(saved) {
   ws = new WebSocket(`${WS_URL}/ws`);
   ws.onopen = () => {
     console.log('WebSocket reconnected');
-    ws.send(JSON.stringify({ type: 'rejoin_match' }));
+    const gameId = localStorage.getItem('gameId');
+    if (gameId) {
+      const lastSeq = parseInt(localStorage.getItem('lastSeq'), 10) || 0;
+      ws.send(JSON.stringify({ type: 'resume', game_id: gameId, last_seq: lastSeq }));
+    } else {
+      ws.send(JSON.stringify({ type: 'rejoin_match' }));
+    }
   };
   setupWebSocketHandlers();
 
@@ -703,6 +710,21 @@ function showStartScreen() {
   }, 4300);
 }
 
+// Запоминает игру и номер последнего события, чтобы после переподключения
+// получить пропущенные события через resume.
+function trackSequence(msg) {
+  if (msg.game_id && (msg.type === 'match_found' || msg.type === 'game_state')) {
+    if (localStorage.getItem('gameId') !== msg.game_id) {
+      localStorage.setItem('lastSeq', '0');
+    }
+    localStorage.setItem('gameId', msg.game_id);
+  }
+  if (typeof msg.seq === 'number' && msg.type !== 'resumed') {
+    const lastSeq = parseInt(localStorage.getItem('lastSeq'), 10) || 0;
+    localStorage.setItem('lastSeq', String(Math.max(lastSeq, msg.seq)));
+  }
+}
+
 function setupWebSocketHandlers() {
   ws.onerror = (err) => {
     console.error('WebSocket error:', err);
@@ -726,6 +748,7 @@ function setupWebSocketHandlers() {
   ws.onmessage = (event) => {
     const msg = JSON.parse(event.data);
     console.log('Received WS message:', msg);
+    trackSequence(msg);
 
     switch (msg.type) {
       case 'game_state': {
@@ -816,6 +839,7 @@ function setupWebSocketHandlers() {
       }
 
       case 'game_over': {
+        clearInterval(reconnectCountdownId);
         updateStatus(msg.result === 'draw' ? "Draw!" : `${msg.result === mySymbol ? 'You win' : 'You lose'}!`);
 
         if (msg.result === 'draw') {
@@ -838,6 +862,30 @@ function setupWebSocketHandlers() {
         break;
       }
 
+      case 'welcome':
+      case 'resumed':
+        break;
+
+      case 'opponent_disconnected': {
+        let seconds = msg.seconds;
+        clearInterval(reconnectCountdownId);
+        updateStatus(`Opponent disconnected. Waiting ${seconds}s for them to return...`);
+        reconnectCountdownId = setInterval(() => {
+          seconds--;
+          if (seconds <= 0) {
+            clearInterval(reconnectCountdownId);
+            return;
+          }
+          updateStatus(`Opponent disconnected. Waiting ${seconds}s for them to return...`);
+        }, 1000);
+        break;
+      }
+
+      case 'opponent_reconnected':
+        clearInterval(reconnectCountdownId);
+        updateStatus(currentTurn === mySymbol ? "Your turn" : "Opponent's turn");
+        break;
+
       case 'opponent_left': {
         updateStatus("Opponent disconnected!");
         endGame();
@@ -869,7 +917,11 @@ function setupWebSocketHandlers() {
         break;
 
       case 'error':
-        if (msg.message === 'no active game') {
+        if (msg.request === 'resume') {
+          // Игра уже другая или журнал недоступен: просим полный снимок
+          localStorage.removeItem('gameId');
+          ws.send(JSON.stringify({ type: 'rejoin_match' }));
+        } else if (msg.code === 'no_active_game') {
           localStorage.removeItem('savedGame');
           backToMain();
         } else {